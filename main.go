@@ -4,10 +4,22 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/jadolg/klum/pkg/download"
 	"github.com/jadolg/klum/pkg/metrics"
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	klumv1alpha1 "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
 
 	"github.com/jadolg/klum/pkg/generated/controllers/klum.cattle.io"
 
@@ -30,6 +42,8 @@ var (
 	GitCommit  = "HEAD"
 	cfg        user.Config
 	kubeConfig string
+
+	downloadAuthProxyAllowedNames string
 )
 
 func main() {
@@ -57,6 +71,12 @@ func main() {
 			Value:       "default",
 			Destination: &cfg.ContextName,
 		},
+		cli.StringFlag{
+			Name:        "cluster-id",
+			Usage:       "Identifies this cluster in the Secrets written to other clusters. Defaults to the UID of the kube-system namespace",
+			EnvVar:      "CLUSTER_ID",
+			Destination: &cfg.ClusterID,
+		},
 		cli.StringFlag{
 			Name:        "server",
 			Usage:       "The external server field to put in the Kubeconfigs",
@@ -70,6 +90,37 @@ func main() {
 			EnvVar:      "CA",
 			Destination: &cfg.CA,
 		},
+		cli.BoolFlag{
+			Name:        "context-per-namespace",
+			Usage:       "Add a context for every namespace a user has a role in to the Kubeconfigs",
+			EnvVar:      "CONTEXT_PER_NAMESPACE",
+			Destination: &cfg.ContextPerNamespace,
+		},
+		cli.StringFlag{
+			Name:        "namespace-context-template",
+			Usage:       "Go template for the name of the per namespace contexts. Available fields: .Cluster, .Context, .User and .Namespace",
+			EnvVar:      "NAMESPACE_CONTEXT_TEMPLATE",
+			Value:       "{{.Context}}-{{.Namespace}}",
+			Destination: &cfg.NamespaceContextTemplate,
+		},
+		cli.StringFlag{
+			Name:        "tls-server-name",
+			Usage:       "The server name used to verify the server certificate in the Kubeconfigs",
+			EnvVar:      "TLS_SERVER_NAME",
+			Destination: &cfg.TLSServerName,
+		},
+		cli.StringFlag{
+			Name:        "proxy-url",
+			Usage:       "The proxy URL to put in the Kubeconfigs",
+			EnvVar:      "PROXY_URL",
+			Destination: &cfg.ProxyURL,
+		},
+		cli.BoolFlag{
+			Name:        "insecure-skip-tls-verify",
+			Usage:       "Skip the verification of the server certificate in the Kubeconfigs. The CA data is not added",
+			EnvVar:      "INSECURE_SKIP_TLS_VERIFY",
+			Destination: &cfg.InsecureSkipTLSVerify,
+		},
 		cli.StringFlag{
 			Name:        "default-cluster-role",
 			Usage:       "Default cluster-role to assign to users with no roles",
@@ -105,6 +156,214 @@ func main() {
 			Value:       0,
 			Destination: &cfg.GithubConfig.AppID,
 		},
+		cli.StringFlag{
+			Name:        "gitlab-token",
+			Usage:       "The token used to push kubeconfigs to GitLab CI/CD variables if you need this feature",
+			EnvVar:      "GITLAB_TOKEN",
+			Value:       "",
+			Destination: &cfg.GitlabConfig.Token,
+		},
+		cli.StringFlag{
+			Name:        "gitlab-url",
+			Usage:       "The GitLab URL if you are using a self-hosted GitLab",
+			EnvVar:      "GITLAB_URL",
+			Value:       "",
+			Destination: &cfg.GitlabConfig.BaseURL,
+		},
+		cli.StringFlag{
+			Name:        "gitea-token",
+			Usage:       "The token used to push kubeconfigs to Gitea or Forgejo Actions secrets if you need this feature",
+			EnvVar:      "GITEA_TOKEN",
+			Value:       "",
+			Destination: &cfg.GiteaConfig.Token,
+		},
+		cli.StringFlag{
+			Name:        "gitea-url",
+			Usage:       "The URL of the Gitea or Forgejo instance",
+			EnvVar:      "GITEA_URL",
+			Value:       "",
+			Destination: &cfg.GiteaConfig.BaseURL,
+		},
+		cli.StringFlag{
+			Name:        "vault-addr",
+			Usage:       "The address of the Vault server kubeconfigs are written to if you need this feature",
+			EnvVar:      "VAULT_ADDR",
+			Value:       "",
+			Destination: &cfg.VaultConfig.Address,
+		},
+		cli.StringFlag{
+			Name:        "vault-kubernetes-role",
+			Usage:       "Role used to log in to Vault with the Kubernetes auth method",
+			EnvVar:      "VAULT_KUBERNETES_ROLE",
+			Value:       "",
+			Destination: &cfg.VaultConfig.KubernetesRole,
+		},
+		cli.StringFlag{
+			Name:        "vault-kubernetes-auth-path",
+			Usage:       "Mount path of the Vault Kubernetes auth method",
+			EnvVar:      "VAULT_KUBERNETES_AUTH_PATH",
+			Value:       "kubernetes",
+			Destination: &cfg.VaultConfig.KubernetesAuthPath,
+		},
+		cli.StringFlag{
+			Name:        "vault-token-secret",
+			Usage:       "Secret in the klum namespace holding a Vault token under the token key. Used when no Kubernetes auth role is set",
+			EnvVar:      "VAULT_TOKEN_SECRET",
+			Value:       "",
+			Destination: &cfg.VaultConfig.TokenSecret,
+		},
+		cli.StringFlag{
+			Name:        "s3-endpoint",
+			Usage:       "The URL of the S3 compatible object storage encrypted kubeconfigs are uploaded to if you need this feature",
+			EnvVar:      "S3_ENDPOINT",
+			Value:       "",
+			Destination: &cfg.S3Config.Endpoint,
+		},
+		cli.StringFlag{
+			Name:        "s3-region",
+			Usage:       "The region of the S3 compatible object storage",
+			EnvVar:      "S3_REGION",
+			Value:       "us-east-1",
+			Destination: &cfg.S3Config.Region,
+		},
+		cli.StringFlag{
+			Name:        "s3-access-key-id",
+			Usage:       "The access key ID used to upload to the S3 compatible object storage",
+			EnvVar:      "S3_ACCESS_KEY_ID",
+			Value:       "",
+			Destination: &cfg.S3Config.AccessKeyID,
+		},
+		cli.StringFlag{
+			Name:        "s3-secret-access-key",
+			Usage:       "The secret access key used to upload to the S3 compatible object storage",
+			EnvVar:      "S3_SECRET_ACCESS_KEY",
+			Value:       "",
+			Destination: &cfg.S3Config.SecretAccessKey,
+		},
+		cli.StringFlag{
+			Name:        "smtp-host",
+			Usage:       "The SMTP server encrypted kubeconfigs are emailed through if you need this feature",
+			EnvVar:      "SMTP_HOST",
+			Value:       "",
+			Destination: &cfg.EmailConfig.Host,
+		},
+		cli.IntFlag{
+			Name:        "smtp-port",
+			Usage:       "The port of the SMTP server",
+			EnvVar:      "SMTP_PORT",
+			Value:       587,
+			Destination: &cfg.EmailConfig.Port,
+		},
+		cli.StringFlag{
+			Name:        "smtp-username",
+			Usage:       "The username used to authenticate to the SMTP server. No authentication if empty",
+			EnvVar:      "SMTP_USERNAME",
+			Value:       "",
+			Destination: &cfg.EmailConfig.Username,
+		},
+		cli.StringFlag{
+			Name:        "smtp-password",
+			Usage:       "The password used to authenticate to the SMTP server",
+			EnvVar:      "SMTP_PASSWORD",
+			Value:       "",
+			Destination: &cfg.EmailConfig.Password,
+		},
+		cli.StringFlag{
+			Name:        "email-from",
+			Usage:       "The sender address of kubeconfig emails",
+			EnvVar:      "EMAIL_FROM",
+			Value:       "",
+			Destination: &cfg.EmailConfig.From,
+		},
+		cli.StringFlag{
+			Name:        "email-subject",
+			Usage:       "Go template for the subject of kubeconfig emails",
+			EnvVar:      "EMAIL_SUBJECT",
+			Value:       "",
+			Destination: &cfg.EmailConfig.SubjectTemplate,
+		},
+		cli.StringFlag{
+			Name:        "email-template-file",
+			Usage:       "File with a Go template for the body of kubeconfig emails",
+			EnvVar:      "EMAIL_TEMPLATE_FILE",
+			Value:       "",
+			Destination: &cfg.EmailConfig.BodyTemplateFile,
+		},
+		cli.IntFlag{
+			Name:        "email-rate-limit",
+			Usage:       "Maximum number of kubeconfig emails sent per minute",
+			EnvVar:      "EMAIL_RATE_LIMIT",
+			Value:       10,
+			Destination: &cfg.EmailConfig.RateLimit,
+		},
+		cli.StringFlag{
+			Name:        "bitbucket-token",
+			Usage:       "Bitbucket access token, or app password with --bitbucket-username, if you need this feature",
+			EnvVar:      "BITBUCKET_TOKEN",
+			Value:       "",
+			Destination: &cfg.BitbucketConfig.Token,
+		},
+		cli.StringFlag{
+			Name:        "bitbucket-username",
+			Usage:       "Username used with --bitbucket-token for basic authentication. The token is sent as a bearer token if empty",
+			EnvVar:      "BITBUCKET_USERNAME",
+			Value:       "",
+			Destination: &cfg.BitbucketConfig.Username,
+		},
+		cli.StringFlag{
+			Name:        "bitbucket-url",
+			Usage:       "Base URL of the Bitbucket Cloud API, Bitbucket Data Center is not supported",
+			EnvVar:      "BITBUCKET_URL",
+			Value:       "https://api.bitbucket.org/2.0",
+			Destination: &cfg.BitbucketConfig.BaseURL,
+		},
+		cli.StringFlag{
+			Name:        "aws-access-key-id",
+			Usage:       "The access key ID used to write kubeconfigs to AWS Secrets Manager and SSM Parameter Store if you need this feature",
+			EnvVar:      "AWS_ACCESS_KEY_ID",
+			Value:       "",
+			Destination: &cfg.AWSConfig.AccessKeyID,
+		},
+		cli.StringFlag{
+			Name:        "aws-secret-access-key",
+			Usage:       "The secret access key used to write to AWS Secrets Manager and SSM Parameter Store",
+			EnvVar:      "AWS_SECRET_ACCESS_KEY",
+			Value:       "",
+			Destination: &cfg.AWSConfig.SecretAccessKey,
+		},
+		cli.StringFlag{
+			Name:        "aws-session-token",
+			Usage:       "The session token of temporary AWS credentials",
+			EnvVar:      "AWS_SESSION_TOKEN",
+			Value:       "",
+			Destination: &cfg.AWSConfig.SessionToken,
+		},
+		cli.StringFlag{
+			Name:        "aws-region",
+			Usage:       "The AWS region of Secrets Manager and SSM Parameter Store",
+			EnvVar:      "AWS_REGION",
+			Value:       "us-east-1",
+			Destination: &cfg.AWSConfig.Region,
+		},
+		cli.StringFlag{
+			Name:        "aws-endpoint",
+			Usage:       "Overrides the endpoint of Secrets Manager and SSM Parameter Store, e.g. to use LocalStack",
+			EnvVar:      "AWS_ENDPOINT_URL",
+			Value:       "",
+			Destination: &cfg.AWSConfig.Endpoint,
+		},
+		cli.BoolFlag{
+			Name:        "token-secret-ref",
+			Usage:       "Reference the token Secret from Kubeconfigs instead of storing the token in them",
+			EnvVar:      "TOKEN_SECRET_REF",
+			Destination: &cfg.TokenSecretRef,
+		},
+		cli.DurationFlag{
+			Name:        "verify-interval",
+			Usage:       "How often synchronized kubeconfigs are checked for being deleted or changed outside of klum. Disabled if 0",
+			EnvVar:      "VERIFY_INTERVAL",
+			Destination: &cfg.VerifyInterval,
+		},
 		cli.IntFlag{
 			Name:        "metrics-port",
 			Usage:       "Port used to export the /metrics endpoint",
@@ -112,6 +371,56 @@ func main() {
 			Value:       0,
 			Destination: &cfg.MetricsPort,
 		},
+		cli.IntFlag{
+			Name:        "download-port",
+			Usage:       "Port used to serve the /kubeconfig/<user> download endpoint. Disabled if 0",
+			EnvVar:      "DOWNLOAD_PORT",
+			Value:       0,
+			Destination: &cfg.DownloadConfig.Port,
+		},
+		cli.StringFlag{
+			Name:        "download-tls-cert-file",
+			Usage:       "Certificate file to serve the download endpoint over HTTPS",
+			EnvVar:      "DOWNLOAD_TLS_CERT_FILE",
+			Destination: &cfg.DownloadConfig.TLSCertFile,
+		},
+		cli.StringFlag{
+			Name:        "download-tls-key-file",
+			Usage:       "Key file to serve the download endpoint over HTTPS",
+			EnvVar:      "DOWNLOAD_TLS_KEY_FILE",
+			Destination: &cfg.DownloadConfig.TLSKeyFile,
+		},
+		cli.StringFlag{
+			Name:        "download-auth-proxy-user-header",
+			Usage:       "Header set by a trusted authenticating proxy with the name of the requester",
+			EnvVar:      "DOWNLOAD_AUTH_PROXY_USER_HEADER",
+			Destination: &cfg.DownloadConfig.AuthProxyUserHeader,
+		},
+		cli.StringFlag{
+			Name:        "download-auth-proxy-groups-header",
+			Usage:       "Header set by a trusted authenticating proxy with the groups of the requester",
+			EnvVar:      "DOWNLOAD_AUTH_PROXY_GROUPS_HEADER",
+			Destination: &cfg.DownloadConfig.AuthProxyGroupsHeader,
+		},
+		cli.StringFlag{
+			Name:        "download-auth-proxy-client-ca",
+			Usage:       "CA file the client certificate of the authenticating proxy is verified with. The proxy headers are only trusted from clients with a verified certificate",
+			EnvVar:      "DOWNLOAD_AUTH_PROXY_CLIENT_CA",
+			Destination: &cfg.DownloadConfig.AuthProxyClientCAFile,
+		},
+		cli.StringFlag{
+			Name:        "download-auth-proxy-allowed-names",
+			Usage:       "Comma separated common names allowed in the client certificate of the authenticating proxy. Any name signed by the CA is allowed if empty",
+			EnvVar:      "DOWNLOAD_AUTH_PROXY_ALLOWED_NAMES",
+			Destination: &downloadAuthProxyAllowedNames,
+		},
+		cli.StringFlag{
+			Name:        "download-admin-cluster-role",
+			Usage:       "Holders of this cluster-role can download the kubeconfig of any user",
+			EnvVar:      "DOWNLOAD_ADMIN_CLUSTER_ROLE",
+			Value:       "cluster-admin",
+			Destination: &cfg.DownloadConfig.AdminClusterRole,
+		},
 	}
 	app.Action = run
 
@@ -125,6 +434,40 @@ func run(c *cli.Context) error {
 	if cfg.GithubConfig.Enabled() {
 		logrus.Info("Synchronizing annotated credentials to github secrets")
 	}
+	if cfg.GitlabConfig.Enabled() {
+		logrus.Info("Synchronizing annotated credentials to gitlab variables")
+	}
+	if cfg.GiteaConfig.Enabled() {
+		logrus.Info("Synchronizing annotated credentials to gitea secrets")
+	}
+	if cfg.VaultConfig.Enabled() {
+		logrus.Info("Synchronizing annotated credentials to vault")
+	}
+	if cfg.S3Config.Enabled() {
+		logrus.Info("Synchronizing annotated credentials to s3")
+	}
+	if cfg.EmailConfig.Enabled() {
+		logrus.Info("Emailing annotated credentials to users")
+	}
+	if cfg.BitbucketConfig.Enabled() {
+		if err := cfg.BitbucketConfig.Validate(); err != nil {
+			return err
+		}
+		logrus.Info("Synchronizing annotated credentials to bitbucket variables")
+	}
+	if cfg.AWSConfig.Enabled() {
+		logrus.Info("Synchronizing annotated credentials to aws")
+	}
+	for _, name := range strings.Split(downloadAuthProxyAllowedNames, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			cfg.DownloadConfig.AuthProxyAllowedNames = append(cfg.DownloadConfig.AuthProxyAllowedNames, name)
+		}
+	}
+	if cfg.DownloadConfig.Enabled() {
+		if err := cfg.DownloadConfig.Validate(); err != nil {
+			return err
+		}
+	}
 	ctx := signals.SetupSignalContext()
 
 	restConfig, err := kubeconfig.GetNonInteractiveClientConfig(kubeConfig).ClientConfig()
@@ -166,6 +509,20 @@ func run(c *cli.Context) error {
 		return nil
 	}
 
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+
+	recorder, err := newEventRecorder(clientset)
+	if err != nil {
+		return err
+	}
+
+	if cfg.ClusterID, err = clusterID(ctx, clientset, cfg.ClusterID); err != nil {
+		return err
+	}
+
 	user.Register(ctx,
 		cfg,
 		apply,
@@ -173,9 +530,8 @@ func run(c *cli.Context) error {
 		rbac.Rbac().V1().ClusterRoleBinding(),
 		rbac.Rbac().V1().RoleBinding(),
 		core.Core().V1().Secret(),
-		klum.Klum().V1alpha1().Kubeconfig(),
-		klum.Klum().V1alpha1().User(),
-		klum.Klum().V1alpha1().UserSyncGithub(),
+		klum.Klum().V1alpha1(),
+		recorder,
 		k8sversion,
 	)
 
@@ -183,6 +539,22 @@ func run(c *cli.Context) error {
 		go metrics.StartMetricsServer(cfg.MetricsPort)
 	}
 
+	if cfg.DownloadConfig.Enabled() {
+		server := download.NewServer(
+			cfg.DownloadConfig,
+			cfg.Namespace,
+			klum.Klum().V1alpha1().Kubeconfig().Cache(),
+			core.Core().V1().Secret().Cache(),
+			rbac.Rbac().V1().ClusterRoleBinding().Cache(),
+			clientset.AuthenticationV1().TokenReviews(),
+		)
+		go func() {
+			if err := server.Start(ctx); err != nil {
+				logrus.Errorf("Error serving kubeconfig downloads: %v", err)
+			}
+		}()
+	}
+
 	if err := start.All(ctx, 2, klum, core, rbac); err != nil {
 		logrus.Fatalf("Error starting: %s", err.Error())
 	}
@@ -190,3 +562,29 @@ func run(c *cli.Context) error {
 	<-ctx.Done()
 	return nil
 }
+
+// clusterID returns id, or the UID of the kube-system namespace if it is empty. It is used as a label value.
+func clusterID(ctx context.Context, clientset kubernetes.Interface, id string) (string, error) {
+	if id == "" {
+		namespace, err := clientset.CoreV1().Namespaces().Get(ctx, metav1.NamespaceSystem, metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("failed to read the cluster id, set --cluster-id: %w", err)
+		}
+		id = string(namespace.UID)
+	}
+	if errs := validation.IsValidLabelValue(id); len(errs) > 0 {
+		return "", fmt.Errorf("invalid cluster id %q: %s", id, strings.Join(errs, ", "))
+	}
+	return id, nil
+}
+
+// newEventRecorder returns a recorder for the events of klum objects
+func newEventRecorder(clientset kubernetes.Interface) (record.EventRecorder, error) {
+	scheme := runtime.NewScheme()
+	if err := klumv1alpha1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+	return broadcaster.NewRecorder(scheme, corev1.EventSource{Component: "klum"}), nil
+}
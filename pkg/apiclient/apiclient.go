@@ -0,0 +1,133 @@
+// Package apiclient holds the HTTP client shared by the sync providers talking to REST APIs
+package apiclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Timeout bounds every request sent to a sync target
+const Timeout = 30 * time.Second
+
+// maxErrorSize is how much of the body of a failed response is kept in Error
+const maxErrorSize = 4096
+
+// NewHTTPClient returns an HTTP client whose requests time out after Timeout
+func NewHTTPClient() *http.Client {
+	return &http.Client{Timeout: Timeout}
+}
+
+// Error is returned for every response that is not a 2xx
+type Error struct {
+	// API names the API in the message, e.g. gitlab
+	API        string
+	StatusCode int
+	// Type is the error code of APIs returning one, e.g. ResourceNotFoundException
+	Type    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Type != "" {
+		return fmt.Sprintf("%s API returned %d %s: %s", e.API, e.StatusCode, e.Type, e.Message)
+	}
+	return fmt.Sprintf("%s API returned %d: %s", e.API, e.StatusCode, e.Message)
+}
+
+// HasStatus reports whether err is an Error with statusCode
+func HasStatus(err error, statusCode int) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == statusCode
+}
+
+// HasType reports whether err is an Error of errorType
+func HasType(err error, errorType string) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Type == errorType
+}
+
+func IsNotFound(err error) bool {
+	return HasStatus(err, http.StatusNotFound)
+}
+
+// ReadError returns the Error for the failed response resp
+func ReadError(api string, resp *http.Response) *Error {
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorSize))
+	return &Error{API: api, StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(message))}
+}
+
+// Client calls a JSON REST API
+type Client struct {
+	// API names the API in errors
+	API     string
+	BaseURL string
+	// Authorize adds the credentials to every request
+	Authorize func(req *http.Request)
+	// ErrorMessage extracts the message of a failed response from its body, the whole body is used if nil
+	ErrorMessage func(body []byte) string
+	HTTPClient   *http.Client
+}
+
+// NewClient returns a Client for the API at baseURL using a client from NewHTTPClient
+func NewClient(api, baseURL string, authorize func(req *http.Request)) *Client {
+	return &Client{
+		API:        api,
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Authorize:  authorize,
+		HTTPClient: NewHTTPClient(),
+	}
+}
+
+// Do sends body encoded as JSON to target and decodes the response into out. Both may be nil.
+// target is either a path relative to BaseURL or an absolute URL, e.g. the next page of a collection.
+func (c *Client) Do(ctx context.Context, method, target string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	if strings.HasPrefix(target, "/") {
+		target = c.BaseURL + target
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Authorize != nil {
+		c.Authorize(req)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := ReadError(c.API, resp)
+		if c.ErrorMessage != nil {
+			apiErr.Message = c.ErrorMessage([]byte(apiErr.Message))
+		}
+		return apiErr
+	}
+
+	if out != nil && resp.StatusCode != http.StatusNoContent {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package apiclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.Header.Get("X-Token"))
+		switch r.URL.Path {
+		case "/api/item":
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			_, _ = w.Write([]byte(`{"name":"item"}`))
+		case "/api/empty":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, `{"errors":["no such item"]}`, http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	c := NewClient("test", server.URL+"/api/", func(req *http.Request) {
+		req.Header.Set("X-Token", "secret")
+	})
+	assert.Equal(t, Timeout, c.HTTPClient.Timeout)
+
+	out := struct {
+		Name string `json:"name"`
+	}{}
+	require.NoError(t, c.Do(context.Background(), http.MethodPost, "/item", map[string]string{"a": "b"}, &out))
+	assert.Equal(t, "item", out.Name)
+
+	// Absolute URLs are used as is
+	require.NoError(t, c.Do(context.Background(), http.MethodGet, server.URL+"/api/empty", nil, &out))
+
+	err := c.Do(context.Background(), http.MethodGet, "/missing", nil, nil)
+	assert.True(t, IsNotFound(err))
+	assert.EqualError(t, err, `test API returned 404: {"errors":["no such item"]}`)
+
+	c.ErrorMessage = func(body []byte) string { return "parsed" }
+	err = c.Do(context.Background(), http.MethodGet, "/missing", nil, nil)
+	assert.EqualError(t, err, "test API returned 404: parsed")
+}
+
+func TestErrorType(t *testing.T) {
+	err := &Error{API: "aws", StatusCode: http.StatusBadRequest, Type: "ResourceNotFoundException", Message: "gone"}
+	assert.True(t, HasType(err, "ResourceNotFoundException"))
+	assert.False(t, IsNotFound(err))
+	assert.EqualError(t, err, "aws API returned 400 ResourceNotFoundException: gone")
+}
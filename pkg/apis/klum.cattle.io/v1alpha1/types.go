@@ -2,6 +2,10 @@ package v1alpha1
 
 import (
 	"fmt"
+	"net/mail"
+	"path"
+
+	"k8s.io/apimachinery/pkg/runtime"
 
 	"github.com/rancher/wrangler/v3/pkg/condition"
 	"github.com/rancher/wrangler/v3/pkg/genericcondition"
@@ -11,6 +15,10 @@ import (
 var (
 	UserReadyCondition     = condition.Cond("Ready")
 	UserSyncReadyCondition = condition.Cond("Ready")
+	// UserSyncReachableCondition is reported by targets that can check whether they can be reached
+	UserSyncReachableCondition = condition.Cond("Reachable")
+	// UserSyncDriftedCondition is true when the last verification found the target changed outside of klum
+	UserSyncDriftedCondition = condition.Cond("Drifted")
 )
 
 // +genclient
@@ -30,10 +38,43 @@ type UserSpec struct {
 	Roles            []NamespaceRole `json:"roles,omitempty"`
 	Context          string          `json:"context,omitempty"`
 	ContextNamespace string          `json:"contextNamespace,omitempty"`
+	// ContextPerNamespace adds a context for every namespace in Roles. Overrides the controller setting
+	ContextPerNamespace *bool `json:"contextPerNamespace,omitempty"`
+	// CredentialGeneration revokes the current token and issues a new one every time it is changed
+	CredentialGeneration int64 `json:"credentialGeneration,omitempty"`
+	// Kubeconfig holds additional settings rendered into the user's kubeconfig
+	Kubeconfig *KubeconfigOptions `json:"kubeconfig,omitempty"`
+}
+
+// KubeconfigOptions are the per user settings of the generated kubeconfig
+type KubeconfigOptions struct {
+	// ClientCertificateSecret is the name of a kubernetes.io/tls Secret in the klum namespace whose
+	// certificate and key are added to the user's credentials
+	ClientCertificateSecret string `json:"clientCertificateSecret,omitempty"`
+	// Exec specifies a credential plugin to fetch credentials for the user
+	Exec *ExecConfig `json:"exec,omitempty"`
+	// Impersonate is the username to impersonate
+	Impersonate string `json:"impersonate,omitempty"`
+	// ImpersonateUID is the uid to impersonate
+	ImpersonateUID string `json:"impersonateUID,omitempty"`
+	// ImpersonateGroups is the groups to impersonate
+	ImpersonateGroups []string `json:"impersonateGroups,omitempty"`
+	// ImpersonateUserExtra contains additional information for the impersonated user
+	ImpersonateUserExtra map[string][]string `json:"impersonateUserExtra,omitempty"`
+	// ContextExtensions are added to the extensions of the user's context
+	ContextExtensions []NamedExtension `json:"contextExtensions,omitempty"`
+	// Preferences are copied to the preferences of the kubeconfig
+	Preferences *Preferences `json:"preferences,omitempty"`
 }
 
 type UserStatus struct {
 	Conditions []genericcondition.GenericCondition `json:"conditions,omitempty"`
+	// CredentialGeneration is the last spec.credentialGeneration the credentials were issued for
+	CredentialGeneration int64 `json:"credentialGeneration,omitempty"`
+	// CredentialsIssued is set once the token Secret of the user was created
+	CredentialsIssued bool `json:"credentialsIssued,omitempty"`
+	// LastRevocationTime is when the credentials were last revoked through spec.credentialGeneration
+	LastRevocationTime *metav1.Time `json:"lastRevocationTime,omitempty"`
 }
 
 type NamespaceRole struct {
@@ -61,6 +102,29 @@ type KubeconfigSpec struct {
 	Contexts []NamedContext `json:"contexts"`
 	// CurrentContext is the name of the context that you would like to use by default
 	CurrentContext string `json:"current-context"`
+	// Preferences holds general information to be use for cli interactions
+	// +optional
+	Preferences *Preferences `json:"preferences,omitempty"`
+	// Extensions holds additional information. This is useful for extenders so that reads and writes don't clobber unknown fields
+	// +optional
+	Extensions []NamedExtension `json:"extensions,omitempty"`
+}
+
+// Preferences holds general information to be use for cli interactions
+type Preferences struct {
+	// +optional
+	Colors bool `json:"colors,omitempty"`
+	// Extensions holds additional information. This is useful for extenders so that reads and writes don't clobber unknown fields
+	// +optional
+	Extensions []NamedExtension `json:"extensions,omitempty"`
+}
+
+// NamedExtension relates nicknames to extension information
+type NamedExtension struct {
+	// Name is the nickname for this Extension
+	Name string `json:"name"`
+	// Extension holds the extension information
+	Extension runtime.RawExtension `json:"extension"`
 }
 
 // NamedCluster relates nicknames to cluster information
@@ -78,6 +142,15 @@ type Cluster struct {
 	// CertificateAuthorityData contains PEM-encoded certificate authority certificates. Overrides CertificateAuthority
 	// +optional
 	CertificateAuthorityData string `json:"certificate-authority-data,omitempty"`
+	// InsecureSkipTLSVerify skips the validity check for the server's certificate. This will make your HTTPS connections insecure.
+	// +optional
+	InsecureSkipTLSVerify bool `json:"insecure-skip-tls-verify,omitempty"`
+	// TLSServerName is used to check server certificate. If TLSServerName is empty, the hostname used to contact the server is used.
+	// +optional
+	TLSServerName string `json:"tls-server-name,omitempty"`
+	// ProxyURL is the URL to the proxy to be used for all requests made by this client.
+	// +optional
+	ProxyURL string `json:"proxy-url,omitempty"`
 }
 
 // NamedAuthInfo relates nicknames to auth information
@@ -93,6 +166,76 @@ type AuthInfo struct {
 	// Token is the bearer token for authentication to the kubernetes cluster.
 	// +optional
 	Token string `json:"token,omitempty"`
+	// TokenSecretRef references the Secret holding the bearer token when klum runs with token references
+	// instead of inline tokens. It must be resolved before the kubeconfig is handed to a client.
+	// +optional
+	TokenSecretRef *SecretKeyReference `json:"tokenSecretRef,omitempty"`
+	// ClientCertificateData contains PEM-encoded data from a client cert file for TLS.
+	// +optional
+	ClientCertificateData string `json:"client-certificate-data,omitempty"`
+	// ClientKeyData contains PEM-encoded data from a client key file for TLS.
+	// +optional
+	ClientKeyData string `json:"client-key-data,omitempty"`
+	// ClientKeySecretRef references the Secret holding the client key when klum runs with token references.
+	// +optional
+	ClientKeySecretRef *SecretKeyReference `json:"clientKeySecretRef,omitempty"`
+	// Impersonate is the username to impersonate.  The name matches the flag.
+	// +optional
+	Impersonate string `json:"as,omitempty"`
+	// ImpersonateUID is the uid to impersonate.
+	// +optional
+	ImpersonateUID string `json:"as-uid,omitempty"`
+	// ImpersonateGroups is the groups to impersonate.
+	// +optional
+	ImpersonateGroups []string `json:"as-groups,omitempty"`
+	// ImpersonateUserExtra contains additional information for impersonated user.
+	// +optional
+	ImpersonateUserExtra map[string][]string `json:"as-user-extra,omitempty"`
+	// Exec specifies a custom exec-based authentication plugin for the kubernetes cluster.
+	// +optional
+	Exec *ExecConfig `json:"exec,omitempty"`
+}
+
+// ExecConfig specifies a command to provide client credentials. The command is exec'd
+// and outputs structured stdout holding credentials.
+type ExecConfig struct {
+	// Command to execute.
+	Command string `json:"command"`
+	// Arguments to pass to the command when executing it.
+	// +optional
+	Args []string `json:"args,omitempty"`
+	// Env defines additional environment variables to expose to the process.
+	// +optional
+	Env []ExecEnvVar `json:"env,omitempty"`
+	// Preferred input version of the ExecInfo.
+	APIVersion string `json:"apiVersion"`
+	// This text is shown to the user when the executable doesn't seem to be present.
+	// +optional
+	InstallHint string `json:"installHint,omitempty"`
+	// ProvideClusterInfo determines whether or not to provide cluster information to the exec plugin.
+	// +optional
+	ProvideClusterInfo bool `json:"provideClusterInfo,omitempty"`
+	// InteractiveMode determines this plugin's relationship with standard input (Never, IfAvailable or Always).
+	// +optional
+	InteractiveMode string `json:"interactiveMode,omitempty"`
+}
+
+// ExecEnvVar is used for setting environment variables when executing an exec-based
+// credential plugin.
+type ExecEnvVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// SecretKeyReference points at a single key of a Secret
+type SecretKeyReference struct {
+	// Namespace of the Secret
+	Namespace string `json:"namespace"`
+	// Name of the Secret
+	Name string `json:"name"`
+	// Key inside the Secret data. Defaults to "token"
+	// +optional
+	Key string `json:"key,omitempty"`
 }
 
 // Context is a tuple of references to a cluster (how do I communicate with a kubernetes cluster), a user (how do I identify myself), and a namespace (what subset of resources do I want to work with)
@@ -103,6 +246,9 @@ type Context struct {
 	AuthInfo string `json:"user"`
 	// Namespace is the name of the current namespace for this context
 	Namespace string `json:"namespace,omitempty"`
+	// Extensions holds additional information. This is useful for extenders so that reads and writes don't clobber unknown fields
+	// +optional
+	Extensions []NamedExtension `json:"extensions,omitempty"`
 }
 
 // NamedContext relates nicknames to context information
@@ -124,25 +270,791 @@ type UserSyncGithub struct {
 	Status            UserSyncStatus     `json:"status,omitempty"`
 }
 
+func (u *UserSyncGithub) SyncUser() string {
+	return u.Spec.User
+}
+
+func (u *UserSyncGithub) GetSyncStatus() UserSyncStatus {
+	return u.Status
+}
+
+func (u *UserSyncGithub) SetSyncStatus(status UserSyncStatus) {
+	u.Status = status
+}
+
 type UserSyncGithubSpec struct {
 	User   string         `json:"user"`
 	Github GithubSyncSpec `json:"github"`
 }
 
+const (
+	GithubKindActions    = "actions"
+	GithubKindDependabot = "dependabot"
+	GithubKindCodespaces = "codespaces"
+
+	GithubVisibilityAll      = "all"
+	GithubVisibilityPrivate  = "private"
+	GithubVisibilitySelected = "selected"
+
+	// GithubFormatYAML stores the kubeconfig as YAML
+	GithubFormatYAML = "yaml"
+	// GithubFormatJSON stores the kubeconfig as JSON
+	GithubFormatJSON = "json"
+	// GithubFormatBase64YAML stores the kubeconfig as base64 encoded YAML
+	GithubFormatBase64YAML = "base64-yaml"
+	// GithubFormatSplit stores the server, CA and token of the current context in separate secrets
+	GithubFormatSplit = "split"
+	// GithubFormatTemplate stores the output of a Go template
+	GithubFormatTemplate = "template"
+)
+
 type GithubSyncSpec struct {
-	Owner       string `json:"owner"`
-	Repository  string `json:"repository"`
+	Owner string `json:"owner"`
+	// Repository is omitted for organization secrets, Owner is then the organization
+	Repository string `json:"repository,omitempty"`
+	// Environment is also the default environment of Targets and of the repositories matching
+	// RepositorySelector
 	Environment string `json:"environment,omitempty"`
 	SecretName  string `json:"secretName"`
+	// Kind of the secret, one of actions (default), dependabot or codespaces
+	Kind string `json:"kind,omitempty"`
+	// Visibility of an organization secret, one of all, private (default) or selected
+	Visibility string `json:"visibility,omitempty"`
+	// SelectedRepositories are the names of the repositories of the organization that can
+	// access an organization secret with the selected visibility
+	SelectedRepositories []string `json:"selectedRepositories,omitempty"`
+	// Targets creates the secret in several repositories instead of Repository
+	Targets []GithubTarget `json:"targets,omitempty"`
+	// RepositorySelector creates the secret in the repositories of Owner matching the selector
+	// instead of Repository
+	RepositorySelector *GithubRepositorySelector `json:"repositorySelector,omitempty"`
+	// Format of the secret, one of yaml (default), json, base64-yaml, split or template
+	Format string `json:"format,omitempty"`
+	// SplitPrefix is the prefix of the SERVER, CA and TOKEN secrets of the split format, defaults
+	// to SecretName followed by an underscore. SecretName is not used then.
+	SplitPrefix string `json:"splitPrefix,omitempty"`
+	// Template is the Go template of the template format. It can use .Kubeconfig (the YAML),
+	// .Server, .CA (PEM), .Token, .Context, .Namespace and the base64 function.
+	Template string `json:"template,omitempty"`
+	// EnvironmentProtection is applied to the environments klum creates
+	EnvironmentProtection *GithubEnvironmentProtection `json:"environmentProtection,omitempty"`
+	// Variables publishes values of the kubeconfig that are not secret as Actions variables of the
+	// repositories, or environments, of the secret
+	Variables *GithubVariables `json:"variables,omitempty"`
+}
+
+// GithubVariables selects the values of the current context of the kubeconfig published as variables
+type GithubVariables struct {
+	// Prefix of the names of the variables, defaults to KUBE_
+	Prefix string `json:"prefix,omitempty"`
+	// Server publishes the <Prefix>SERVER variable
+	Server bool `json:"server,omitempty"`
+	// Context publishes the <Prefix>CONTEXT variable
+	Context bool `json:"context,omitempty"`
+	// Namespace publishes the <Prefix>NAMESPACE variable
+	Namespace bool `json:"namespace,omitempty"`
+	// Cluster publishes the <Prefix>CLUSTER variable
+	Cluster bool `json:"cluster,omitempty"`
+}
+
+// GithubTarget is a repository, or an environment of a repository, the secret is created in
+type GithubTarget struct {
+	// Owner defaults to the owner of the sync
+	Owner       string `json:"owner,omitempty"`
+	Repository  string `json:"repository"`
+	Environment string `json:"environment,omitempty"`
+}
+
+// GithubRepositorySelector selects the repositories of an owner having a topic and a name
+// matching a pattern. Archived repositories are never selected.
+type GithubRepositorySelector struct {
+	Topic string `json:"topic,omitempty"`
+	// NamePattern is a shell pattern, e.g. service-*
+	NamePattern string `json:"namePattern,omitempty"`
+}
+
+// GithubEnvironmentProtection are the protection rules of an environment
+type GithubEnvironmentProtection struct {
+	// Reviewers must approve deployments to the environment, up to 6
+	Reviewers []GithubReviewer `json:"reviewers,omitempty"`
+	// WaitTimer is the number of minutes deployments wait before they proceed, up to 43200 (30 days)
+	WaitTimer int `json:"waitTimer,omitempty"`
+	// PreventSelfReview prevents the reviewers from approving their own deployments
+	PreventSelfReview bool `json:"preventSelfReview,omitempty"`
+	// DeploymentBranchPolicy restricts the branches and tags that can deploy to the environment,
+	// all of them can if it is not set
+	DeploymentBranchPolicy *GithubDeploymentBranchPolicy `json:"deploymentBranchPolicy,omitempty"`
+	// Enforce applies the rules to existing environments too, reverting changes made on GitHub on
+	// every upload. Otherwise they are only applied when klum creates the environment.
+	Enforce bool `json:"enforce,omitempty"`
+}
+
+// GithubReviewer is a user, or a team of the owner, by name
+type GithubReviewer struct {
+	User string `json:"user,omitempty"`
+	// Team is the slug of the team
+	Team string `json:"team,omitempty"`
+}
+
+// GithubDeploymentBranchPolicy allows deployments from protected branches, or from the branches and
+// tags matching patterns
+type GithubDeploymentBranchPolicy struct {
+	ProtectedBranches bool `json:"protectedBranches,omitempty"`
+	// Branches and Tags are name patterns, e.g. release/*
+	Branches []string `json:"branches,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+func (p *GithubEnvironmentProtection) validate() error {
+	if len(p.Reviewers) > 6 {
+		return fmt.Errorf("environments can have up to 6 reviewers")
+	}
+	for _, reviewer := range p.Reviewers {
+		if (reviewer.User == "") == (reviewer.Team == "") {
+			return fmt.Errorf("reviewers need either a user or a team")
+		}
+	}
+	if p.WaitTimer < 0 || p.WaitTimer > 43200 {
+		return fmt.Errorf("waitTimer must be between 0 and 43200 minutes")
+	}
+	if policy := p.DeploymentBranchPolicy; policy != nil {
+		custom := len(policy.Branches) > 0 || len(policy.Tags) > 0
+		if policy.ProtectedBranches == custom {
+			return fmt.Errorf("deploymentBranchPolicy needs either protectedBranches or branches and tags")
+		}
+	}
+	return nil
+}
+
+// FanOut reports whether the secret is created in several repositories
+func (g *GithubSyncSpec) FanOut() bool {
+	return len(g.Targets) > 0 || g.RepositorySelector != nil
 }
 
 func (g *GithubSyncSpec) Validate() error {
-	if g.SecretName != "" && g.Owner != "" && g.Repository != "" {
+	if g.SecretName == "" || g.Owner == "" {
+		return fmt.Errorf("not enough github data to be able to remove a GitHub secret")
+	}
+	environments := g.Environment != ""
+	for _, target := range g.Targets {
+		if target.Repository == "" {
+			return fmt.Errorf("github targets need a repository")
+		}
+		environments = environments || target.Environment != ""
+	}
+	switch g.Kind {
+	case "", GithubKindActions:
+	case GithubKindDependabot, GithubKindCodespaces:
+		if environments {
+			return fmt.Errorf("environment secrets are only supported for the %s kind", GithubKindActions)
+		}
+	default:
+		return fmt.Errorf("unsupported github secret kind %q", g.Kind)
+	}
+	switch g.Format {
+	case "", GithubFormatYAML, GithubFormatJSON, GithubFormatBase64YAML, GithubFormatSplit:
+		if g.Template != "" {
+			return fmt.Errorf("template needs the %s format", GithubFormatTemplate)
+		}
+	case GithubFormatTemplate:
+		if g.Template == "" {
+			return fmt.Errorf("the %s format needs a template", GithubFormatTemplate)
+		}
+	default:
+		return fmt.Errorf("unsupported github format %q", g.Format)
+	}
+	if g.SplitPrefix != "" && g.Format != GithubFormatSplit {
+		return fmt.Errorf("splitPrefix needs the %s format", GithubFormatSplit)
+	}
+	if v := g.Variables; v != nil {
+		if !v.Server && !v.Context && !v.Namespace && !v.Cluster {
+			return fmt.Errorf("variables need at least one of server, context, namespace and cluster")
+		}
+		if g.Repository == "" && !g.FanOut() {
+			return fmt.Errorf("variables need a repository")
+		}
+		if g.Kind != "" && g.Kind != GithubKindActions {
+			return fmt.Errorf("variables are only supported for the %s kind", GithubKindActions)
+		}
+	}
+	if g.EnvironmentProtection != nil {
+		if !environments {
+			return fmt.Errorf("environmentProtection needs an environment")
+		}
+		if err := g.EnvironmentProtection.validate(); err != nil {
+			return err
+		}
+	}
+	if g.FanOut() {
+		if g.Repository != "" || (len(g.Targets) > 0 && g.RepositorySelector != nil) {
+			return fmt.Errorf("only one of repository, targets and repositorySelector can be set")
+		}
+		if selector := g.RepositorySelector; selector != nil {
+			if selector.Topic == "" && selector.NamePattern == "" {
+				return fmt.Errorf("repositorySelector needs a topic or a namePattern")
+			}
+			if _, err := path.Match(selector.NamePattern, ""); err != nil {
+				return fmt.Errorf("invalid namePattern %q: %w", selector.NamePattern, err)
+			}
+		}
+	}
+	if g.Repository != "" || g.FanOut() {
+		if g.Visibility != "" || len(g.SelectedRepositories) > 0 {
+			return fmt.Errorf("visibility and selectedRepositories are only supported for organization secrets")
+		}
+		return nil
+	}
+	if g.Environment != "" {
+		return fmt.Errorf("environment secrets need a repository")
+	}
+	switch g.Visibility {
+	case "", GithubVisibilityAll, GithubVisibilityPrivate:
+		if len(g.SelectedRepositories) > 0 {
+			return fmt.Errorf("selectedRepositories need the %s visibility", GithubVisibilitySelected)
+		}
+	case GithubVisibilitySelected:
+	default:
+		return fmt.Errorf("unsupported github visibility %q", g.Visibility)
+	}
+	return nil
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+type UserSyncGitlab struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              UserSyncGitlabSpec `json:"spec"`
+	Status            UserSyncStatus     `json:"status,omitempty"`
+}
+
+func (u *UserSyncGitlab) SyncUser() string {
+	return u.Spec.User
+}
+
+func (u *UserSyncGitlab) GetSyncStatus() UserSyncStatus {
+	return u.Status
+}
+
+func (u *UserSyncGitlab) SetSyncStatus(status UserSyncStatus) {
+	u.Status = status
+}
+
+type UserSyncGitlabSpec struct {
+	User   string         `json:"user"`
+	Gitlab GitlabSyncSpec `json:"gitlab"`
+}
+
+const (
+	GitlabVariableTypeEnvVar = "env_var"
+	GitlabVariableTypeFile   = "file"
+)
+
+type GitlabSyncSpec struct {
+	// Project is the ID or full path (e.g. group/project) of the project owning the variable
+	Project string `json:"project,omitempty"`
+	// Group is the ID or full path of the group owning the variable. Mutually exclusive with Project
+	Group        string `json:"group,omitempty"`
+	VariableName string `json:"variableName"`
+	// VariableType is env_var (default) or file
+	VariableType string `json:"variableType,omitempty"`
+	// EnvironmentScope limits the variable to matching environments. Defaults to "*"
+	EnvironmentScope string `json:"environmentScope,omitempty"`
+	Protected        bool   `json:"protected,omitempty"`
+	// Masked hides the variable in job logs. GitLab only masks single line values, so the kubeconfig is stored base64 encoded
+	Masked bool `json:"masked,omitempty"`
+}
+
+func (g *GitlabSyncSpec) Validate() error {
+	if g.VariableName == "" {
+		return fmt.Errorf("gitlab variableName is required")
+	}
+	if (g.Project == "") == (g.Group == "") {
+		return fmt.Errorf("exactly one of gitlab project or group is required")
+	}
+	switch g.VariableType {
+	case "", GitlabVariableTypeEnvVar, GitlabVariableTypeFile:
+	default:
+		return fmt.Errorf("invalid gitlab variableType %q, must be %s or %s", g.VariableType, GitlabVariableTypeEnvVar, GitlabVariableTypeFile)
+	}
+	return nil
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+type UserSyncGitea struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              UserSyncGiteaSpec `json:"spec"`
+	Status            UserSyncStatus    `json:"status,omitempty"`
+}
+
+func (u *UserSyncGitea) SyncUser() string {
+	return u.Spec.User
+}
+
+func (u *UserSyncGitea) GetSyncStatus() UserSyncStatus {
+	return u.Status
+}
+
+func (u *UserSyncGitea) SetSyncStatus(status UserSyncStatus) {
+	u.Status = status
+}
+
+type UserSyncGiteaSpec struct {
+	User  string        `json:"user"`
+	Gitea GiteaSyncSpec `json:"gitea"`
+}
+
+type GiteaSyncSpec struct {
+	// Owner is the user or organization owning the secret
+	Owner string `json:"owner"`
+	// Repository is the repository owning the secret. Organization level secrets are created when empty
+	Repository string `json:"repository,omitempty"`
+	SecretName string `json:"secretName"`
+}
+
+func (g *GiteaSyncSpec) Validate() error {
+	if g.SecretName != "" && g.Owner != "" {
 		return nil
 	}
-	return fmt.Errorf("not enough github data to be able to remove a GitHub secret")
+	return fmt.Errorf("not enough gitea data to be able to manage a Gitea secret")
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+type UserSyncVault struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              UserSyncVaultSpec `json:"spec"`
+	Status            UserSyncStatus    `json:"status,omitempty"`
+}
+
+func (u *UserSyncVault) SyncUser() string {
+	return u.Spec.User
+}
+
+func (u *UserSyncVault) GetSyncStatus() UserSyncStatus {
+	return u.Status
+}
+
+func (u *UserSyncVault) SetSyncStatus(status UserSyncStatus) {
+	u.Status = status
+}
+
+type UserSyncVaultSpec struct {
+	User  string        `json:"user"`
+	Vault VaultSyncSpec `json:"vault"`
+}
+
+const (
+	// VaultFormatKubeconfig stores the whole kubeconfig under the kubeconfig key
+	VaultFormatKubeconfig = "kubeconfig"
+	// VaultFormatCredentials stores the token, server and ca keys only
+	VaultFormatCredentials = "credentials"
+
+	// VaultDeletePolicyDelete removes every version and the metadata of the secret
+	VaultDeletePolicyDelete = "delete"
+	// VaultDeletePolicySoftDelete deletes the latest version, which can still be undeleted
+	VaultDeletePolicySoftDelete = "softDelete"
+)
+
+type VaultSyncSpec struct {
+	// Mount is the path the KV v2 secrets engine is mounted at. Defaults to secret
+	Mount string `json:"mount,omitempty"`
+	Path  string `json:"path"`
+	// Format is kubeconfig (default) or credentials
+	Format string `json:"format,omitempty"`
+	// DeletePolicy is delete (default) or softDelete
+	DeletePolicy string `json:"deletePolicy,omitempty"`
+}
+
+func (v *VaultSyncSpec) Validate() error {
+	if v.Path == "" {
+		return fmt.Errorf("vault path is required")
+	}
+	switch v.Format {
+	case "", VaultFormatKubeconfig, VaultFormatCredentials:
+	default:
+		return fmt.Errorf("invalid vault format %q, must be %s or %s", v.Format, VaultFormatKubeconfig, VaultFormatCredentials)
+	}
+	switch v.DeletePolicy {
+	case "", VaultDeletePolicyDelete, VaultDeletePolicySoftDelete:
+	default:
+		return fmt.Errorf("invalid vault deletePolicy %q, must be %s or %s", v.DeletePolicy, VaultDeletePolicyDelete, VaultDeletePolicySoftDelete)
+	}
+	return nil
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+type UserSyncS3 struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              UserSyncS3Spec `json:"spec"`
+	Status            UserSyncStatus `json:"status,omitempty"`
+}
+
+func (u *UserSyncS3) SyncUser() string {
+	return u.Spec.User
+}
+
+func (u *UserSyncS3) GetSyncStatus() UserSyncStatus {
+	return u.Status
+}
+
+func (u *UserSyncS3) SetSyncStatus(status UserSyncStatus) {
+	u.Status = status
+}
+
+type UserSyncS3Spec struct {
+	User string     `json:"user"`
+	S3   S3SyncSpec `json:"s3"`
+}
+
+type S3SyncSpec struct {
+	Bucket string `json:"bucket"`
+	// Key is a Go template for the object key. Available fields: .User, .Name (of the sync object) and .Extension
+	// (.age or .asc depending on PublicKey). Defaults to {{.User}}.kubeconfig{{.Extension}}
+	Key string `json:"key,omitempty"`
+	// PublicKey is the age (age1...) or ASCII armored PGP public key the kubeconfig is encrypted for
+	PublicKey string `json:"publicKey"`
+}
+
+func (s *S3SyncSpec) Validate() error {
+	if s.Bucket != "" && s.PublicKey != "" {
+		return nil
+	}
+	return fmt.Errorf("s3 bucket and publicKey are required")
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+type UserSyncWebhook struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              UserSyncWebhookSpec `json:"spec"`
+	Status            UserSyncStatus      `json:"status,omitempty"`
+}
+
+func (u *UserSyncWebhook) SyncUser() string {
+	return u.Spec.User
+}
+
+func (u *UserSyncWebhook) GetSyncStatus() UserSyncStatus {
+	return u.Status
+}
+
+func (u *UserSyncWebhook) SetSyncStatus(status UserSyncStatus) {
+	u.Status = status
+}
+
+type UserSyncWebhookSpec struct {
+	User    string          `json:"user"`
+	Webhook WebhookSyncSpec `json:"webhook"`
+}
+
+type WebhookSyncSpec struct {
+	URL string `json:"url"`
+	// SecretRef points to the shared secret used to sign the requests. It must be in the klum namespace, the key defaults to secret
+	SecretRef SecretKeyReference `json:"secretRef"`
+	// PublicKey is an age (age1...) or ASCII armored PGP public key. The kubeconfig is added to the payload, encrypted, when set
+	// +optional
+	PublicKey string `json:"publicKey,omitempty"`
+	// Retries is the number of times a failed request is retried before giving up. Defaults to 3, at most 10
+	// +optional
+	Retries *int `json:"retries,omitempty"`
+}
+
+// MaxWebhookRetries caps WebhookSyncSpec.Retries, retries wait up to 5 minutes each
+const MaxWebhookRetries = 10
+
+func (w *WebhookSyncSpec) Validate() error {
+	if w.URL == "" || w.SecretRef.Name == "" {
+		return fmt.Errorf("webhook url and secretRef are required")
+	}
+	if w.Retries != nil && (*w.Retries < 0 || *w.Retries > MaxWebhookRetries) {
+		return fmt.Errorf("webhook retries must be between 0 and %d", MaxWebhookRetries)
+	}
+	return nil
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+type UserSyncEmail struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              UserSyncEmailSpec `json:"spec"`
+	Status            UserSyncStatus    `json:"status,omitempty"`
+}
+
+func (u *UserSyncEmail) SyncUser() string {
+	return u.Spec.User
+}
+
+func (u *UserSyncEmail) GetSyncStatus() UserSyncStatus {
+	return u.Status
+}
+
+func (u *UserSyncEmail) SetSyncStatus(status UserSyncStatus) {
+	u.Status = status
+}
+
+type UserSyncEmailSpec struct {
+	User string `json:"user"`
+	// Email is the address the kubeconfig is sent to
+	Email string `json:"email"`
+	// PublicKey is the age (age1...) or ASCII armored PGP public key the attached kubeconfig is encrypted for
+	PublicKey string `json:"publicKey"`
+}
+
+func (e *UserSyncEmailSpec) Validate() error {
+	if e.Email == "" || e.PublicKey == "" {
+		return fmt.Errorf("email and publicKey are required")
+	}
+	// Only a bare address, it is used as is in the To header and the RCPT command
+	address, err := mail.ParseAddress(e.Email)
+	if err != nil || address.Address != e.Email {
+		return fmt.Errorf("email %q is not a valid address", e.Email)
+	}
+	return nil
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+type UserSyncBitbucket struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              UserSyncBitbucketSpec `json:"spec"`
+	Status            UserSyncStatus        `json:"status,omitempty"`
+}
+
+func (u *UserSyncBitbucket) SyncUser() string {
+	return u.Spec.User
+}
+
+func (u *UserSyncBitbucket) GetSyncStatus() UserSyncStatus {
+	return u.Status
+}
+
+func (u *UserSyncBitbucket) SetSyncStatus(status UserSyncStatus) {
+	u.Status = status
+}
+
+type UserSyncBitbucketSpec struct {
+	User      string            `json:"user"`
+	Bitbucket BitbucketSyncSpec `json:"bitbucket"`
+}
+
+type BitbucketSyncSpec struct {
+	// Workspace is the slug or UUID of the workspace owning the repository
+	Workspace  string `json:"workspace"`
+	Repository string `json:"repository"`
+	// Environment is the name of a deployment environment. The kubeconfig is stored as a deployment variable
+	// of the environment when set and as a repository variable otherwise
+	Environment  string `json:"environment,omitempty"`
+	VariableName string `json:"variableName"`
+}
+
+func (b *BitbucketSyncSpec) Validate() error {
+	if b.VariableName != "" && b.Workspace != "" && b.Repository != "" {
+		return nil
+	}
+	return fmt.Errorf("bitbucket workspace, repository and variableName are required")
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+type UserSyncAWSSecret struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              UserSyncAWSSecretSpec `json:"spec"`
+	Status            UserSyncStatus        `json:"status,omitempty"`
+}
+
+func (u *UserSyncAWSSecret) SyncUser() string {
+	return u.Spec.User
+}
+
+func (u *UserSyncAWSSecret) GetSyncStatus() UserSyncStatus {
+	return u.Status
+}
+
+func (u *UserSyncAWSSecret) SetSyncStatus(status UserSyncStatus) {
+	u.Status = status
+}
+
+type UserSyncAWSSecretSpec struct {
+	User string      `json:"user"`
+	AWS  AWSSyncSpec `json:"aws"`
+}
+
+const (
+	AWSServiceSecretsManager = "secretsmanager"
+	AWSServiceSSM            = "ssm"
+)
+
+type AWSSyncSpec struct {
+	// Service is secretsmanager (default) for a Secrets Manager secret or ssm for an SSM SecureString parameter
+	Service string `json:"service,omitempty"`
+	// Name of the secret or parameter
+	Name string `json:"name"`
+	// KMSKeyID is the ID, ARN or alias of the KMS key encrypting the value. The AWS managed key of the service is used if empty
+	KMSKeyID string `json:"kmsKeyId,omitempty"`
+	// RecoveryWindowInDays is how long a deleted Secrets Manager secret can be restored, between 7 and 30 days.
+	// Defaults to 30, 0 deletes the secret immediately. SSM parameters are always deleted immediately.
+	RecoveryWindowInDays *int `json:"recoveryWindowInDays,omitempty"`
+}
+
+func (a *AWSSyncSpec) Validate() error {
+	if a.Name == "" {
+		return fmt.Errorf("aws name is required")
+	}
+	switch a.Service {
+	case "", AWSServiceSecretsManager, AWSServiceSSM:
+	default:
+		return fmt.Errorf("invalid aws service %q, must be %s or %s", a.Service, AWSServiceSecretsManager, AWSServiceSSM)
+	}
+	if w := a.RecoveryWindowInDays; w != nil && *w != 0 && (*w < 7 || *w > 30) {
+		return fmt.Errorf("aws recoveryWindowInDays must be 0 or between 7 and 30")
+	}
+	return nil
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+type UserSyncRemoteSecret struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              UserSyncRemoteSecretSpec `json:"spec"`
+	Status            UserSyncStatus           `json:"status,omitempty"`
+}
+
+func (u *UserSyncRemoteSecret) SyncUser() string {
+	return u.Spec.User
+}
+
+func (u *UserSyncRemoteSecret) GetSyncStatus() UserSyncStatus {
+	return u.Status
+}
+
+func (u *UserSyncRemoteSecret) SetSyncStatus(status UserSyncStatus) {
+	u.Status = status
+}
+
+type UserSyncRemoteSecretSpec struct {
+	User         string               `json:"user"`
+	RemoteSecret RemoteSecretSyncSpec `json:"remoteSecret"`
+}
+
+const (
+	RemoteSecretFormatKubeconfig = "kubeconfig"
+	RemoteSecretFormatArgoCD     = "argocd"
+)
+
+type RemoteSecretSyncSpec struct {
+	// KubeconfigSecretRef references the Secret holding the kubeconfig of the remote cluster.
+	// It must be in the klum namespace, which the namespace defaults to, and the key defaults to "kubeconfig".
+	KubeconfigSecretRef SecretKeyReference `json:"kubeconfigSecretRef"`
+	// Namespace of the Secret in the remote cluster, it must exist
+	Namespace string `json:"namespace"`
+	// Name of the Secret in the remote cluster
+	Name string `json:"name"`
+	// Format is kubeconfig (default) to store the kubeconfig under the kubeconfig key, or argocd
+	// to write an Argo CD cluster Secret for the current context of the kubeconfig
+	Format string `json:"format,omitempty"`
+	// ClusterName is the name of the cluster in Argo CD, it defaults to the current context of the kubeconfig
+	ClusterName string `json:"clusterName,omitempty"`
+	// Labels are added to the Secret, e.g. to match Argo CD projects
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+func (r *RemoteSecretSyncSpec) Validate() error {
+	if r.KubeconfigSecretRef.Name == "" || r.Namespace == "" || r.Name == "" {
+		return fmt.Errorf("remoteSecret kubeconfigSecretRef.name, namespace and name are required")
+	}
+	switch r.Format {
+	case "", RemoteSecretFormatKubeconfig, RemoteSecretFormatArgoCD:
+	default:
+		return fmt.Errorf("invalid remoteSecret format %q, must be %s or %s", r.Format, RemoteSecretFormatKubeconfig, RemoteSecretFormatArgoCD)
+	}
+	return nil
+}
+
+// DeliveryStatus describes the last request made to the target of a sync
+type DeliveryStatus struct {
+	Time       metav1.Time `json:"time"`
+	Event      string      `json:"event,omitempty"`
+	StatusCode int         `json:"statusCode,omitempty"`
+	Attempts   int         `json:"attempts,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// TargetStatus is the outcome of the last upload to one of the targets of a sync
+type TargetStatus struct {
+	Owner       string `json:"owner"`
+	Repository  string `json:"repository,omitempty"`
+	Environment string `json:"environment,omitempty"`
+	// RepositoryID identifies the repository of the target, its name can change
+	RepositoryID int64 `json:"repositoryID,omitempty"`
+	// Hash of the kubeconfig and the settings of the secret last uploaded
+	Hash string `json:"hash,omitempty"`
+	// SecretHashes are the hashes of the secrets last uploaded to the target by name, the split
+	// format creates several secrets
+	SecretHashes map[string]string `json:"secretHashes,omitempty"`
+	// VariableHashes are the hashes of the variables last set in the target by name
+	VariableHashes map[string]string `json:"variableHashes,omitempty"`
+	LastUpload     *metav1.Time      `json:"lastUpload,omitempty"`
+	// UpdatedAt is the time the target reported for the secrets after the last upload, a later
+	// time means a secret was changed outside of klum
+	UpdatedAt *metav1.Time `json:"updatedAt,omitempty"`
+	Error     string       `json:"error,omitempty"`
 }
 
 type UserSyncStatus struct {
 	Conditions []genericcondition.GenericCondition `json:"conditions,omitempty"`
+	// Hash of the kubeconfig last synchronized, empty before the first upload and after the
+	// kubeconfig of a disabled user was removed
+	// +optional
+	Hash string `json:"hash,omitempty"`
+	// LastUpload is the last time the kubeconfig was uploaded, or removed for a disabled user
+	// +optional
+	LastUpload *metav1.Time `json:"lastUpload,omitempty"`
+	// KubeconfigRevision is the resource version of the Kubeconfig last uploaded
+	// +optional
+	KubeconfigRevision string `json:"kubeconfigRevision,omitempty"`
+	// LastError is the error of the last synchronization that failed, cleared once one succeeds
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+	// ObservedGeneration is the generation of the spec last synchronized, changes to the spec
+	// are synchronized even if the kubeconfig didn't change
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// LastDelivery is recorded by targets that report the outcome of their requests
+	// +optional
+	LastDelivery *DeliveryStatus `json:"lastDelivery,omitempty"`
+	// Targets is recorded by providers synchronizing a sync to several targets
+	// +optional
+	Targets []TargetStatus `json:"targets,omitempty"`
+	// LastVerified is the last time the target was checked for drift
+	// +optional
+	LastVerified *metav1.Time `json:"lastVerified,omitempty"`
 }
@@ -74,3 +74,156 @@ func NewUserSyncGithub(namespace, name string, obj UserSyncGithub) *UserSyncGith
 	obj.Namespace = namespace
 	return &obj
 }
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// UserSyncGitlabList is a list of UserSyncGitlab resources
+type UserSyncGitlabList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []UserSyncGitlab `json:"items"`
+}
+
+func NewUserSyncGitlab(namespace, name string, obj UserSyncGitlab) *UserSyncGitlab {
+	obj.APIVersion, obj.Kind = SchemeGroupVersion.WithKind("UserSyncGitlab").ToAPIVersionAndKind()
+	obj.Name = name
+	obj.Namespace = namespace
+	return &obj
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// UserSyncGiteaList is a list of UserSyncGitea resources
+type UserSyncGiteaList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []UserSyncGitea `json:"items"`
+}
+
+func NewUserSyncGitea(namespace, name string, obj UserSyncGitea) *UserSyncGitea {
+	obj.APIVersion, obj.Kind = SchemeGroupVersion.WithKind("UserSyncGitea").ToAPIVersionAndKind()
+	obj.Name = name
+	obj.Namespace = namespace
+	return &obj
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// UserSyncVaultList is a list of UserSyncVault resources
+type UserSyncVaultList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []UserSyncVault `json:"items"`
+}
+
+func NewUserSyncVault(namespace, name string, obj UserSyncVault) *UserSyncVault {
+	obj.APIVersion, obj.Kind = SchemeGroupVersion.WithKind("UserSyncVault").ToAPIVersionAndKind()
+	obj.Name = name
+	obj.Namespace = namespace
+	return &obj
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// UserSyncS3List is a list of UserSyncS3 resources
+type UserSyncS3List struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []UserSyncS3 `json:"items"`
+}
+
+func NewUserSyncS3(namespace, name string, obj UserSyncS3) *UserSyncS3 {
+	obj.APIVersion, obj.Kind = SchemeGroupVersion.WithKind("UserSyncS3").ToAPIVersionAndKind()
+	obj.Name = name
+	obj.Namespace = namespace
+	return &obj
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// UserSyncWebhookList is a list of UserSyncWebhook resources
+type UserSyncWebhookList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []UserSyncWebhook `json:"items"`
+}
+
+func NewUserSyncWebhook(namespace, name string, obj UserSyncWebhook) *UserSyncWebhook {
+	obj.APIVersion, obj.Kind = SchemeGroupVersion.WithKind("UserSyncWebhook").ToAPIVersionAndKind()
+	obj.Name = name
+	obj.Namespace = namespace
+	return &obj
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// UserSyncEmailList is a list of UserSyncEmail resources
+type UserSyncEmailList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []UserSyncEmail `json:"items"`
+}
+
+func NewUserSyncEmail(namespace, name string, obj UserSyncEmail) *UserSyncEmail {
+	obj.APIVersion, obj.Kind = SchemeGroupVersion.WithKind("UserSyncEmail").ToAPIVersionAndKind()
+	obj.Name = name
+	obj.Namespace = namespace
+	return &obj
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// UserSyncBitbucketList is a list of UserSyncBitbucket resources
+type UserSyncBitbucketList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []UserSyncBitbucket `json:"items"`
+}
+
+func NewUserSyncBitbucket(namespace, name string, obj UserSyncBitbucket) *UserSyncBitbucket {
+	obj.APIVersion, obj.Kind = SchemeGroupVersion.WithKind("UserSyncBitbucket").ToAPIVersionAndKind()
+	obj.Name = name
+	obj.Namespace = namespace
+	return &obj
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// UserSyncAWSSecretList is a list of UserSyncAWSSecret resources
+type UserSyncAWSSecretList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []UserSyncAWSSecret `json:"items"`
+}
+
+func NewUserSyncAWSSecret(namespace, name string, obj UserSyncAWSSecret) *UserSyncAWSSecret {
+	obj.APIVersion, obj.Kind = SchemeGroupVersion.WithKind("UserSyncAWSSecret").ToAPIVersionAndKind()
+	obj.Name = name
+	obj.Namespace = namespace
+	return &obj
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// UserSyncRemoteSecretList is a list of UserSyncRemoteSecret resources
+type UserSyncRemoteSecretList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []UserSyncRemoteSecret `json:"items"`
+}
+
+func NewUserSyncRemoteSecret(namespace, name string, obj UserSyncRemoteSecret) *UserSyncRemoteSecret {
+	obj.APIVersion, obj.Kind = SchemeGroupVersion.WithKind("UserSyncRemoteSecret").ToAPIVersionAndKind()
+	obj.Name = name
+	obj.Namespace = namespace
+	return &obj
+}
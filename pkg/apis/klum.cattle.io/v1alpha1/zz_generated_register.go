@@ -28,9 +28,18 @@ import (
 )
 
 var (
-	KubeconfigResourceName     = "kubeconfigs"
-	UserResourceName           = "users"
-	UserSyncGithubResourceName = "usersyncgithubs"
+	KubeconfigResourceName           = "kubeconfigs"
+	UserResourceName                 = "users"
+	UserSyncAWSSecretResourceName    = "usersyncawssecrets"
+	UserSyncBitbucketResourceName    = "usersyncbitbuckets"
+	UserSyncEmailResourceName        = "usersyncemails"
+	UserSyncGiteaResourceName        = "usersyncgiteas"
+	UserSyncGithubResourceName       = "usersyncgithubs"
+	UserSyncGitlabResourceName       = "usersyncgitlabs"
+	UserSyncRemoteSecretResourceName = "usersyncremotesecrets"
+	UserSyncS3ResourceName           = "usersyncs3s"
+	UserSyncVaultResourceName        = "usersyncvaults"
+	UserSyncWebhookResourceName      = "usersyncwebhooks"
 )
 
 // SchemeGroupVersion is group version used to register these objects
@@ -58,8 +67,26 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		&KubeconfigList{},
 		&User{},
 		&UserList{},
+		&UserSyncAWSSecret{},
+		&UserSyncAWSSecretList{},
+		&UserSyncBitbucket{},
+		&UserSyncBitbucketList{},
+		&UserSyncEmail{},
+		&UserSyncEmailList{},
+		&UserSyncGitea{},
+		&UserSyncGiteaList{},
 		&UserSyncGithub{},
 		&UserSyncGithubList{},
+		&UserSyncGitlab{},
+		&UserSyncGitlabList{},
+		&UserSyncRemoteSecret{},
+		&UserSyncRemoteSecretList{},
+		&UserSyncS3{},
+		&UserSyncS3List{},
+		&UserSyncVault{},
+		&UserSyncVaultList{},
+		&UserSyncWebhook{},
+		&UserSyncWebhookList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
@@ -0,0 +1,242 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jadolg/klum/pkg/apiclient"
+	"github.com/jadolg/klum/pkg/sigv4"
+)
+
+const defaultRegion = "us-east-1"
+
+// Config of the AWS credentials used for Secrets Manager and SSM. Endpoint overrides the endpoints
+// of both services, e.g. to use LocalStack.
+type Config struct {
+	Region          string
+	Endpoint        string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+func (c *Config) Enabled() bool {
+	return c.AccessKeyID != "" && c.SecretAccessKey != ""
+}
+
+type client struct {
+	cfg Config
+	// service is the signing name of the API, secretsmanager or ssm
+	service string
+	// targetPrefix is prepended to the operations in the X-Amz-Target header
+	targetPrefix string
+	endpoint     string
+	httpClient   *http.Client
+	now          func() time.Time
+}
+
+func newClient(cfg Config, service, targetPrefix string) (*client, error) {
+	if !cfg.Enabled() {
+		return nil, fmt.Errorf("insufficient information provided. AWS client can't be created")
+	}
+	if cfg.Region == "" {
+		cfg.Region = defaultRegion
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.%s.amazonaws.com", service, cfg.Region)
+	}
+	return &client{
+		cfg:          cfg,
+		service:      service,
+		targetPrefix: targetPrefix,
+		endpoint:     strings.TrimSuffix(endpoint, "/") + "/",
+		httpClient:   apiclient.NewHTTPClient(),
+		now:          time.Now,
+	}, nil
+}
+
+func newSecretsManagerClient(cfg Config) (*client, error) {
+	return newClient(cfg, "secretsmanager", "secretsmanager.")
+}
+
+func newSSMClient(cfg Config) (*client, error) {
+	return newClient(cfg, "ssm", "AmazonSSM.")
+}
+
+// do calls operation with the AWS JSON 1.1 protocol used by Secrets Manager and SSM
+func (c *client) do(ctx context.Context, operation string, in interface{}, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", c.targetPrefix+operation)
+	if c.cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.cfg.SessionToken)
+	}
+	sigv4.Sign(req, body, sigv4.Credentials{
+		Region:          c.cfg.Region,
+		Service:         c.service,
+		AccessKeyID:     c.cfg.AccessKeyID,
+		SecretAccessKey: c.cfg.SecretAccessKey,
+	}, c.now())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return parseError(resp.StatusCode, data)
+	}
+
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
+
+func parseError(statusCode int, data []byte) error {
+	body := struct {
+		Type         string `json:"__type"`
+		Message      string `json:"message"`
+		MessageUpper string `json:"Message"`
+	}{}
+	_ = json.Unmarshal(data, &body)
+
+	apiErr := &apiclient.Error{API: "aws", StatusCode: statusCode, Type: body.Type, Message: body.Message}
+	// Types may be namespaced, e.g. com.amazonaws.secretsmanager#ResourceNotFoundException
+	if i := strings.LastIndex(apiErr.Type, "#"); i >= 0 {
+		apiErr.Type = apiErr.Type[i+1:]
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = body.MessageUpper
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = strings.TrimSpace(string(data))
+	}
+	return apiErr
+}
+
+type tag struct {
+	Key   string `json:"Key"`
+	Value string `json:"Value"`
+}
+
+// secret is the subset of the DescribeSecret response klum uses
+type secret struct {
+	Name string `json:"Name"`
+	// DeletedDate is set while the secret is scheduled for deletion
+	DeletedDate *float64 `json:"DeletedDate,omitempty"`
+}
+
+// describeSecret returns the secret called name or nil if it doesn't exist
+func (c *client) describeSecret(ctx context.Context, name string) (*secret, error) {
+	out := &secret{}
+	err := c.do(ctx, "DescribeSecret", map[string]string{"SecretId": name}, out)
+	if apiclient.HasType(err, "ResourceNotFoundException") {
+		return nil, nil
+	}
+	return out, err
+}
+
+func (c *client) createSecret(ctx context.Context, name, value, kmsKeyID string, tags []tag) error {
+	in := map[string]interface{}{
+		"Name":         name,
+		"SecretString": value,
+		"Tags":         tags,
+	}
+	if kmsKeyID != "" {
+		in["KmsKeyId"] = kmsKeyID
+	}
+	return c.do(ctx, "CreateSecret", in, nil)
+}
+
+// updateSecret stores a new version of the value and switches the KMS key if kmsKeyID is set
+func (c *client) updateSecret(ctx context.Context, name, value, kmsKeyID string) error {
+	in := map[string]interface{}{
+		"SecretId":     name,
+		"SecretString": value,
+	}
+	if kmsKeyID != "" {
+		in["KmsKeyId"] = kmsKeyID
+	}
+	return c.do(ctx, "UpdateSecret", in, nil)
+}
+
+func (c *client) tagSecret(ctx context.Context, name string, tags []tag) error {
+	return c.do(ctx, "TagResource", map[string]interface{}{
+		"SecretId": name,
+		"Tags":     tags,
+	}, nil)
+}
+
+func (c *client) restoreSecret(ctx context.Context, name string) error {
+	return c.do(ctx, "RestoreSecret", map[string]string{"SecretId": name}, nil)
+}
+
+// deleteSecret schedules the deletion of the secret, recoveryWindowInDays 0 deletes it immediately
+// and nil uses the default recovery window of 30 days
+func (c *client) deleteSecret(ctx context.Context, name string, recoveryWindowInDays *int) error {
+	in := map[string]interface{}{"SecretId": name}
+	if recoveryWindowInDays != nil {
+		if *recoveryWindowInDays == 0 {
+			in["ForceDeleteWithoutRecovery"] = true
+		} else {
+			in["RecoveryWindowInDays"] = *recoveryWindowInDays
+		}
+	}
+	return c.do(ctx, "DeleteSecret", in, nil)
+}
+
+// putParameter creates or overwrites a SecureString parameter. Intelligent-Tiering switches to the
+// advanced tier for values over the 4KB of the standard tier.
+func (c *client) putParameter(ctx context.Context, name, value, kmsKeyID string) error {
+	in := map[string]interface{}{
+		"Name":      name,
+		"Value":     value,
+		"Type":      "SecureString",
+		"Overwrite": true,
+		"Tier":      "Intelligent-Tiering",
+	}
+	if kmsKeyID != "" {
+		in["KeyId"] = kmsKeyID
+	}
+	return c.do(ctx, "PutParameter", in, nil)
+}
+
+// tagParameter adds or overwrites tags, PutParameter doesn't accept tags together with Overwrite
+func (c *client) tagParameter(ctx context.Context, name string, tags []tag) error {
+	return c.do(ctx, "AddTagsToResource", map[string]interface{}{
+		"ResourceType": "Parameter",
+		"ResourceId":   name,
+		"Tags":         tags,
+	}, nil)
+}
+
+// parameterExists reports whether the parameter exists without decrypting its value
+func (c *client) parameterExists(ctx context.Context, name string) (bool, error) {
+	err := c.do(ctx, "GetParameter", map[string]interface{}{"Name": name, "WithDecryption": false}, nil)
+	if apiclient.HasType(err, "ParameterNotFound") {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (c *client) deleteParameter(ctx context.Context, name string) error {
+	return c.do(ctx, "DeleteParameter", map[string]string{"Name": name}, nil)
+}
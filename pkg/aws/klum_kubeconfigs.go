@@ -0,0 +1,177 @@
+package aws
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/jadolg/klum/pkg/apiclient"
+	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	"github.com/jadolg/klum/pkg/usersync"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	userTag = "klum-user"
+	hashTag = "klum-hash"
+)
+
+// Provider synchronizes kubeconfigs to AWS Secrets Manager secrets and SSM SecureString parameters
+type Provider struct {
+	cfg Config
+}
+
+func NewProvider(cfg Config) *Provider {
+	return &Provider{cfg: cfg}
+}
+
+func (p *Provider) Name() string {
+	return "aws"
+}
+
+func (p *Provider) Enabled() bool {
+	return p.cfg.Enabled()
+}
+
+func (p *Provider) Upload(ctx context.Context, sync usersync.Object, payload []byte) error {
+	userSync, err := asUserSyncAWSSecret(sync)
+	if err != nil {
+		return err
+	}
+	awsSync := userSync.Spec.AWS
+	if err := awsSync.Validate(); err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"name":    awsSync.Name,
+		"user":    userSync.Spec.User,
+		"service": service(&awsSync),
+	}).Info("Adding secret")
+
+	tags := []tag{
+		{Key: userTag, Value: userSync.Spec.User},
+		{Key: hashTag, Value: fmt.Sprintf("%x", sha256.Sum256(payload))},
+	}
+
+	if service(&awsSync) == klum.AWSServiceSSM {
+		client, err := newSSMClient(p.cfg)
+		if err != nil {
+			return err
+		}
+		if err := client.putParameter(ctx, awsSync.Name, string(payload), awsSync.KMSKeyID); err != nil {
+			return err
+		}
+		return client.tagParameter(ctx, awsSync.Name, tags)
+	}
+
+	client, err := newSecretsManagerClient(p.cfg)
+	if err != nil {
+		return err
+	}
+	existing, err := client.describeSecret(ctx, awsSync.Name)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return client.createSecret(ctx, awsSync.Name, string(payload), awsSync.KMSKeyID, tags)
+	}
+	if existing.DeletedDate != nil {
+		// The secret is still in its recovery window, e.g. because the sync was deleted and created again
+		if err := client.restoreSecret(ctx, awsSync.Name); err != nil {
+			return err
+		}
+	}
+	if err := client.updateSecret(ctx, awsSync.Name, string(payload), awsSync.KMSKeyID); err != nil {
+		return err
+	}
+	return client.tagSecret(ctx, awsSync.Name, tags)
+}
+
+func (p *Provider) Delete(ctx context.Context, sync usersync.Object) error {
+	userSync, err := asUserSyncAWSSecret(sync)
+	if err != nil {
+		return err
+	}
+	awsSync := userSync.Spec.AWS
+	if err := awsSync.Validate(); err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"name":    awsSync.Name,
+		"user":    userSync.Spec.User,
+		"service": service(&awsSync),
+	}).Info("Deleting secret")
+
+	if service(&awsSync) == klum.AWSServiceSSM {
+		client, err := newSSMClient(p.cfg)
+		if err != nil {
+			return err
+		}
+		err = client.deleteParameter(ctx, awsSync.Name)
+		if apiclient.HasType(err, "ParameterNotFound") {
+			return nil
+		}
+		return err
+	}
+
+	client, err := newSecretsManagerClient(p.cfg)
+	if err != nil {
+		return err
+	}
+	existing, err := client.describeSecret(ctx, awsSync.Name)
+	if err != nil || existing == nil || existing.DeletedDate != nil {
+		return err
+	}
+	err = client.deleteSecret(ctx, awsSync.Name, awsSync.RecoveryWindowInDays)
+	if apiclient.HasType(err, "ResourceNotFoundException") {
+		return nil
+	}
+	return err
+}
+
+// Verify reports whether the secret or parameter exists. Secrets scheduled for deletion don't.
+func (p *Provider) Verify(ctx context.Context, sync usersync.Object) (bool, error) {
+	userSync, err := asUserSyncAWSSecret(sync)
+	if err != nil {
+		return false, err
+	}
+	awsSync := userSync.Spec.AWS
+	if err := awsSync.Validate(); err != nil {
+		return false, err
+	}
+
+	if service(&awsSync) == klum.AWSServiceSSM {
+		client, err := newSSMClient(p.cfg)
+		if err != nil {
+			return false, err
+		}
+		return client.parameterExists(ctx, awsSync.Name)
+	}
+
+	client, err := newSecretsManagerClient(p.cfg)
+	if err != nil {
+		return false, err
+	}
+	existing, err := client.describeSecret(ctx, awsSync.Name)
+	if err != nil {
+		return false, err
+	}
+	return existing != nil && existing.DeletedDate == nil, nil
+}
+
+func service(spec *klum.AWSSyncSpec) string {
+	if spec.Service == "" {
+		return klum.AWSServiceSecretsManager
+	}
+	return spec.Service
+}
+
+func asUserSyncAWSSecret(sync usersync.Object) (*klum.UserSyncAWSSecret, error) {
+	userSync, ok := sync.(*klum.UserSyncAWSSecret)
+	if !ok {
+		return nil, fmt.Errorf("aws provider can't synchronize %T", sync)
+	}
+	return userSync, nil
+}
@@ -0,0 +1,253 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jadolg/klum/pkg/apiclient"
+	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type entry struct {
+	value    string
+	kmsKeyID string
+	tags     map[string]string
+	deleted  bool
+}
+
+// fakeAWS implements the Secrets Manager and SSM operations used by the provider, like LocalStack does on a single endpoint
+type fakeAWS struct {
+	lock       sync.Mutex
+	operations []string
+	requests   []map[string]interface{}
+	secrets    map[string]*entry
+	parameters map[string]*entry
+}
+
+func (f *fakeAWS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	operation := r.Header.Get("X-Amz-Target")
+	service := "secretsmanager"
+	if strings.HasPrefix(operation, "AmazonSSM.") {
+		service = "ssm"
+	}
+	if !strings.Contains(r.Header.Get("Authorization"), "/us-east-1/"+service+"/aws4_request") ||
+		r.Header.Get("X-Amz-Security-Token") != "session" {
+		writeError(w, "UnrecognizedClientException")
+		return
+	}
+
+	in := map[string]interface{}{}
+	_ = json.NewDecoder(r.Body).Decode(&in)
+	f.operations = append(f.operations, operation)
+	f.requests = append(f.requests, in)
+
+	str := func(key string) string {
+		s, _ := in[key].(string)
+		return s
+	}
+	tags := func(e *entry) {
+		list, _ := in["Tags"].([]interface{})
+		for _, t := range list {
+			t := t.(map[string]interface{})
+			e.tags[t["Key"].(string)] = t["Value"].(string)
+		}
+	}
+
+	switch operation {
+	case "secretsmanager.DescribeSecret":
+		s, ok := f.secrets[str("SecretId")]
+		if !ok {
+			writeError(w, "ResourceNotFoundException")
+			return
+		}
+		out := map[string]interface{}{"Name": str("SecretId")}
+		if s.deleted {
+			out["DeletedDate"] = 1.7e9
+		}
+		_ = json.NewEncoder(w).Encode(out)
+	case "secretsmanager.CreateSecret":
+		e := &entry{value: str("SecretString"), kmsKeyID: str("KmsKeyId"), tags: map[string]string{}}
+		tags(e)
+		f.secrets[str("Name")] = e
+	case "secretsmanager.UpdateSecret":
+		s := f.secrets[str("SecretId")]
+		if s.deleted {
+			writeError(w, "InvalidRequestException")
+			return
+		}
+		s.value = str("SecretString")
+		if str("KmsKeyId") != "" {
+			s.kmsKeyID = str("KmsKeyId")
+		}
+	case "secretsmanager.TagResource":
+		tags(f.secrets[str("SecretId")])
+	case "secretsmanager.RestoreSecret":
+		f.secrets[str("SecretId")].deleted = false
+	case "secretsmanager.DeleteSecret":
+		if in["ForceDeleteWithoutRecovery"] == true {
+			delete(f.secrets, str("SecretId"))
+		} else {
+			f.secrets[str("SecretId")].deleted = true
+		}
+	case "AmazonSSM.PutParameter":
+		e, ok := f.parameters[str("Name")]
+		if !ok {
+			e = &entry{tags: map[string]string{}}
+			f.parameters[str("Name")] = e
+		}
+		e.value = str("Value")
+		e.kmsKeyID = str("KeyId")
+	case "AmazonSSM.AddTagsToResource":
+		tags(f.parameters[str("ResourceId")])
+	case "AmazonSSM.GetParameter", "AmazonSSM.DeleteParameter":
+		if _, ok := f.parameters[str("Name")]; !ok {
+			writeError(w, "ParameterNotFound")
+			return
+		}
+		if operation == "AmazonSSM.DeleteParameter" {
+			delete(f.parameters, str("Name"))
+		}
+	default:
+		writeError(w, "UnknownOperationException")
+		return
+	}
+	_, _ = w.Write([]byte("{}"))
+}
+
+func writeError(w http.ResponseWriter, errorType string) {
+	w.WriteHeader(http.StatusBadRequest)
+	_, _ = w.Write([]byte(`{"__type":"com.amazonaws#` + errorType + `","message":"` + errorType + `"}`))
+}
+
+func newTestProvider(t *testing.T) (*Provider, *fakeAWS) {
+	fake := &fakeAWS{secrets: map[string]*entry{}, parameters: map[string]*entry{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return NewProvider(Config{
+		Endpoint:        server.URL,
+		AccessKeyID:     "access",
+		SecretAccessKey: "secret",
+		SessionToken:    "session",
+	}), fake
+}
+
+func newTestSync(spec klum.AWSSyncSpec) *klum.UserSyncAWSSecret {
+	return &klum.UserSyncAWSSecret{
+		ObjectMeta: metav1.ObjectMeta{Name: "darren"},
+		Spec:       klum.UserSyncAWSSecretSpec{User: "darren", AWS: spec},
+	}
+}
+
+func TestSecretsManager(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	sync := newTestSync(klum.AWSSyncSpec{Name: "klum/darren", KMSKeyID: "alias/klum"})
+
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	secret := fake.secrets["klum/darren"]
+	require.NotNil(t, secret)
+	assert.Equal(t, "kubeconfig", secret.value)
+	assert.Equal(t, "alias/klum", secret.kmsKeyID)
+	assert.Equal(t, "darren", secret.tags["klum-user"])
+	hash := secret.tags["klum-hash"]
+	assert.Len(t, hash, 64)
+
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("rotated")))
+	assert.Equal(t, "rotated", secret.value)
+	assert.NotEqual(t, hash, secret.tags["klum-hash"])
+
+	exists, err := provider.Verify(context.Background(), sync)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	require.NoError(t, provider.Delete(context.Background(), sync))
+	assert.True(t, secret.deleted, "the secret can be recovered")
+	assert.NotContains(t, fake.requests[len(fake.requests)-1], "RecoveryWindowInDays", "AWS default recovery window")
+
+	exists, err = provider.Verify(context.Background(), sync)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	require.NoError(t, provider.Delete(context.Background(), sync), "deleting a scheduled secret does nothing")
+	assert.Equal(t, "secretsmanager.DescribeSecret", fake.operations[len(fake.operations)-1])
+
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("again")))
+	assert.False(t, secret.deleted, "the secret is restored")
+	assert.Equal(t, "again", secret.value)
+}
+
+func TestSecretsManagerRecoveryWindow(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	window := 7
+	sync := newTestSync(klum.AWSSyncSpec{Name: "klum/darren", RecoveryWindowInDays: &window})
+
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	require.NoError(t, provider.Delete(context.Background(), sync))
+	assert.Equal(t, float64(7), fake.requests[len(fake.requests)-1]["RecoveryWindowInDays"])
+
+	window = 0
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	require.NoError(t, provider.Delete(context.Background(), sync))
+	assert.NotContains(t, fake.secrets, "klum/darren")
+
+	require.NoError(t, provider.Delete(context.Background(), sync), "deleting a missing secret succeeds")
+}
+
+func TestSSM(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	sync := newTestSync(klum.AWSSyncSpec{Service: klum.AWSServiceSSM, Name: "/klum/darren", KMSKeyID: "alias/klum"})
+
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	parameter := fake.parameters["/klum/darren"]
+	require.NotNil(t, parameter)
+	assert.Equal(t, "kubeconfig", parameter.value)
+	assert.Equal(t, "alias/klum", parameter.kmsKeyID)
+	assert.Equal(t, "darren", parameter.tags["klum-user"])
+	assert.Len(t, parameter.tags["klum-hash"], 64)
+	assert.Equal(t, "SecureString", fake.requests[0]["Type"])
+	assert.Equal(t, true, fake.requests[0]["Overwrite"])
+
+	exists, err := provider.Verify(context.Background(), sync)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	require.NoError(t, provider.Delete(context.Background(), sync))
+	assert.Empty(t, fake.parameters)
+
+	exists, err = provider.Verify(context.Background(), sync)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	require.NoError(t, provider.Delete(context.Background(), sync), "deleting a missing parameter succeeds")
+}
+
+func TestErrors(t *testing.T) {
+	provider, _ := newTestProvider(t)
+	provider.cfg.SessionToken = ""
+	sync := newTestSync(klum.AWSSyncSpec{Name: "klum/darren"})
+
+	err := provider.Upload(context.Background(), sync, []byte("kubeconfig"))
+	require.Error(t, err)
+	assert.True(t, apiclient.HasType(err, "UnrecognizedClientException"))
+}
+
+func TestValidate(t *testing.T) {
+	window := 3
+	for _, spec := range []klum.AWSSyncSpec{
+		{},
+		{Name: "klum/darren", Service: "kms"},
+		{Name: "klum/darren", RecoveryWindowInDays: &window},
+	} {
+		assert.Error(t, spec.Validate())
+	}
+}
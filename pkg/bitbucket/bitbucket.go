@@ -0,0 +1,165 @@
+package bitbucket
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jadolg/klum/pkg/apiclient"
+)
+
+const (
+	defaultBaseURL = "https://api.bitbucket.org/2.0"
+	pageLength     = 100
+)
+
+type Config struct {
+	BaseURL string
+	// Username is used with Token for basic authentication (app passwords and API tokens).
+	// Token is sent as a bearer token (repository and workspace access tokens) when Username is empty.
+	Username string
+	Token    string
+}
+
+func (c *Config) Enabled() bool {
+	return c.Token != ""
+}
+
+// Validate rejects Bitbucket Data Center URLs. Only Bitbucket Cloud has Pipelines variables,
+// Data Center builds run in external CI servers that klum doesn't know about.
+func (c *Config) Validate() error {
+	if c.BaseURL == "" {
+		return nil
+	}
+	u, err := url.Parse(c.BaseURL)
+	if err != nil {
+		return err
+	}
+	if strings.Contains(u.Path, "/rest/api/") {
+		return fmt.Errorf("bitbucket url %s looks like a Bitbucket Data Center API, only Bitbucket Cloud is supported", c.BaseURL)
+	}
+	return nil
+}
+
+// variable is a Pipelines repository or deployment variable as exposed by the Bitbucket REST API
+type variable struct {
+	UUID    string `json:"uuid,omitempty"`
+	Key     string `json:"key"`
+	Value   string `json:"value,omitempty"`
+	Secured bool   `json:"secured"`
+}
+
+type environment struct {
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
+}
+
+// page is a page of a paginated Bitbucket collection, Next is the absolute URL of the following page
+type page[T any] struct {
+	Values []T    `json:"values"`
+	Next   string `json:"next,omitempty"`
+}
+
+type client struct {
+	*apiclient.Client
+}
+
+func newBitbucketClient(cfg Config) (*client, error) {
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("insufficient information provided. Bitbucket client can't be created")
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	return &client{apiclient.NewClient("bitbucket", baseURL, func(req *http.Request) {
+		if cfg.Username != "" {
+			req.SetBasicAuth(cfg.Username, cfg.Token)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+cfg.Token)
+		}
+	})}, nil
+}
+
+// variablesPath returns the path of the repository variables, or of the deployment variables of
+// the environment with environmentUUID if it is set
+func variablesPath(workspace, repository, environmentUUID string) string {
+	path := fmt.Sprintf("/repositories/%s/%s", url.PathEscape(workspace), url.PathEscape(repository))
+	if environmentUUID == "" {
+		return path + "/pipelines_config/variables"
+	}
+	return path + "/deployments_config/environments/" + url.PathEscape(environmentUUID) + "/variables"
+}
+
+// find returns the first element of the collection at path matching match, or nil
+func find[T any](ctx context.Context, c *client, path string, match func(*T) bool) (*T, error) {
+	next := path + "?" + url.Values{"pagelen": {fmt.Sprint(pageLength)}}.Encode()
+	for next != "" {
+		result := &page[T]{}
+		if err := c.Do(ctx, http.MethodGet, next, nil, result); err != nil {
+			return nil, err
+		}
+		for i := range result.Values {
+			if match(&result.Values[i]) {
+				return &result.Values[i], nil
+			}
+		}
+		next = result.Next
+	}
+	return nil, nil
+}
+
+// environmentUUID returns the UUID of the deployment environment called name
+func (c *client) environmentUUID(ctx context.Context, workspace, repository, name string) (string, error) {
+	path := fmt.Sprintf("/repositories/%s/%s/environments", url.PathEscape(workspace), url.PathEscape(repository))
+	env, err := find(ctx, c, path, func(e *environment) bool {
+		return e.Name == name
+	})
+	if err != nil {
+		return "", err
+	}
+	if env == nil {
+		return "", &apiclient.Error{
+			API:        "bitbucket",
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("environment %s not found in %s/%s", name, workspace, repository),
+		}
+	}
+	return env.UUID, nil
+}
+
+// getVariable returns the variable called key or nil if it doesn't exist. Values of secured variables are never returned.
+func (c *client) getVariable(ctx context.Context, workspace, repository, environmentUUID, key string) (*variable, error) {
+	return find(ctx, c, variablesPath(workspace, repository, environmentUUID), func(v *variable) bool {
+		return v.Key == key
+	})
+}
+
+// createOrUpdateVariable updates the variable with the key of v and creates it if it doesn't exist yet
+func (c *client) createOrUpdateVariable(ctx context.Context, workspace, repository, environmentUUID string, v *variable) error {
+	existing, err := c.getVariable(ctx, workspace, repository, environmentUUID, v.Key)
+	if err != nil {
+		return err
+	}
+	path := variablesPath(workspace, repository, environmentUUID)
+	if existing == nil {
+		return c.Do(ctx, http.MethodPost, path, v, nil)
+	}
+	update := *v
+	update.UUID = existing.UUID
+	return c.Do(ctx, http.MethodPut, path+"/"+url.PathEscape(existing.UUID), &update, nil)
+}
+
+// deleteVariable deletes the variable called key, it does nothing if it doesn't exist
+func (c *client) deleteVariable(ctx context.Context, workspace, repository, environmentUUID, key string) error {
+	existing, err := c.getVariable(ctx, workspace, repository, environmentUUID, key)
+	if err != nil || existing == nil {
+		return err
+	}
+	return c.Do(ctx, http.MethodDelete, variablesPath(workspace, repository, environmentUUID)+"/"+url.PathEscape(existing.UUID), nil, nil)
+}
@@ -0,0 +1,147 @@
+package bitbucket
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/jadolg/klum/pkg/apiclient"
+	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	"github.com/jadolg/klum/pkg/usersync"
+	log "github.com/sirupsen/logrus"
+)
+
+// Provider synchronizes kubeconfigs to Bitbucket Cloud Pipelines variables
+type Provider struct {
+	cfg Config
+}
+
+func NewProvider(cfg Config) *Provider {
+	return &Provider{cfg: cfg}
+}
+
+func (p *Provider) Name() string {
+	return "bitbucket"
+}
+
+func (p *Provider) Enabled() bool {
+	return p.cfg.Enabled()
+}
+
+// Upload stores the kubeconfig base64 encoded in a secured variable, Pipelines variables can't hold multiple lines
+func (p *Provider) Upload(ctx context.Context, sync usersync.Object, payload []byte) error {
+	userSync, err := asUserSyncBitbucket(sync)
+	if err != nil {
+		return err
+	}
+	bitbucketSync := userSync.Spec.Bitbucket
+	if err := bitbucketSync.Validate(); err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"variable":    bitbucketSync.VariableName,
+		"user":        userSync.Spec.User,
+		"workspace":   bitbucketSync.Workspace,
+		"repository":  bitbucketSync.Repository,
+		"environment": bitbucketSync.Environment,
+	}).Info("Adding variable")
+
+	client, err := newBitbucketClient(p.cfg)
+	if err != nil {
+		return err
+	}
+
+	environmentUUID, err := p.environmentUUID(ctx, client, &bitbucketSync)
+	if err != nil {
+		return err
+	}
+
+	return client.createOrUpdateVariable(ctx, bitbucketSync.Workspace, bitbucketSync.Repository, environmentUUID, &variable{
+		Key:     bitbucketSync.VariableName,
+		Value:   base64.StdEncoding.EncodeToString(payload),
+		Secured: true,
+	})
+}
+
+func (p *Provider) Delete(ctx context.Context, sync usersync.Object) error {
+	userSync, err := asUserSyncBitbucket(sync)
+	if err != nil {
+		return err
+	}
+	bitbucketSync := userSync.Spec.Bitbucket
+	if err := bitbucketSync.Validate(); err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"variable":    bitbucketSync.VariableName,
+		"user":        userSync.Spec.User,
+		"workspace":   bitbucketSync.Workspace,
+		"repository":  bitbucketSync.Repository,
+		"environment": bitbucketSync.Environment,
+	}).Info("Deleting variable")
+
+	client, err := newBitbucketClient(p.cfg)
+	if err != nil {
+		return err
+	}
+
+	environmentUUID, err := p.environmentUUID(ctx, client, &bitbucketSync)
+	if apiclient.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	err = client.deleteVariable(ctx, bitbucketSync.Workspace, bitbucketSync.Repository, environmentUUID, bitbucketSync.VariableName)
+	if apiclient.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func (p *Provider) Verify(ctx context.Context, sync usersync.Object) (bool, error) {
+	userSync, err := asUserSyncBitbucket(sync)
+	if err != nil {
+		return false, err
+	}
+	bitbucketSync := userSync.Spec.Bitbucket
+	if err := bitbucketSync.Validate(); err != nil {
+		return false, err
+	}
+
+	client, err := newBitbucketClient(p.cfg)
+	if err != nil {
+		return false, err
+	}
+
+	environmentUUID, err := p.environmentUUID(ctx, client, &bitbucketSync)
+	if apiclient.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	v, err := client.getVariable(ctx, bitbucketSync.Workspace, bitbucketSync.Repository, environmentUUID, bitbucketSync.VariableName)
+	if err != nil {
+		return false, err
+	}
+	return v != nil, nil
+}
+
+// environmentUUID returns the UUID of the environment of spec, or an empty string for repository variables
+func (p *Provider) environmentUUID(ctx context.Context, client *client, spec *klum.BitbucketSyncSpec) (string, error) {
+	if spec.Environment == "" {
+		return "", nil
+	}
+	return client.environmentUUID(ctx, spec.Workspace, spec.Repository, spec.Environment)
+}
+
+func asUserSyncBitbucket(sync usersync.Object) (*klum.UserSyncBitbucket, error) {
+	userSync, ok := sync.(*klum.UserSyncBitbucket)
+	if !ok {
+		return nil, fmt.Errorf("bitbucket provider can't synchronize %T", sync)
+	}
+	return userSync, nil
+}
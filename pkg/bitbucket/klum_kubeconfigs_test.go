@@ -0,0 +1,228 @@
+package bitbucket
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	repositoryPath  = "/repositories/team/app"
+	environmentUUID = "{5a4b5c6d-0000-0000-0000-000000000001}"
+)
+
+type request struct {
+	method string
+	path   string
+}
+
+// fakeBitbucket stores variables by collection path and returns one item per page to exercise pagination
+type fakeBitbucket struct {
+	lock      sync.Mutex
+	url       string
+	requests  []request
+	variables map[string][]variable
+	nextUUID  int
+}
+
+func (f *fakeBitbucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	path := r.URL.EscapedPath()
+	f.requests = append(f.requests, request{method: r.Method, path: path})
+
+	if path == repositoryPath+"/environments" && r.Method == http.MethodGet {
+		f.writePage(w, r, []environment{{UUID: "{other}", Name: "Staging"}, {UUID: environmentUUID, Name: "Production"}})
+		return
+	}
+
+	collection, uuid := path, ""
+	if !strings.HasSuffix(path, "/variables") {
+		i := strings.LastIndex(path, "/")
+		collection, uuid = path[:i], path[i+1:]
+	}
+	if collection != repositoryPath+"/pipelines_config/variables" &&
+		collection != repositoryPath+"/deployments_config/environments/"+escape(environmentUUID)+"/variables" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		f.writePage(w, r, f.variables[collection])
+	case http.MethodPost:
+		v := variable{}
+		_ = json.NewDecoder(r.Body).Decode(&v)
+		f.nextUUID++
+		v.UUID = fmt.Sprintf("{%d}", f.nextUUID)
+		f.variables[collection] = append(f.variables[collection], v)
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodPut, http.MethodDelete:
+		for i, v := range f.variables[collection] {
+			if escape(v.UUID) != uuid {
+				continue
+			}
+			if r.Method == http.MethodDelete {
+				f.variables[collection] = append(f.variables[collection][:i], f.variables[collection][i+1:]...)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			update := variable{}
+			_ = json.NewDecoder(r.Body).Decode(&update)
+			f.variables[collection][i] = update
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeBitbucket) writePage(w http.ResponseWriter, r *http.Request, values interface{}) {
+	data, _ := json.Marshal(values)
+	var items []json.RawMessage
+	_ = json.Unmarshal(data, &items)
+
+	index := 0
+	_, _ = fmt.Sscan(r.URL.Query().Get("page"), &index)
+	result := map[string]interface{}{"values": items[min(index, len(items)):min(index+1, len(items))]}
+	if index+1 < len(items) {
+		result["next"] = fmt.Sprintf("%s%s?pagelen=1&page=%d", f.url, r.URL.Path, index+1)
+	}
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+func escape(uuid string) string {
+	return strings.NewReplacer("{", "%7B", "}", "%7D").Replace(uuid)
+}
+
+func newTestProvider(t *testing.T) (*Provider, *fakeBitbucket) {
+	fake := &fakeBitbucket{variables: map[string][]variable{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	fake.url = server.URL
+	return NewProvider(Config{BaseURL: server.URL, Token: "token"}), fake
+}
+
+func newTestSync(spec klum.BitbucketSyncSpec) *klum.UserSyncBitbucket {
+	return &klum.UserSyncBitbucket{
+		ObjectMeta: metav1.ObjectMeta{Name: "darren"},
+		Spec:       klum.UserSyncBitbucketSpec{User: "darren", Bitbucket: spec},
+	}
+}
+
+func TestRepositoryVariable(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	sync := newTestSync(klum.BitbucketSyncSpec{Workspace: "team", Repository: "app", VariableName: "KUBECONFIG"})
+	collection := repositoryPath + "/pipelines_config/variables"
+	fake.variables[collection] = []variable{{UUID: "{other}", Key: "OTHER"}}
+
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	require.Len(t, fake.variables[collection], 2)
+	created := fake.variables[collection][1]
+	assert.Equal(t, "KUBECONFIG", created.Key)
+	assert.True(t, created.Secured)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("kubeconfig")), created.Value)
+
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("rotated")))
+	require.Len(t, fake.variables[collection], 2, "the existing variable is updated")
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("rotated")), fake.variables[collection][1].Value)
+	assert.Equal(t, created.UUID, fake.variables[collection][1].UUID)
+
+	exists, err := provider.Verify(context.Background(), sync)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	require.NoError(t, provider.Delete(context.Background(), sync))
+	assert.Equal(t, []variable{{UUID: "{other}", Key: "OTHER"}}, fake.variables[collection])
+
+	exists, err = provider.Verify(context.Background(), sync)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	require.NoError(t, provider.Delete(context.Background(), sync), "deleting a missing variable succeeds")
+}
+
+func TestDeploymentVariable(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	sync := newTestSync(klum.BitbucketSyncSpec{
+		Workspace:    "team",
+		Repository:   "app",
+		Environment:  "Production",
+		VariableName: "KUBECONFIG",
+	})
+
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	collection := repositoryPath + "/deployments_config/environments/" + escape(environmentUUID) + "/variables"
+	require.Len(t, fake.variables[collection], 1)
+	assert.Empty(t, fake.variables[repositoryPath+"/pipelines_config/variables"])
+
+	require.NoError(t, provider.Delete(context.Background(), sync))
+	assert.Empty(t, fake.variables[collection])
+}
+
+func TestMissingEnvironment(t *testing.T) {
+	provider, _ := newTestProvider(t)
+	sync := newTestSync(klum.BitbucketSyncSpec{
+		Workspace:    "team",
+		Repository:   "app",
+		Environment:  "Test",
+		VariableName: "KUBECONFIG",
+	})
+
+	assert.Error(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	assert.NoError(t, provider.Delete(context.Background(), sync), "a removed environment doesn't block deletion")
+	exists, err := provider.Verify(context.Background(), sync)
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestBasicAuth(t *testing.T) {
+	var username, password string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, _ = r.BasicAuth()
+		_, _ = w.Write([]byte(`{"values":[]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	provider := NewProvider(Config{BaseURL: server.URL, Username: "darren", Token: "app-password"})
+	sync := newTestSync(klum.BitbucketSyncSpec{Workspace: "team", Repository: "app", VariableName: "KUBECONFIG"})
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	assert.Equal(t, "darren", username)
+	assert.Equal(t, "app-password", password)
+}
+
+func TestValidate(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	sync := newTestSync(klum.BitbucketSyncSpec{Workspace: "team", VariableName: "KUBECONFIG"})
+
+	assert.Error(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	assert.Error(t, provider.Delete(context.Background(), sync))
+	assert.Empty(t, fake.requests)
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, (&Config{Token: "token"}).Validate())
+	assert.NoError(t, (&Config{BaseURL: "https://bitbucket-proxy.example.com/2.0", Token: "token"}).Validate())
+	assert.Error(t, (&Config{BaseURL: "https://bitbucket.example.com/rest/api/1.0", Token: "token"}).Validate(),
+		"Bitbucket Data Center is not supported")
+
+	provider := NewProvider(Config{BaseURL: "https://bitbucket.example.com/rest/api/latest", Token: "token"})
+	sync := newTestSync(klum.BitbucketSyncSpec{Workspace: "team", Repository: "app", VariableName: "KUBECONFIG"})
+	assert.Error(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+}
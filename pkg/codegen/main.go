@@ -19,6 +19,15 @@ func main() {
 					v1alpha1.User{},
 					v1alpha1.Kubeconfig{},
 					v1alpha1.UserSyncGithub{},
+					v1alpha1.UserSyncGitlab{},
+					v1alpha1.UserSyncGitea{},
+					v1alpha1.UserSyncVault{},
+					v1alpha1.UserSyncS3{},
+					v1alpha1.UserSyncWebhook{},
+					v1alpha1.UserSyncEmail{},
+					v1alpha1.UserSyncBitbucket{},
+					v1alpha1.UserSyncAWSSecret{},
+					v1alpha1.UserSyncRemoteSecret{},
 				},
 				GenerateTypes: true,
 			},
@@ -8,10 +8,24 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/jadolg/klum/pkg/metrics"
 
+	"github.com/jadolg/klum/pkg/aws"
+	"github.com/jadolg/klum/pkg/bitbucket"
+	"github.com/jadolg/klum/pkg/download"
+	"github.com/jadolg/klum/pkg/email"
+	"github.com/jadolg/klum/pkg/gitea"
 	"github.com/jadolg/klum/pkg/github"
+	"github.com/jadolg/klum/pkg/gitlab"
+	"github.com/jadolg/klum/pkg/remotesecret"
+	"github.com/jadolg/klum/pkg/render"
+	"github.com/jadolg/klum/pkg/s3"
+	"github.com/jadolg/klum/pkg/usersync"
+	"github.com/jadolg/klum/pkg/vault"
+	"github.com/jadolg/klum/pkg/webhook"
 
 	log "github.com/sirupsen/logrus"
 
@@ -29,6 +43,13 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	tokenSecretVersionAnnotation    = "klum.cattle.io/token-secret-version"
+	defaultNamespaceContextTemplate = "{{.Context}}-{{.Namespace}}"
+	clientCertificateSecretIndex    = "klum.cattle.io/client-certificate-secret"
 )
 
 type Config struct {
@@ -38,7 +59,27 @@ type Config struct {
 	CA                 string
 	DefaultClusterRole string
 	GithubConfig       github.Config
+	GitlabConfig       gitlab.Config
+	GiteaConfig        gitea.Config
+	VaultConfig        vault.Config
+	S3Config           s3.Config
+	EmailConfig        email.Config
+	BitbucketConfig    bitbucket.Config
+	AWSConfig          aws.Config
 	MetricsPort        int
+	DownloadConfig     download.Config
+	TokenSecretRef     bool
+	// TLSServerName, ProxyURL and InsecureSkipTLSVerify are copied to the cluster of every kubeconfig
+	TLSServerName         string
+	ProxyURL              string
+	InsecureSkipTLSVerify bool
+	// ContextPerNamespace adds a context for every namespace in the roles of a user, named after NamespaceContextTemplate
+	ContextPerNamespace      bool
+	NamespaceContextTemplate string
+	// VerifyInterval is how often synchronized kubeconfigs are checked for drift, disabled if 0
+	VerifyInterval time.Duration
+	// ClusterID identifies this cluster in the Secrets written to other clusters
+	ClusterID string
 }
 
 func Register(ctx context.Context,
@@ -48,21 +89,28 @@ func Register(ctx context.Context,
 	crb rbaccontroller.ClusterRoleBindingController,
 	rb rbaccontroller.RoleBindingController,
 	secrets v1controller.SecretController,
-	kconfig v1alpha1.KubeconfigController,
-	user v1alpha1.UserController,
-	userSyncGithub v1alpha1.UserSyncGithubController,
+	klumFactory v1alpha1.Interface,
+	recorder record.EventRecorder,
 	k8sversion *version.Info) {
 
+	kconfig := klumFactory.Kubeconfig()
+	user := klumFactory.User()
 	h := &handler{
 		cfg:             cfg,
 		apply:           apply.WithCacheTypes(kconfig),
 		serviceAccounts: serviceAccount.Cache(),
+		secrets:         secrets.Cache(),
 		k8sversion:      k8sversion,
 		kconfig:         kconfig,
 		kuser:           user,
-		kuserSyncGithub: userSyncGithub,
+		users:           user.Cache(),
+		secretQueue:     secrets,
+		syncs:           usersync.NewRegistry(),
+		recorder:        recorder,
 	}
 
+	user.Cache().AddIndexer(clientCertificateSecretIndex, indexClientCertificateSecret)
+
 	v1alpha1.RegisterUserGeneratingHandler(ctx,
 		user,
 		apply.WithCacheTypes(serviceAccount, crb, rb, secrets),
@@ -73,32 +121,62 @@ func Register(ctx context.Context,
 			AllowClusterScoped: true,
 		})
 
-	v1alpha1.RegisterUserSyncGithubGeneratingHandler(
-		ctx,
-		userSyncGithub,
-		apply,
-		"",
-		"klum-usersync",
-		h.OnUserSyncGithubChange,
-		&generic.GeneratingHandlerOptions{
-			AllowClusterScoped: true,
-		},
-	)
+	emailProvider, err := email.NewProvider(cfg.EmailConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	registerSync(ctx, h, github.NewProvider(cfg.GithubConfig), klumFactory.UserSyncGithub(), "klum-usersync")
+	registerSync(ctx, h, gitlab.NewProvider(cfg.GitlabConfig), klumFactory.UserSyncGitlab(), "klum-usersync-gitlab")
+	registerSync(ctx, h, gitea.NewProvider(cfg.GiteaConfig), klumFactory.UserSyncGitea(), "klum-usersync-gitea")
+	registerSync(ctx, h, vault.NewProvider(cfg.VaultConfig, cfg.Namespace, secrets.Cache()), klumFactory.UserSyncVault(), "klum-usersync-vault")
+	registerSync(ctx, h, s3.NewProvider(cfg.S3Config), klumFactory.UserSyncS3(), "klum-usersync-s3")
+	registerSync(ctx, h, webhook.NewProvider(cfg.Namespace, secrets.Cache()), klumFactory.UserSyncWebhook(), "klum-usersync-webhook")
+	registerSync(ctx, h, emailProvider, klumFactory.UserSyncEmail(), "klum-usersync-email")
+	registerSync(ctx, h, bitbucket.NewProvider(cfg.BitbucketConfig), klumFactory.UserSyncBitbucket(), "klum-usersync-bitbucket")
+	registerSync(ctx, h, aws.NewProvider(cfg.AWSConfig), klumFactory.UserSyncAWSSecret(), "klum-usersync-aws")
+	registerSync(ctx, h, remotesecret.NewProvider(cfg.Namespace, cfg.ClusterID, secrets.Cache()), klumFactory.UserSyncRemoteSecret(), "klum-usersync-remotesecret")
 
 	secrets.OnChange(ctx, "klum-secret", h.OnSecretChange)
 	kconfig.OnChange(ctx, "klum-kconfig", h.OnKubeconfigChange)
-	userSyncGithub.OnRemove(ctx, "klum-usersync", h.OnUserSyncGithubRemove)
 	user.OnRemove(ctx, "klum-user", h.OnUserRemoved)
 }
 
+// registerSync drives provider from the sync objects of controller. A new sync target only needs
+// a provider, a custom resource and a call to registerSync.
+func registerSync[T interface {
+	generic.RuntimeMetaObject
+	usersync.StatusObject
+}, TList runtime.Object](ctx context.Context, h *handler, provider usersync.Provider, controller generic.NonNamespacedControllerInterface[T, TList], name string) {
+	sync := usersync.NewHandler(provider, controller, h.kconfig, h.kuser, h.secrets).
+		WithDriftDetection(h.cfg.VerifyInterval, h.recorder)
+	mustRegisterSync(h.syncs, sync)
+	sync.Register(ctx, name)
+}
+
+func mustRegisterSync(registry *usersync.Registry, syncer usersync.Syncer) {
+	if err := registry.Register(syncer); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// secretEnqueuer is the subset of the Secret controller used to rebuild Kubeconfigs
+type secretEnqueuer interface {
+	Enqueue(namespace, name string)
+}
+
 type handler struct {
 	cfg             Config
 	apply           apply.Apply
 	serviceAccounts v1controller.ServiceAccountCache
+	secrets         v1controller.SecretCache
 	k8sversion      *version.Info
 	kuser           v1alpha1.UserController
+	users           v1alpha1.UserCache
+	secretQueue     secretEnqueuer
 	kconfig         v1alpha1.KubeconfigController
-	kuserSyncGithub v1alpha1.UserSyncGithubController
+	syncs           *usersync.Registry
+	recorder        record.EventRecorder
 }
 
 func sanitizedVersion(v string) int {
@@ -121,6 +199,19 @@ func (h *handler) OnUserChange(user *klum.User, status klum.UserStatus) ([]runti
 		return nil, status, nil
 	}
 
+	tokenSecrets := sanitizedVersion(h.k8sversion.Minor) >= 24
+	if user.Spec.CredentialGeneration != status.CredentialGeneration {
+		if tokenSecrets {
+			status = h.revokeCredentials(user, status)
+		} else {
+			// The token of the service account can't be replaced without a token Secret managed by klum
+			log.WithFields(log.Fields{
+				"user":       user.Name,
+				"generation": user.Spec.CredentialGeneration,
+			}).Warning("Revoking credentials requires Kubernetes 1.24 or newer")
+		}
+	}
+
 	objs := []runtime.Object{
 		&v1.ServiceAccount{
 			ObjectMeta: metav1.ObjectMeta{
@@ -133,11 +224,14 @@ func (h *handler) OnUserChange(user *klum.User, status klum.UserStatus) ([]runti
 		},
 	}
 
-	if sanitizedVersion(h.k8sversion.Minor) >= 24 {
+	if tokenSecrets {
+		status.CredentialsIssued = true
+		// The Kubeconfig is built from the token Secret, which doesn't change with the spec of the user
+		h.secretQueue.Enqueue(h.cfg.Namespace, tokenSecretName(user))
 		objs = append(objs,
 			&v1.Secret{
 				ObjectMeta: metav1.ObjectMeta{
-					Name:      user.Name,
+					Name:      tokenSecretName(user),
 					Namespace: h.cfg.Namespace,
 					Annotations: map[string]string{
 						"kubernetes.io/service-account.name": user.Name,
@@ -153,6 +247,34 @@ func (h *handler) OnUserChange(user *klum.User, status klum.UserStatus) ([]runti
 	return objs, setReady(status, true), nil
 }
 
+// revokeCredentials records the revocation of the credentials of the user. The Secret holding the
+// revoked token is pruned when the one for the new generation is applied, and the Kubeconfig is
+// replaced once the new Secret holds a token.
+func (h *handler) revokeCredentials(user *klum.User, status klum.UserStatus) klum.UserStatus {
+	status.CredentialGeneration = user.Spec.CredentialGeneration
+	if !status.CredentialsIssued {
+		return status
+	}
+
+	log.WithFields(log.Fields{
+		"user":       user.Name,
+		"generation": user.Spec.CredentialGeneration,
+	}).Info("Revoking credentials")
+
+	now := metav1.Now()
+	status.LastRevocationTime = &now
+	metrics.CredentialRevocationsTotal.Inc()
+	return status
+}
+
+// tokenSecretName is the name of the Secret holding the token of the current credential generation
+func tokenSecretName(user *klum.User) string {
+	if user.Spec.CredentialGeneration == 0 {
+		return user.Name
+	}
+	return fmt.Sprintf("%s-%d", user.Name, user.Spec.CredentialGeneration)
+}
+
 func (h *handler) getRoles(user *klum.User) []runtime.Object {
 	subjects := []rbacv1.Subject{
 		{
@@ -277,6 +399,10 @@ func getUserByName(name string, h *handler) (*klum.User, error) {
 func (h *handler) OnSecretChange(key string, secret *v1.Secret) (*v1.Secret, error) {
 	userName := getUserNameForSecret(secret)
 	if userName == "" {
+		return secret, h.enqueueClientCertificateUsers(secret)
+	}
+	if len(secret.Data["token"]) == 0 {
+		// The Secret is handled again once the token controller added the token
 		return secret, nil
 	}
 
@@ -284,57 +410,223 @@ func (h *handler) OnSecretChange(key string, secret *v1.Secret) (*v1.Secret, err
 	if ca == "" {
 		ca = base64.StdEncoding.EncodeToString(secret.Data["ca.crt"])
 	}
-	token := string(secret.Data["token"])
+	if h.cfg.InsecureSkipTLSVerify {
+		// clientcmd rejects a CA together with insecure-skip-tls-verify
+		ca = ""
+	}
 
 	contextName := h.cfg.ContextName
 	contextNamespace := "default"
+	options := &klum.KubeconfigOptions{}
+	var namespaceContexts []klum.NamedContext
+	// The token Secret is replaced on every credential generation, the Kubeconfig is owned by the
+	// user so it isn't garbage collected with the revoked Secret before the new one holds a token
+	var owner runtime.Object = secret
 	user, err := getUserByName(userName, h)
 	if err == nil {
+		owner = user
 		if err := h.updateUserDefaults(user); err != nil {
 			return nil, err
 		}
 		contextName = user.Spec.Context
 		contextNamespace = user.Spec.ContextNamespace
+		if user.Spec.Kubeconfig != nil {
+			options = user.Spec.Kubeconfig
+		}
+		namespaceContexts, err = h.namespaceContexts(user, userName, options.ContextExtensions)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return secret, h.apply.
-		WithOwner(secret).
-		WithSetOwnerReference(true, false).
-		ApplyObjects(&klum.Kubeconfig{
-			ObjectMeta: metav1.ObjectMeta{
-				Name: userName,
-			},
-			Spec: klum.KubeconfigSpec{
-				Clusters: []klum.NamedCluster{
-					{
-						Name: h.cfg.ContextName,
-						Cluster: klum.Cluster{
-							Server:                   h.cfg.Server,
-							CertificateAuthorityData: ca,
-						},
+	authInfo, err := h.authInfoForSecret(secret, options)
+	if err != nil {
+		return nil, err
+	}
+
+	kubeconfig := &klum.Kubeconfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: userName,
+		},
+		Spec: klum.KubeconfigSpec{
+			Clusters: []klum.NamedCluster{
+				{
+					Name: h.cfg.ContextName,
+					Cluster: klum.Cluster{
+						Server:                   h.cfg.Server,
+						CertificateAuthorityData: ca,
+						InsecureSkipTLSVerify:    h.cfg.InsecureSkipTLSVerify,
+						TLSServerName:            h.cfg.TLSServerName,
+						ProxyURL:                 h.cfg.ProxyURL,
 					},
 				},
-				AuthInfos: []klum.NamedAuthInfo{
-					{
-						Name: userName,
-						AuthInfo: klum.AuthInfo{
-							Token: token,
-						},
-					},
+			},
+			AuthInfos: []klum.NamedAuthInfo{
+				{
+					Name:     userName,
+					AuthInfo: authInfo,
 				},
-				Contexts: []klum.NamedContext{
-					{
-						Name: contextName,
-						Context: klum.Context{
-							Cluster:   h.cfg.ContextName,
-							AuthInfo:  userName,
-							Namespace: contextNamespace,
-						},
+			},
+			Contexts: append([]klum.NamedContext{
+				{
+					Name: contextName,
+					Context: klum.Context{
+						Cluster:    h.cfg.ContextName,
+						AuthInfo:   userName,
+						Namespace:  contextNamespace,
+						Extensions: options.ContextExtensions,
 					},
 				},
-				CurrentContext: contextName,
+			}, namespaceContexts...),
+			CurrentContext: contextName,
+			Preferences:    options.Preferences,
+		},
+	}
+
+	if err := render.Validate(kubeconfig.Spec); err != nil {
+		metrics.ErrorsTotal.Inc()
+		return nil, fmt.Errorf("kubeconfig for user %s: %w", userName, err)
+	}
+
+	if h.cfg.TokenSecretRef {
+		// The spec does not change when the token is rotated, so the version of the Secret
+		// is recorded to get the change propagated to the syncs
+		kubeconfig.Annotations = map[string]string{
+			tokenSecretVersionAnnotation: secret.ResourceVersion,
+		}
+	}
+
+	return secret, h.apply.
+		WithOwner(owner).
+		WithSetOwnerReference(true, false).
+		ApplyObjects(kubeconfig)
+}
+
+// namespaceContextData is passed to the template naming the context of each namespace
+type namespaceContextData struct {
+	Cluster   string
+	Context   string
+	User      string
+	Namespace string
+}
+
+// namespaceContexts returns one context per namespace in the user's roles when enabled
+func (h *handler) namespaceContexts(user *klum.User, userName string, extensions []klum.NamedExtension) ([]klum.NamedContext, error) {
+	enabled := h.cfg.ContextPerNamespace
+	if user.Spec.ContextPerNamespace != nil {
+		enabled = *user.Spec.ContextPerNamespace
+	}
+	if !enabled {
+		return nil, nil
+	}
+
+	nameTemplate := h.cfg.NamespaceContextTemplate
+	if nameTemplate == "" {
+		nameTemplate = defaultNamespaceContextTemplate
+	}
+	tmpl, err := template.New("context").Option("missingkey=error").Parse(nameTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid namespace context template: %w", err)
+	}
+
+	var contexts []klum.NamedContext
+	seen := map[string]bool{user.Spec.Context: true}
+	for _, role := range user.Spec.Roles {
+		if role.Namespace == "" {
+			continue
+		}
+
+		var name strings.Builder
+		err := tmpl.Execute(&name, namespaceContextData{
+			Cluster:   h.cfg.ContextName,
+			Context:   user.Spec.Context,
+			User:      userName,
+			Namespace: role.Namespace,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid namespace context template: %w", err)
+		}
+
+		if seen[name.String()] {
+			continue
+		}
+		seen[name.String()] = true
+
+		contexts = append(contexts, klum.NamedContext{
+			Name: name.String(),
+			Context: klum.Context{
+				Cluster:    h.cfg.ContextName,
+				AuthInfo:   userName,
+				Namespace:  role.Namespace,
+				Extensions: extensions,
 			},
 		})
+	}
+	return contexts, nil
+}
+
+// indexClientCertificateSecret indexes users by the Secret holding their client certificate
+func indexClientCertificateSecret(user *klum.User) ([]string, error) {
+	if user.Spec.Kubeconfig == nil || user.Spec.Kubeconfig.ClientCertificateSecret == "" {
+		return nil, nil
+	}
+	return []string{user.Spec.Kubeconfig.ClientCertificateSecret}, nil
+}
+
+// enqueueClientCertificateUsers enqueues the token Secrets of the users whose client certificate
+// is kept in secret, so their Kubeconfigs are rebuilt when it changes
+func (h *handler) enqueueClientCertificateUsers(secret *v1.Secret) error {
+	if secret == nil || secret.Namespace != h.cfg.Namespace {
+		return nil
+	}
+	users, err := h.users.GetByIndex(clientCertificateSecretIndex, secret.Name)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		h.secretQueue.Enqueue(h.cfg.Namespace, tokenSecretName(user))
+	}
+	return nil
+}
+
+func (h *handler) authInfoForSecret(secret *v1.Secret, options *klum.KubeconfigOptions) (klum.AuthInfo, error) {
+	authInfo := klum.AuthInfo{
+		Exec:                 options.Exec,
+		Impersonate:          options.Impersonate,
+		ImpersonateUID:       options.ImpersonateUID,
+		ImpersonateGroups:    options.ImpersonateGroups,
+		ImpersonateUserExtra: options.ImpersonateUserExtra,
+	}
+
+	if h.cfg.TokenSecretRef {
+		authInfo.TokenSecretRef = &klum.SecretKeyReference{
+			Namespace: secret.Namespace,
+			Name:      secret.Name,
+			Key:       "token",
+		}
+	} else {
+		authInfo.Token = string(secret.Data["token"])
+	}
+
+	if options.ClientCertificateSecret == "" {
+		return authInfo, nil
+	}
+
+	certSecret, err := h.secrets.Get(h.cfg.Namespace, options.ClientCertificateSecret)
+	if err != nil {
+		return authInfo, err
+	}
+	authInfo.ClientCertificateData = base64.StdEncoding.EncodeToString(certSecret.Data[v1.TLSCertKey])
+	if h.cfg.TokenSecretRef {
+		authInfo.ClientKeySecretRef = &klum.SecretKeyReference{
+			Namespace: certSecret.Namespace,
+			Name:      certSecret.Name,
+			Key:       v1.TLSPrivateKeyKey,
+		}
+	} else {
+		authInfo.ClientKeyData = base64.StdEncoding.EncodeToString(certSecret.Data[v1.TLSPrivateKeyKey])
+	}
+	return authInfo, nil
 }
 
 func (h *handler) updateUserDefaults(user *klum.User) error {
@@ -385,77 +677,20 @@ func (h *handler) removeKubeconfig(user *klum.User) error {
 
 func (h *handler) OnKubeconfigChange(s string, kubeconfig *klum.Kubeconfig) (*klum.Kubeconfig, error) {
 	if kubeconfig == nil {
+		// Let the syncs react to disabled users
+		if err := h.syncs.EnqueueUser(s); err != nil {
+			metrics.ErrorsTotal.Inc()
+			return nil, err
+		}
 		return nil, nil
 	}
-	// ToDo: Check how we can make `spec.user` usable as a field selector
-	userSyncsGithub, err := h.kuserSyncGithub.List(metav1.ListOptions{})
-	if err != nil {
+	if err := h.syncs.EnqueueUser(kubeconfig.Name); err != nil {
 		metrics.ErrorsTotal.Inc()
 		return nil, err
 	}
-	for _, userSync := range userSyncsGithub.Items {
-		if userSync.Spec.User == kubeconfig.Name {
-			log.Infof("Synchronizing credentials for %s", userSync.Name)
-			h.kuserSyncGithub.Enqueue(userSync.Name)
-		}
-	}
 	return kubeconfig, nil
 }
 
-func (h *handler) OnUserSyncGithubChange(syncGithub *klum.UserSyncGithub, s klum.UserSyncStatus) ([]runtime.Object, klum.UserSyncStatus, error) {
-	if syncGithub == nil {
-		return nil, setSyncGithubReady(s, false, nil), nil
-	}
-	if h.cfg.GithubConfig.Enabled() {
-		kubeconfig, err := h.kconfig.Get(syncGithub.Spec.User, metav1.GetOptions{})
-		if err != nil {
-			return nil, setSyncGithubReady(s, false, err), err
-		}
-
-		if kubeconfig != nil {
-			err = github.UploadKubeconfig(syncGithub, kubeconfig, h.cfg.GithubConfig)
-			if err != nil {
-				metrics.ErrorsTotal.Inc()
-				return nil, setSyncGithubReady(s, false, err), err
-			}
-
-			_, err := h.kuserSyncGithub.Update(syncGithub)
-			if err != nil {
-				metrics.ErrorsTotal.Inc()
-				return nil, setSyncGithubReady(s, false, err), err
-			}
-		} else {
-			return nil, setSyncGithubReady(s, false, err), fmt.Errorf("kubeconfig for user %s is not yet ready", syncGithub.Spec.User)
-		}
-	} else {
-		log.WithFields(log.Fields{
-			"usersync": syncGithub.Name,
-		}).Warning("Github Synchronization is disabled but UserSyncGithub objects are created")
-		err := fmt.Errorf("GitHub Synchronization is disabled in klum")
-		metrics.ErrorsTotal.Inc()
-		return nil, setSyncGithubReady(s, false, err), nil
-	}
-
-	return []runtime.Object{}, setSyncGithubReady(s, true, nil), nil
-}
-
-func (h *handler) OnUserSyncGithubRemove(s string, sync *klum.UserSyncGithub) (*klum.UserSyncGithub, error) {
-	if sync == nil {
-		return nil, nil
-	}
-	if h.cfg.GithubConfig.Enabled() {
-		err := github.DeleteKubeconfig(sync, h.cfg.GithubConfig)
-		if err != nil {
-			metrics.ErrorsTotal.Inc()
-			return nil, err
-		}
-	} else {
-		log.Warning("Github Synchronization is disabled but UserSyncGithub objects are created")
-	}
-
-	return nil, nil
-}
-
 func setReady(status klum.UserStatus, ready bool) klum.UserStatus {
 	// dumb hack to set condition, should really make this easier
 	user := &klum.User{Status: status}
@@ -463,16 +698,6 @@ func setReady(status klum.UserStatus, ready bool) klum.UserStatus {
 	return user.Status
 }
 
-func setSyncGithubReady(status klum.UserSyncStatus, ready bool, err error) klum.UserSyncStatus {
-	userSync := &klum.UserSyncGithub{Status: status}
-	klum.UserSyncReadyCondition.SetStatusBool(userSync, ready)
-	if err != nil {
-		metrics.ErrorsTotal.Inc()
-		klum.UserSyncReadyCondition.SetError(userSync, err.Error(), err)
-	}
-	return userSync.Status
-}
-
 func (h *handler) OnUserRemoved(key string, user *klum.User) (*klum.User, error) {
 	_, err := h.kconfig.Get(user.Name, metav1.GetOptions{})
 	if err != nil {
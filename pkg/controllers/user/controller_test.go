@@ -4,11 +4,13 @@ import (
 	"testing"
 
 	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	"github.com/jadolg/klum/pkg/render"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 func TestVersion(t *testing.T) {
@@ -760,7 +762,7 @@ func TestOnSecretChange_Valid(t *testing.T) {
 	cfg := Config{
 		ContextName: "test-context",
 		Server:      "https://k8s.example.com",
-		CA:          "test-ca-data",
+		CA:          "dGVzdC1jYS1kYXRh",
 	}
 	kuser := NewMockUserController()
 	kconfig := NewMockKubeconfigController()
@@ -802,13 +804,14 @@ func TestOnSecretChange_Valid(t *testing.T) {
 
 	assert.Equal(t, "testuser", kc.Name)
 	assert.Equal(t, "user-context", kc.Spec.CurrentContext)
-	assert.Equal(t, "test-ca-data", kc.Spec.Clusters[0].Cluster.CertificateAuthorityData)
+	assert.Equal(t, "dGVzdC1jYS1kYXRh", kc.Spec.Clusters[0].Cluster.CertificateAuthorityData)
 	assert.Equal(t, "test-token", kc.Spec.AuthInfos[0].AuthInfo.Token)
 }
 
 func TestOnSecretChange_UpdateDefaults(t *testing.T) {
 	cfg := Config{
 		ContextName: "default-context",
+		Server:      "https://k8s.example.com",
 	}
 	kuser := NewMockUserController()
 	kconfig := NewMockKubeconfigController()
@@ -852,3 +855,546 @@ func TestOnSecretChange_UpdateDefaults(t *testing.T) {
 	require.True(t, ok)
 	assert.Equal(t, "default-context", kc.Spec.CurrentContext)
 }
+
+func TestOnSecretChange_TokenSecretRef(t *testing.T) {
+	cfg := Config{
+		ContextName:    "test-context",
+		Server:         "https://k8s.example.com",
+		CA:             "dGVzdC1jYS1kYXRh",
+		TokenSecretRef: true,
+	}
+	kuser := NewMockUserController()
+	kconfig := NewMockKubeconfigController()
+	kuserSyncGithub := NewMockUserSyncGithubController()
+	mockApply := NewMockApply()
+
+	h := newTestHandlerWithApply(cfg, kuser, kconfig, kuserSyncGithub, mockApply, "25")
+
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "testuser",
+			Namespace:       "klum",
+			ResourceVersion: "42",
+			Annotations: map[string]string{
+				"objectset.rio.cattle.io/id":         "klum-user",
+				"objectset.rio.cattle.io/owner-name": "testuser",
+			},
+		},
+		Type: v1.SecretTypeServiceAccountToken,
+		Data: map[string][]byte{
+			"token": []byte("test-token"),
+		},
+	}
+
+	_, err := h.OnSecretChange("klum/testuser", secret)
+	require.NoError(t, err)
+
+	require.Len(t, mockApply.AppliedObjects, 1)
+	kc, ok := mockApply.AppliedObjects[0].(*klum.Kubeconfig)
+	require.True(t, ok)
+
+	authInfo := kc.Spec.AuthInfos[0].AuthInfo
+	assert.Empty(t, authInfo.Token)
+	require.NotNil(t, authInfo.TokenSecretRef)
+	assert.Equal(t, "klum", authInfo.TokenSecretRef.Namespace)
+	assert.Equal(t, "testuser", authInfo.TokenSecretRef.Name)
+	assert.Equal(t, "42", kc.Annotations[tokenSecretVersionAnnotation])
+
+	secrets := NewMockSecretCache()
+	secrets.AddSecret(secret)
+	rendered, err := render.Kubeconfig(kc, secrets)
+	require.NoError(t, err)
+	assert.Equal(t, "test-token", rendered.Spec.AuthInfos[0].AuthInfo.Token)
+	assert.Nil(t, rendered.Spec.AuthInfos[0].AuthInfo.TokenSecretRef)
+	// The stored object must not be modified by rendering
+	assert.Empty(t, kc.Spec.AuthInfos[0].AuthInfo.Token)
+}
+
+func newTestUserSecret(userName string) *v1.Secret {
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      userName,
+			Namespace: "klum",
+			Annotations: map[string]string{
+				"objectset.rio.cattle.io/id":         "klum-user",
+				"objectset.rio.cattle.io/owner-name": userName,
+			},
+		},
+		Type: v1.SecretTypeServiceAccountToken,
+		Data: map[string][]byte{
+			"token":  []byte("test-token"),
+			"ca.crt": []byte("ca-cert"),
+		},
+	}
+}
+
+func TestOnSecretChange_KubeconfigOptions(t *testing.T) {
+	cfg := Config{
+		Namespace:     "klum",
+		ContextName:   "test-context",
+		Server:        "https://k8s.example.com",
+		TLSServerName: "k8s.internal",
+		ProxyURL:      "http://proxy.example.com:3128",
+	}
+	kuser := NewMockUserController()
+	kconfig := NewMockKubeconfigController()
+	kuserSyncGithub := NewMockUserSyncGithubController()
+	mockApply := NewMockApply()
+
+	h := newTestHandlerWithApply(cfg, kuser, kconfig, kuserSyncGithub, mockApply, "25")
+	secrets := NewMockSecretCache()
+	secrets.AddSecret(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "testuser-cert", Namespace: "klum"},
+		Type:       v1.SecretTypeTLS,
+		Data: map[string][]byte{
+			v1.TLSCertKey:       []byte("cert"),
+			v1.TLSPrivateKeyKey: []byte("key"),
+		},
+	})
+	h.secrets = secrets
+
+	kuser.AddUser(&klum.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "testuser"},
+		Spec: klum.UserSpec{
+			Context:          "user-context",
+			ContextNamespace: "user-namespace",
+			Kubeconfig: &klum.KubeconfigOptions{
+				ClientCertificateSecret: "testuser-cert",
+				Impersonate:             "someone",
+				ImpersonateGroups:       []string{"devs"},
+				Preferences:             &klum.Preferences{Colors: true},
+			},
+		},
+	})
+
+	_, err := h.OnSecretChange("klum/testuser", newTestUserSecret("testuser"))
+	require.NoError(t, err)
+
+	require.Len(t, mockApply.AppliedObjects, 1)
+	kc, ok := mockApply.AppliedObjects[0].(*klum.Kubeconfig)
+	require.True(t, ok)
+
+	cluster := kc.Spec.Clusters[0].Cluster
+	assert.Equal(t, "k8s.internal", cluster.TLSServerName)
+	assert.Equal(t, "http://proxy.example.com:3128", cluster.ProxyURL)
+
+	authInfo := kc.Spec.AuthInfos[0].AuthInfo
+	assert.Equal(t, "test-token", authInfo.Token)
+	assert.Equal(t, "someone", authInfo.Impersonate)
+	assert.Equal(t, []string{"devs"}, authInfo.ImpersonateGroups)
+	assert.Equal(t, "Y2VydA==", authInfo.ClientCertificateData)
+	assert.Equal(t, "a2V5", authInfo.ClientKeyData)
+	require.NotNil(t, kc.Spec.Preferences)
+	assert.True(t, kc.Spec.Preferences.Colors)
+}
+
+func TestOnUserChange_EnqueuesTokenSecret(t *testing.T) {
+	cfg := Config{
+		Namespace: "klum",
+	}
+	kuser := NewMockUserController()
+	kconfig := NewMockKubeconfigController()
+	kuserSyncGithub := NewMockUserSyncGithubController()
+
+	h := newTestHandler(cfg, kuser, kconfig, kuserSyncGithub, "25")
+	secretQueue := &MockSecretEnqueuer{}
+	h.secretQueue = secretQueue
+
+	user := &klum.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "testuser"},
+		Spec:       klum.UserSpec{CredentialGeneration: 2},
+	}
+
+	_, _, err := h.OnUserChange(user, klum.UserStatus{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"klum/testuser-2"}, secretQueue.EnqueuedKeys)
+}
+
+func TestOnSecretChange_ClientCertificateSecret(t *testing.T) {
+	cfg := Config{
+		Namespace: "klum",
+	}
+	kuser := NewMockUserController()
+	kconfig := NewMockKubeconfigController()
+	kuserSyncGithub := NewMockUserSyncGithubController()
+	mockApply := NewMockApply()
+
+	h := newTestHandlerWithApply(cfg, kuser, kconfig, kuserSyncGithub, mockApply, "25")
+	secretQueue := &MockSecretEnqueuer{}
+	h.secretQueue = secretQueue
+	users := NewMockUserCache()
+	users.AddUser(&klum.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "testuser"},
+		Spec: klum.UserSpec{
+			Kubeconfig: &klum.KubeconfigOptions{ClientCertificateSecret: "testuser-cert"},
+		},
+	})
+	users.AddUser(&klum.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "otheruser"},
+	})
+	h.users = users
+
+	certSecret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "testuser-cert", Namespace: "klum"},
+		Type:       v1.SecretTypeTLS,
+	}
+	_, err := h.OnSecretChange("klum/testuser-cert", certSecret)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"klum/testuser"}, secretQueue.EnqueuedKeys)
+	assert.Empty(t, mockApply.AppliedObjects)
+
+	// Secrets outside of the klum namespace are never referenced
+	secretQueue.EnqueuedKeys = nil
+	certSecret.Namespace = "other"
+	_, err = h.OnSecretChange("other/testuser-cert", certSecret)
+	require.NoError(t, err)
+	assert.Empty(t, secretQueue.EnqueuedKeys)
+}
+
+func TestOnSecretChange_InsecureSkipTLSVerifyDropsCA(t *testing.T) {
+	cfg := Config{
+		ContextName:           "test-context",
+		Server:                "https://k8s.example.com",
+		InsecureSkipTLSVerify: true,
+	}
+	kuser := NewMockUserController()
+	kconfig := NewMockKubeconfigController()
+	kuserSyncGithub := NewMockUserSyncGithubController()
+	mockApply := NewMockApply()
+
+	h := newTestHandlerWithApply(cfg, kuser, kconfig, kuserSyncGithub, mockApply, "25")
+
+	_, err := h.OnSecretChange("klum/testuser", newTestUserSecret("testuser"))
+	require.NoError(t, err)
+
+	require.Len(t, mockApply.AppliedObjects, 1)
+	kc, ok := mockApply.AppliedObjects[0].(*klum.Kubeconfig)
+	require.True(t, ok)
+	assert.True(t, kc.Spec.Clusters[0].Cluster.InsecureSkipTLSVerify)
+	assert.Empty(t, kc.Spec.Clusters[0].Cluster.CertificateAuthorityData)
+}
+
+func TestOnSecretChange_InvalidKubeconfig(t *testing.T) {
+	cfg := Config{
+		ContextName: "test-context",
+		Server:      "https://k8s.example.com",
+	}
+	kuser := NewMockUserController()
+	kconfig := NewMockKubeconfigController()
+	kuserSyncGithub := NewMockUserSyncGithubController()
+	mockApply := NewMockApply()
+
+	h := newTestHandlerWithApply(cfg, kuser, kconfig, kuserSyncGithub, mockApply, "25")
+
+	kuser.AddUser(&klum.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "testuser"},
+		Spec: klum.UserSpec{
+			Context:          "user-context",
+			ContextNamespace: "user-namespace",
+			Kubeconfig: &klum.KubeconfigOptions{
+				// apiVersion is mandatory for exec plugins
+				Exec: &klum.ExecConfig{Command: "get-token"},
+			},
+		},
+	})
+
+	_, err := h.OnSecretChange("klum/testuser", newTestUserSecret("testuser"))
+	require.Error(t, err)
+	assert.Empty(t, mockApply.AppliedObjects)
+}
+
+func TestOnSecretChange_ContextPerNamespace(t *testing.T) {
+	tests := []struct {
+		name             string
+		cfg              Config
+		userSetting      *bool
+		expectedContexts []string
+	}{
+		{
+			name:             "disabled by default",
+			expectedContexts: []string{"user-context"},
+		},
+		{
+			name:             "enabled globally",
+			cfg:              Config{ContextPerNamespace: true},
+			expectedContexts: []string{"user-context", "user-context-frontend", "user-context-backend"},
+		},
+		{
+			name:             "disabled for the user",
+			cfg:              Config{ContextPerNamespace: true},
+			userSetting:      boolPtr(false),
+			expectedContexts: []string{"user-context"},
+		},
+		{
+			name:             "enabled for the user",
+			userSetting:      boolPtr(true),
+			expectedContexts: []string{"user-context", "user-context-frontend", "user-context-backend"},
+		},
+		{
+			name:             "custom template",
+			cfg:              Config{ContextPerNamespace: true, NamespaceContextTemplate: "{{.Cluster}}/{{.Namespace}}"},
+			expectedContexts: []string{"user-context", "test-context/frontend", "test-context/backend"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.ContextName = "test-context"
+			cfg.Server = "https://k8s.example.com"
+			kuser := NewMockUserController()
+			kconfig := NewMockKubeconfigController()
+			kuserSyncGithub := NewMockUserSyncGithubController()
+			mockApply := NewMockApply()
+
+			h := newTestHandlerWithApply(cfg, kuser, kconfig, kuserSyncGithub, mockApply, "25")
+
+			kuser.AddUser(&klum.User{
+				ObjectMeta: metav1.ObjectMeta{Name: "testuser"},
+				Spec: klum.UserSpec{
+					Context:             "user-context",
+					ContextNamespace:    "frontend",
+					ContextPerNamespace: tt.userSetting,
+					Roles: []klum.NamespaceRole{
+						{Namespace: "frontend", Role: "developer"},
+						{Namespace: "frontend", ClusterRole: "view"},
+						{Namespace: "backend", ClusterRole: "view"},
+					},
+				},
+			})
+
+			_, err := h.OnSecretChange("klum/testuser", newTestUserSecret("testuser"))
+			require.NoError(t, err)
+
+			require.Len(t, mockApply.AppliedObjects, 1)
+			kc, ok := mockApply.AppliedObjects[0].(*klum.Kubeconfig)
+			require.True(t, ok)
+
+			var contexts []string
+			for _, context := range kc.Spec.Contexts {
+				contexts = append(contexts, context.Name)
+			}
+			assert.Equal(t, tt.expectedContexts, contexts)
+			assert.Equal(t, "user-context", kc.Spec.CurrentContext)
+			assert.Equal(t, "frontend", kc.Spec.Contexts[0].Context.Namespace)
+		})
+	}
+}
+
+func TestOnUserChange_CredentialGeneration(t *testing.T) {
+	cfg := Config{
+		Namespace:          "klum-system",
+		DefaultClusterRole: "cluster-admin",
+	}
+	kuser := NewMockUserController()
+	kconfig := NewMockKubeconfigController()
+	kuserSyncGithub := NewMockUserSyncGithubController()
+
+	h := newTestHandler(cfg, kuser, kconfig, kuserSyncGithub, "25")
+
+	user := &klum.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "testuser"},
+	}
+
+	objs, status, err := h.OnUserChange(user, klum.UserStatus{})
+	require.NoError(t, err)
+	assert.True(t, status.CredentialsIssued)
+	assert.Nil(t, status.LastRevocationTime)
+	assert.Equal(t, "testuser", findTokenSecret(t, objs).Name)
+
+	kconfig.AddKubeconfig(&klum.Kubeconfig{ObjectMeta: metav1.ObjectMeta{Name: "testuser"}})
+	user.Spec.CredentialGeneration = 1
+
+	objs, status, err = h.OnUserChange(user, status)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), status.CredentialGeneration)
+	assert.NotNil(t, status.LastRevocationTime)
+	assert.Equal(t, "testuser-1", findTokenSecret(t, objs).Name)
+
+	// The kubeconfig is kept until the new Secret holds a token, so syncs don't fail in between
+	_, err = kconfig.Get("testuser", metav1.GetOptions{})
+	assert.NoError(t, err)
+
+	// Nothing is revoked again until the generation changes
+	revokedAt := status.LastRevocationTime
+	_, status, err = h.OnUserChange(user, status)
+	require.NoError(t, err)
+	assert.Equal(t, revokedAt, status.LastRevocationTime)
+}
+
+func TestOnUserChange_CredentialGenerationK8sBelow24(t *testing.T) {
+	cfg := Config{
+		Namespace:          "klum-system",
+		DefaultClusterRole: "cluster-admin",
+	}
+	kuser := NewMockUserController()
+	kconfig := NewMockKubeconfigController()
+	kuserSyncGithub := NewMockUserSyncGithubController()
+
+	h := newTestHandler(cfg, kuser, kconfig, kuserSyncGithub, "23")
+
+	user := &klum.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "testuser"},
+		Spec:       klum.UserSpec{CredentialGeneration: 1},
+	}
+
+	// Without a token Secret there is nothing to revoke
+	_, status, err := h.OnUserChange(user, klum.UserStatus{Conditions: setReady(klum.UserStatus{}, true).Conditions})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), status.CredentialGeneration)
+	assert.False(t, status.CredentialsIssued)
+	assert.Nil(t, status.LastRevocationTime)
+}
+
+func TestOnSecretChange_WaitsForToken(t *testing.T) {
+	cfg := Config{
+		ContextName: "test-context",
+		Server:      "https://k8s.example.com",
+	}
+	kuser := NewMockUserController()
+	kconfig := NewMockKubeconfigController()
+	kuserSyncGithub := NewMockUserSyncGithubController()
+	mockApply := NewMockApply()
+
+	h := newTestHandlerWithApply(cfg, kuser, kconfig, kuserSyncGithub, mockApply, "25")
+
+	secret := newTestUserSecret("testuser")
+	delete(secret.Data, "token")
+	_, err := h.OnSecretChange("klum/testuser", secret)
+	require.NoError(t, err)
+	assert.Empty(t, mockApply.AppliedObjects)
+}
+
+func TestOnSecretChange_KubeconfigOutlivesRevokedSecret(t *testing.T) {
+	cfg := Config{
+		Namespace:   "klum-system",
+		ContextName: "test-context",
+		Server:      "https://k8s.example.com",
+	}
+	kuser := NewMockUserController()
+	kconfig := NewMockKubeconfigController()
+	kuserSyncGithub := NewMockUserSyncGithubController()
+	mockApply := NewMockApply()
+
+	h := newTestHandlerWithApply(cfg, kuser, kconfig, kuserSyncGithub, mockApply, "25")
+
+	user := &klum.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "testuser"},
+		Spec:       klum.UserSpec{Context: "test-context", ContextNamespace: "default"},
+	}
+	kuser.AddUser(user)
+
+	secret := newTestUserSecret("testuser")
+	_, err := h.OnSecretChange("klum-system/testuser", secret)
+	require.NoError(t, err)
+	require.Len(t, mockApply.AppliedObjects, 1)
+	kconfig.AddKubeconfig(mockApply.AppliedObjects[0].(*klum.Kubeconfig))
+
+	// Rotating the credentials prunes the Secret of the previous generation before the token
+	// controller fills the new one, the garbage collector deletes what the pruned Secret owns
+	user.Spec.CredentialGeneration = 1
+	objs, _, err := h.OnUserChange(user, klum.UserStatus{CredentialsIssued: true})
+	require.NoError(t, err)
+	assert.NotEqual(t, secret.Name, findTokenSecret(t, objs).Name)
+	if owner, ok := mockApply.Owner.(*v1.Secret); ok && owner.Name == secret.Name {
+		require.NoError(t, kconfig.Delete("testuser", &metav1.DeleteOptions{}))
+	}
+
+	_, err = kconfig.Get("testuser", metav1.GetOptions{})
+	assert.NoError(t, err, "the kubeconfig is kept until the new Secret holds a token")
+	owner, ok := mockApply.Owner.(*klum.User)
+	require.True(t, ok, "the kubeconfig is owned by the user")
+	assert.Equal(t, "testuser", owner.Name)
+}
+
+func TestOnUserChange_CredentialGenerationOnNewUser(t *testing.T) {
+	cfg := Config{
+		Namespace:          "klum-system",
+		DefaultClusterRole: "cluster-admin",
+	}
+	kuser := NewMockUserController()
+	kconfig := NewMockKubeconfigController()
+	kuserSyncGithub := NewMockUserSyncGithubController()
+
+	h := newTestHandler(cfg, kuser, kconfig, kuserSyncGithub, "25")
+
+	user := &klum.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "testuser"},
+		Spec:       klum.UserSpec{CredentialGeneration: 3},
+	}
+
+	objs, status, err := h.OnUserChange(user, klum.UserStatus{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), status.CredentialGeneration)
+	assert.Nil(t, status.LastRevocationTime)
+	assert.Equal(t, "testuser-3", findTokenSecret(t, objs).Name)
+}
+
+func findTokenSecret(t *testing.T, objs []runtime.Object) *v1.Secret {
+	for _, obj := range objs {
+		if secret, ok := obj.(*v1.Secret); ok {
+			return secret
+		}
+	}
+	require.Fail(t, "no token secret found")
+	return nil
+}
+
+func TestOnUserChange_RolesChangeRebuildsContexts(t *testing.T) {
+	cfg := Config{
+		Namespace:           "klum",
+		ContextName:         "test-context",
+		Server:              "https://k8s.example.com",
+		ContextPerNamespace: true,
+	}
+	kuser := NewMockUserController()
+	kconfig := NewMockKubeconfigController()
+	kuserSyncGithub := NewMockUserSyncGithubController()
+	mockApply := NewMockApply()
+
+	h := newTestHandlerWithApply(cfg, kuser, kconfig, kuserSyncGithub, mockApply, "25")
+	secretQueue := &MockSecretEnqueuer{}
+	h.secretQueue = secretQueue
+	tokenSecrets := map[string]*v1.Secret{"klum/testuser": newTestUserSecret("testuser")}
+
+	user := &klum.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "testuser"},
+		Spec: klum.UserSpec{
+			Context:          "user-context",
+			ContextNamespace: "frontend",
+			Roles: []klum.NamespaceRole{
+				{Namespace: "frontend", ClusterRole: "view"},
+			},
+		},
+	}
+	kuser.AddUser(user)
+
+	// processQueue runs the Secret handler for the token Secrets enqueued by the User handler
+	processQueue := func() []string {
+		for _, key := range secretQueue.EnqueuedKeys {
+			_, err := h.OnSecretChange(key, tokenSecrets[key])
+			require.NoError(t, err)
+		}
+		secretQueue.EnqueuedKeys = nil
+
+		require.NotEmpty(t, mockApply.AppliedObjects)
+		kc, ok := mockApply.AppliedObjects[len(mockApply.AppliedObjects)-1].(*klum.Kubeconfig)
+		require.True(t, ok)
+		var contexts []string
+		for _, context := range kc.Spec.Contexts {
+			contexts = append(contexts, context.Name)
+		}
+		return contexts
+	}
+
+	_, status, err := h.OnUserChange(user, klum.UserStatus{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user-context", "user-context-frontend"}, processQueue())
+
+	user.Spec.Roles = append(user.Spec.Roles, klum.NamespaceRole{Namespace: "backend", ClusterRole: "edit"})
+	kuser.AddUser(user)
+
+	_, _, err = h.OnUserChange(user, status)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user-context", "user-context-frontend", "user-context-backend"}, processQueue())
+}
@@ -5,6 +5,8 @@ import (
 	"time"
 
 	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	"github.com/jadolg/klum/pkg/github"
+	"github.com/jadolg/klum/pkg/usersync"
 	"github.com/rancher/wrangler/v3/pkg/apply"
 	"github.com/rancher/wrangler/v3/pkg/apply/injectors"
 	"github.com/rancher/wrangler/v3/pkg/generic"
@@ -284,11 +286,111 @@ func (m *MockServiceAccountCache) GetByIndex(indexName, key string) ([]*v1.Servi
 	return nil, nil
 }
 
+// --- MockSecretCache ---
+
+type MockSecretCache struct {
+	secrets map[string]*v1.Secret
+}
+
+func NewMockSecretCache() *MockSecretCache {
+	return &MockSecretCache{
+		secrets: make(map[string]*v1.Secret),
+	}
+}
+
+func (m *MockSecretCache) Get(namespace, name string) (*v1.Secret, error) {
+	key := namespace + "/" + name
+	if secret, ok := m.secrets[key]; ok {
+		return secret.DeepCopy(), nil
+	}
+	return nil, errors.NewNotFound(schema.GroupResource{Group: "", Resource: "secrets"}, name)
+}
+
+func (m *MockSecretCache) AddSecret(secret *v1.Secret) {
+	m.secrets[secret.Namespace+"/"+secret.Name] = secret.DeepCopy()
+}
+
+func (m *MockSecretCache) List(namespace string, selector labels.Selector) ([]*v1.Secret, error) {
+	var result []*v1.Secret
+	for _, secret := range m.secrets {
+		if secret.Namespace == namespace {
+			result = append(result, secret.DeepCopy())
+		}
+	}
+	return result, nil
+}
+
+func (m *MockSecretCache) AddIndexer(indexName string, indexer v1controller.SecretIndexer) {
+}
+func (m *MockSecretCache) GetByIndex(indexName, key string) ([]*v1.Secret, error) {
+	return nil, nil
+}
+
+// --- MockUserCache ---
+
+type MockUserCache struct {
+	users map[string]*klum.User
+}
+
+func NewMockUserCache() *MockUserCache {
+	return &MockUserCache{
+		users: make(map[string]*klum.User),
+	}
+}
+
+func (m *MockUserCache) AddUser(user *klum.User) {
+	m.users[user.Name] = user.DeepCopy()
+}
+
+func (m *MockUserCache) Get(name string) (*klum.User, error) {
+	if user, ok := m.users[name]; ok {
+		return user.DeepCopy(), nil
+	}
+	return nil, errors.NewNotFound(schema.GroupResource{Group: "klum.cattle.io", Resource: "users"}, name)
+}
+
+func (m *MockUserCache) List(selector labels.Selector) ([]*klum.User, error) {
+	var result []*klum.User
+	for _, user := range m.users {
+		result = append(result, user.DeepCopy())
+	}
+	return result, nil
+}
+
+func (m *MockUserCache) AddIndexer(indexName string, indexer generic.Indexer[*klum.User]) {
+}
+
+// GetByIndex only supports the client certificate Secret index
+func (m *MockUserCache) GetByIndex(indexName, key string) ([]*klum.User, error) {
+	var result []*klum.User
+	for _, user := range m.users {
+		keys, _ := indexClientCertificateSecret(user)
+		for _, k := range keys {
+			if k == key {
+				result = append(result, user.DeepCopy())
+			}
+		}
+	}
+	return result, nil
+}
+
+// --- MockSecretEnqueuer ---
+
+type MockSecretEnqueuer struct {
+	EnqueuedKeys []string
+}
+
+func (m *MockSecretEnqueuer) Enqueue(namespace, name string) {
+	m.EnqueuedKeys = append(m.EnqueuedKeys, namespace+"/"+name)
+}
+
 // --- MockApply ---
 
 type MockApply struct {
 	AppliedObjects []runtime.Object
 	ApplyError     error
+	// Owner is the owner of the objects applied last, the garbage collector deletes them with it
+	Owner runtime.Object
 }
 
 func NewMockApply() *MockApply {
@@ -309,7 +411,7 @@ func (m *MockApply) ApplyObjects(objs ...runtime.Object) error {
 func (m *MockApply) WithCacheTypeFactory(factory apply.InformerFactory) apply.Apply { return m }
 func (m *MockApply) WithCacheTypes(igs ...apply.InformerGetter) apply.Apply         { return m }
 func (m *MockApply) WithSetID(id string) apply.Apply                                { return m }
-func (m *MockApply) WithOwner(obj runtime.Object) apply.Apply                       { return m }
+func (m *MockApply) WithOwner(obj runtime.Object) apply.Apply                       { m.Owner = obj; return m }
 func (m *MockApply) WithSetOwnerReference(enabled, block bool) apply.Apply          { return m }
 func (m *MockApply) WithOwnerKey(key string, gvk schema.GroupVersionKind) apply.Apply {
 	return m
@@ -348,14 +450,23 @@ func boolPtr(b bool) *bool {
 	return &b
 }
 
+func newTestRegistry(cfg Config, kuser *MockUserController, kconfig *MockKubeconfigController, kuserSyncGithub *MockUserSyncGithubController) *usersync.Registry {
+	registry := usersync.NewRegistry()
+	mustRegisterSync(registry, usersync.NewHandler(github.NewProvider(cfg.GithubConfig), kuserSyncGithub, kconfig, kuser, NewMockSecretCache()))
+	return registry
+}
+
 func newTestHandler(cfg Config, kuser *MockUserController, kconfig *MockKubeconfigController, kuserSyncGithub *MockUserSyncGithubController, k8sMinor string) *handler {
 	return &handler{
 		cfg:             cfg,
 		kuser:           kuser,
 		kconfig:         kconfig,
-		kuserSyncGithub: kuserSyncGithub,
+		syncs:           newTestRegistry(cfg, kuser, kconfig, kuserSyncGithub),
 		k8sversion:      &version.Info{Minor: k8sMinor},
 		serviceAccounts: NewMockServiceAccountCache(),
+		secrets:         NewMockSecretCache(),
+		users:           NewMockUserCache(),
+		secretQueue:     &MockSecretEnqueuer{},
 		apply:           NewMockApply(),
 	}
 }
@@ -365,9 +476,12 @@ func newTestHandlerWithApply(cfg Config, kuser *MockUserController, kconfig *Moc
 		cfg:             cfg,
 		kuser:           kuser,
 		kconfig:         kconfig,
-		kuserSyncGithub: kuserSyncGithub,
+		syncs:           newTestRegistry(cfg, kuser, kconfig, kuserSyncGithub),
 		k8sversion:      &version.Info{Minor: k8sMinor},
 		serviceAccounts: NewMockServiceAccountCache(),
+		secrets:         NewMockSecretCache(),
+		users:           NewMockUserCache(),
+		secretQueue:     &MockSecretEnqueuer{},
 		apply:           mockApply,
 	}
 }
@@ -20,7 +20,16 @@ func Create(ctx context.Context, config *rest.Config) error {
 	return factory.BatchCreateCRDs(ctx,
 		newCRD("User.klum.cattle.io/v1alpha1", v1alpha1.User{}),
 		newCRD("Kubeconfig.klum.cattle.io/v1alpha1", v1alpha1.Kubeconfig{}),
-		newCRD("UserSyncGithub.klum.cattle.io/v1alpha1", v1alpha1.UserSyncGithub{}),
+		newSyncCRD("UserSyncGithub.klum.cattle.io/v1alpha1", v1alpha1.UserSyncGithub{}),
+		newSyncCRD("UserSyncGitlab.klum.cattle.io/v1alpha1", v1alpha1.UserSyncGitlab{}),
+		newSyncCRD("UserSyncGitea.klum.cattle.io/v1alpha1", v1alpha1.UserSyncGitea{}),
+		newSyncCRD("UserSyncVault.klum.cattle.io/v1alpha1", v1alpha1.UserSyncVault{}),
+		newSyncCRD("UserSyncS3.klum.cattle.io/v1alpha1", v1alpha1.UserSyncS3{}),
+		newSyncCRD("UserSyncWebhook.klum.cattle.io/v1alpha1", v1alpha1.UserSyncWebhook{}),
+		newSyncCRD("UserSyncEmail.klum.cattle.io/v1alpha1", v1alpha1.UserSyncEmail{}),
+		newSyncCRD("UserSyncBitbucket.klum.cattle.io/v1alpha1", v1alpha1.UserSyncBitbucket{}),
+		newSyncCRD("UserSyncAWSSecret.klum.cattle.io/v1alpha1", v1alpha1.UserSyncAWSSecret{}),
+		newSyncCRD("UserSyncRemoteSecret.klum.cattle.io/v1alpha1", v1alpha1.UserSyncRemoteSecret{}),
 	).BatchWait()
 }
 
@@ -30,10 +39,43 @@ func newCRD(name string, obj interface{}) crd.CRD {
 		WithSchema(mustSchema(obj))
 }
 
+// newSyncCRD adds the columns showing when, and which revision of, the kubeconfig was last uploaded
+func newSyncCRD(name string, obj interface{}) crd.CRD {
+	c := newCRD(name, obj).
+		WithColumn("User", ".spec.user").
+		WithColumn("Ready", `.status.conditions[?(@.type=="Ready")].status`).
+		WithColumn("Revision", ".status.kubeconfigRevision")
+	c.Columns = append(c.Columns, v1.CustomResourceColumnDefinition{
+		Name:     "Last Upload",
+		Type:     "date",
+		JSONPath: ".status.lastUpload",
+	})
+	return c
+}
+
 func mustSchema(obj interface{}) *v1.JSONSchemaProps {
 	result, err := openapi.ToOpenAPIFromStruct(obj)
 	if err != nil {
 		panic(err)
 	}
+	preserveUnknownFields(result)
 	return result
 }
+
+// preserveUnknownFields keeps the content of free-form objects (e.g. kubeconfig extensions)
+// that would otherwise be pruned by the API server
+func preserveUnknownFields(props *v1.JSONSchemaProps) {
+	if props.Type == "object" && len(props.Properties) == 0 && props.AdditionalProperties == nil {
+		props.XPreserveUnknownFields = &[]bool{true}[0]
+	}
+	for name, prop := range props.Properties {
+		preserveUnknownFields(&prop)
+		props.Properties[name] = prop
+	}
+	if props.Items != nil && props.Items.Schema != nil {
+		preserveUnknownFields(props.Items.Schema)
+	}
+	if props.AdditionalProperties != nil && props.AdditionalProperties.Schema != nil {
+		preserveUnknownFields(props.AdditionalProperties.Schema)
+	}
+}
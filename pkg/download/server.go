@@ -0,0 +1,320 @@
+package download
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	"github.com/jadolg/klum/pkg/metrics"
+	"github.com/jadolg/klum/pkg/render"
+	log "github.com/sirupsen/logrus"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	pathPrefix        = "/kubeconfig/"
+	readHeaderTimeout = 10 * time.Second
+	shutdownTimeout   = 5 * time.Second
+)
+
+type Config struct {
+	Port                  int
+	TLSCertFile           string
+	TLSKeyFile            string
+	AuthProxyUserHeader   string
+	AuthProxyGroupsHeader string
+	// AuthProxyClientCAFile is the CA the client certificate of the authenticating proxy is verified
+	// with. The proxy headers are only trusted on requests with a verified certificate.
+	AuthProxyClientCAFile string
+	// AuthProxyAllowedNames are the common names accepted in the client certificate of the proxy,
+	// any name is accepted if empty
+	AuthProxyAllowedNames []string
+	AdminClusterRole      string
+}
+
+func (c *Config) Enabled() bool {
+	return c.Port != 0
+}
+
+func (c *Config) authProxyEnabled() bool {
+	return c.AuthProxyUserHeader != "" || c.AuthProxyGroupsHeader != ""
+}
+
+// Validate rejects trusting proxy headers that any client could set
+func (c *Config) Validate() error {
+	if !c.authProxyEnabled() {
+		return nil
+	}
+	if c.AuthProxyClientCAFile == "" {
+		return errors.New("the auth proxy headers of the download endpoint require --download-auth-proxy-client-ca")
+	}
+	if c.TLSCertFile == "" || c.TLSKeyFile == "" {
+		return errors.New("the auth proxy headers of the download endpoint require --download-tls-cert-file and --download-tls-key-file")
+	}
+	return nil
+}
+
+// KubeconfigGetter is the subset of the Kubeconfig cache used by the server
+type KubeconfigGetter interface {
+	Get(name string) (*klum.Kubeconfig, error)
+}
+
+// ClusterRoleBindingLister is the subset of the ClusterRoleBinding cache used by the server
+type ClusterRoleBindingLister interface {
+	List(selector labels.Selector) ([]*rbacv1.ClusterRoleBinding, error)
+}
+
+// TokenReviewer is the subset of the TokenReview client used by the server
+type TokenReviewer interface {
+	Create(ctx context.Context, tokenReview *authenticationv1.TokenReview, opts metav1.CreateOptions) (*authenticationv1.TokenReview, error)
+}
+
+type Server struct {
+	cfg                 Config
+	namespace           string
+	kubeconfigs         KubeconfigGetter
+	secrets             render.SecretGetter
+	clusterRoleBindings ClusterRoleBindingLister
+	tokenReviews        TokenReviewer
+}
+
+type identity struct {
+	username string
+	groups   []string
+}
+
+func NewServer(cfg Config, namespace string, kubeconfigs KubeconfigGetter, secrets render.SecretGetter, clusterRoleBindings ClusterRoleBindingLister, tokenReviews TokenReviewer) *Server {
+	return &Server{
+		cfg:                 cfg,
+		namespace:           namespace,
+		kubeconfigs:         kubeconfigs,
+		secrets:             secrets,
+		clusterRoleBindings: clusterRoleBindings,
+		tokenReviews:        tokenReviews,
+	}
+}
+
+// Start serves the download endpoint until ctx is done
+func (s *Server) Start(ctx context.Context) error {
+	if err := s.cfg.Validate(); err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(pathPrefix, s)
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%d", s.cfg.Port),
+		Handler:           mux,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	if s.cfg.AuthProxyClientCAFile != "" {
+		pem, err := os.ReadFile(s.cfg.AuthProxyClientCAFile)
+		if err != nil {
+			return fmt.Errorf("reading auth proxy client CA: %w", err)
+		}
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in auth proxy client CA %s", s.cfg.AuthProxyClientCAFile)
+		}
+		// Clients without a certificate still authenticate with a token
+		server.TLSConfig = &tls.Config{
+			ClientAuth: tls.VerifyClientCertIfGiven,
+			ClientCAs:  clientCAs,
+		}
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	var err error
+	if s.cfg.TLSCertFile != "" && s.cfg.TLSKeyFile != "" {
+		log.Printf("Starting kubeconfig download server with TLS on port %d", s.cfg.Port)
+		err = server.ListenAndServeTLS(s.cfg.TLSCertFile, s.cfg.TLSKeyFile)
+	} else {
+		log.Printf("Starting kubeconfig download server on port %d", s.cfg.Port)
+		err = server.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userName := strings.TrimPrefix(r.URL.Path, pathPrefix)
+	if userName == "" || strings.Contains(userName, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	id, err := s.authenticate(r)
+	if err != nil {
+		log.WithFields(log.Fields{"user": userName}).Warnf("Kubeconfig download not authenticated: %v", err)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	allowed, err := s.authorize(id, userName)
+	if err != nil {
+		metrics.ErrorsTotal.Inc()
+		log.Error(err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !allowed {
+		log.WithFields(log.Fields{"user": userName, "requester": id.username}).Warn("Kubeconfig download forbidden")
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	kubeconfig, err := s.kubeconfigs.Get(userName)
+	if apierrors.IsNotFound(err) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		metrics.ErrorsTotal.Inc()
+		log.Error(err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	kubeconfig, err = render.Kubeconfig(kubeconfig, s.secrets)
+	if err != nil {
+		metrics.ErrorsTotal.Inc()
+		log.Error(err)
+		http.Error(w, "kubeconfig is not ready yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	data, err := yaml.Marshal(kubeconfig.Spec)
+	if err != nil {
+		metrics.ErrorsTotal.Inc()
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	log.WithFields(log.Fields{"user": userName, "requester": id.username}).Info("Kubeconfig downloaded")
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", userName+".kubeconfig"))
+	_, _ = w.Write(data)
+}
+
+func (s *Server) authenticate(r *http.Request) (*identity, error) {
+	if s.cfg.AuthProxyUserHeader != "" && s.fromAuthProxy(r) {
+		if username := r.Header.Get(s.cfg.AuthProxyUserHeader); username != "" {
+			id := &identity{username: username}
+			if s.cfg.AuthProxyGroupsHeader != "" {
+				for _, group := range r.Header.Values(s.cfg.AuthProxyGroupsHeader) {
+					for _, g := range strings.Split(group, ",") {
+						if g = strings.TrimSpace(g); g != "" {
+							id.groups = append(id.groups, g)
+						}
+					}
+				}
+			}
+			return id, nil
+		}
+	}
+
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found || token == "" {
+		return nil, fmt.Errorf("no credentials provided")
+	}
+
+	review, err := s.tokenReviews.Create(r.Context(), &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if !review.Status.Authenticated {
+		return nil, fmt.Errorf("token rejected: %s", review.Status.Error)
+	}
+
+	return &identity{
+		username: review.Status.User.Username,
+		groups:   review.Status.User.Groups,
+	}, nil
+}
+
+// fromAuthProxy reports whether r was sent by the authenticating proxy, i.e. with a client
+// certificate verified with the proxy CA and one of the allowed common names
+func (s *Server) fromAuthProxy(r *http.Request) bool {
+	if s.cfg.AuthProxyClientCAFile == "" || r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return false
+	}
+	if len(s.cfg.AuthProxyAllowedNames) == 0 {
+		return true
+	}
+	commonName := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	return slices.Contains(s.cfg.AuthProxyAllowedNames, commonName)
+}
+
+func (s *Server) authorize(id *identity, userName string) (bool, error) {
+	if id.username == userName || id.username == serviceAccountUsername(s.namespace, userName) {
+		return true, nil
+	}
+
+	if s.cfg.AdminClusterRole == "" {
+		return false, nil
+	}
+
+	bindings, err := s.clusterRoleBindings.List(labels.Everything())
+	if err != nil {
+		return false, err
+	}
+	for _, binding := range bindings {
+		if binding.RoleRef.Kind != "ClusterRole" || binding.RoleRef.Name != s.cfg.AdminClusterRole {
+			continue
+		}
+		for _, subject := range binding.Subjects {
+			if subjectMatches(subject, id) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func serviceAccountUsername(namespace, name string) string {
+	return fmt.Sprintf("system:serviceaccount:%s:%s", namespace, name)
+}
+
+func subjectMatches(subject rbacv1.Subject, id *identity) bool {
+	switch subject.Kind {
+	case rbacv1.UserKind:
+		return subject.Name == id.username
+	case rbacv1.ServiceAccountKind:
+		return serviceAccountUsername(subject.Namespace, subject.Name) == id.username
+	case rbacv1.GroupKind:
+		for _, group := range id.groups {
+			if group == subject.Name {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,240 @@
+package download
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type fakeKubeconfigs map[string]*klum.Kubeconfig
+
+func (f fakeKubeconfigs) Get(name string) (*klum.Kubeconfig, error) {
+	if kc, ok := f[name]; ok {
+		return kc, nil
+	}
+	return nil, errors.NewNotFound(schema.GroupResource{Group: "klum.cattle.io", Resource: "kubeconfigs"}, name)
+}
+
+type fakeSecrets map[string]*v1.Secret
+
+func (f fakeSecrets) Get(namespace, name string) (*v1.Secret, error) {
+	if secret, ok := f[namespace+"/"+name]; ok {
+		return secret, nil
+	}
+	return nil, errors.NewNotFound(schema.GroupResource{Resource: "secrets"}, name)
+}
+
+type fakeClusterRoleBindings []*rbacv1.ClusterRoleBinding
+
+func (f fakeClusterRoleBindings) List(selector labels.Selector) ([]*rbacv1.ClusterRoleBinding, error) {
+	return f, nil
+}
+
+// fakeTokenReviews authenticates tokens of the form "token-<username>"
+type fakeTokenReviews map[string]authenticationv1.UserInfo
+
+func (f fakeTokenReviews) Create(ctx context.Context, tokenReview *authenticationv1.TokenReview, opts metav1.CreateOptions) (*authenticationv1.TokenReview, error) {
+	result := tokenReview.DeepCopy()
+	if user, ok := f[tokenReview.Spec.Token]; ok {
+		result.Status.Authenticated = true
+		result.Status.User = user
+	}
+	return result, nil
+}
+
+func newTestServer(cfg Config) *Server {
+	kubeconfigs := fakeKubeconfigs{
+		"darren": {
+			ObjectMeta: metav1.ObjectMeta{Name: "darren"},
+			Spec: klum.KubeconfigSpec{
+				AuthInfos: []klum.NamedAuthInfo{
+					{
+						Name: "darren",
+						AuthInfo: klum.AuthInfo{
+							TokenSecretRef: &klum.SecretKeyReference{Namespace: "klum", Name: "darren"},
+						},
+					},
+				},
+			},
+		},
+	}
+	secrets := fakeSecrets{
+		"klum/darren": {Data: map[string][]byte{"token": []byte("secret-token")}},
+	}
+	bindings := fakeClusterRoleBindings{
+		{
+			RoleRef:  rbacv1.RoleRef{Kind: "ClusterRole", Name: "cluster-admin"},
+			Subjects: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "admins"}},
+		},
+	}
+	reviews := fakeTokenReviews{
+		"token-darren": {Username: "system:serviceaccount:klum:darren"},
+		"token-other":  {Username: "system:serviceaccount:klum:other"},
+		"token-admin":  {Username: "alice", Groups: []string{"admins"}},
+	}
+	return NewServer(cfg, "klum", kubeconfigs, secrets, bindings, reviews)
+}
+
+func TestServeHTTP(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      Config
+		path     string
+		headers  map[string]string
+		proxyCN  string
+		expected int
+	}{
+		{
+			name:     "no credentials",
+			path:     "/kubeconfig/darren",
+			expected: http.StatusUnauthorized,
+		},
+		{
+			name:     "invalid token",
+			path:     "/kubeconfig/darren",
+			headers:  map[string]string{"Authorization": "Bearer nope"},
+			expected: http.StatusUnauthorized,
+		},
+		{
+			name:     "own kubeconfig",
+			path:     "/kubeconfig/darren",
+			headers:  map[string]string{"Authorization": "Bearer token-darren"},
+			expected: http.StatusOK,
+		},
+		{
+			name:     "someone else's kubeconfig",
+			path:     "/kubeconfig/darren",
+			headers:  map[string]string{"Authorization": "Bearer token-other"},
+			expected: http.StatusForbidden,
+		},
+		{
+			name:     "admin",
+			cfg:      Config{AdminClusterRole: "cluster-admin"},
+			path:     "/kubeconfig/darren",
+			headers:  map[string]string{"Authorization": "Bearer token-admin"},
+			expected: http.StatusOK,
+		},
+		{
+			name:     "admin without admin cluster role configured",
+			path:     "/kubeconfig/darren",
+			headers:  map[string]string{"Authorization": "Bearer token-admin"},
+			expected: http.StatusForbidden,
+		},
+		{
+			name:     "auth proxy header",
+			cfg:      authProxyConfig(),
+			path:     "/kubeconfig/darren",
+			headers:  map[string]string{"X-Remote-User": "darren"},
+			proxyCN:  "front-proxy",
+			expected: http.StatusOK,
+		},
+		{
+			name:     "auth proxy header without client certificate",
+			cfg:      authProxyConfig(),
+			path:     "/kubeconfig/darren",
+			headers:  map[string]string{"X-Remote-User": "darren"},
+			expected: http.StatusUnauthorized,
+		},
+		{
+			name:     "auth proxy header with a client certificate not allowed",
+			cfg:      authProxyConfig(),
+			path:     "/kubeconfig/darren",
+			headers:  map[string]string{"X-Remote-User": "darren"},
+			proxyCN:  "someone-else",
+			expected: http.StatusUnauthorized,
+		},
+		{
+			name:     "auth proxy header ignored when not configured",
+			path:     "/kubeconfig/darren",
+			headers:  map[string]string{"X-Remote-User": "darren"},
+			proxyCN:  "front-proxy",
+			expected: http.StatusUnauthorized,
+		},
+		{
+			name: "auth proxy groups",
+			cfg: func() Config {
+				cfg := authProxyConfig()
+				cfg.AuthProxyGroupsHeader = "X-Remote-Group"
+				cfg.AdminClusterRole = "cluster-admin"
+				return cfg
+			}(),
+			path:     "/kubeconfig/darren",
+			headers:  map[string]string{"X-Remote-User": "bob", "X-Remote-Group": "devs, admins"},
+			proxyCN:  "front-proxy",
+			expected: http.StatusOK,
+		},
+		{
+			name:     "unknown user",
+			cfg:      Config{AdminClusterRole: "cluster-admin"},
+			path:     "/kubeconfig/nobody",
+			headers:  map[string]string{"Authorization": "Bearer token-admin"},
+			expected: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(tt.cfg)
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			if tt.proxyCN != "" {
+				// The TLS handshake verified the client certificate of the proxy
+				req.TLS = &tls.ConnectionState{
+					VerifiedChains: [][]*x509.Certificate{
+						{{Subject: pkix.Name{CommonName: tt.proxyCN}}},
+					},
+				}
+			}
+			rec := httptest.NewRecorder()
+
+			server.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expected, rec.Code)
+			if tt.expected == http.StatusOK {
+				assert.Equal(t, "application/yaml", rec.Header().Get("Content-Type"))
+				assert.Contains(t, rec.Body.String(), "token: secret-token")
+				assert.NotContains(t, rec.Body.String(), "tokenSecretRef")
+			}
+		})
+	}
+}
+
+func authProxyConfig() Config {
+	return Config{
+		TLSCertFile:           "tls.crt",
+		TLSKeyFile:            "tls.key",
+		AuthProxyUserHeader:   "X-Remote-User",
+		AuthProxyClientCAFile: "proxy-ca.crt",
+		AuthProxyAllowedNames: []string{"front-proxy"},
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	cfg := authProxyConfig()
+	assert.NoError(t, cfg.Validate())
+
+	cfg.AuthProxyClientCAFile = ""
+	assert.Error(t, cfg.Validate(), "proxy headers must not be trusted without a client CA")
+
+	cfg = authProxyConfig()
+	cfg.TLSCertFile = ""
+	assert.Error(t, cfg.Validate(), "client certificates require TLS")
+
+	assert.NoError(t, (&Config{Port: 8080}).Validate())
+}
@@ -0,0 +1,220 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	defaultSubject = "Your kubeconfig for {{.User}}"
+	defaultBody    = `Hello {{.User}},
+
+{{if .Rotated}}Your credentials were rotated. The previous kubeconfig no longer works.
+{{else}}An account was created for you.
+{{end}}
+Your kubeconfig is attached as {{.Attachment}}, encrypted for your {{.Encryption}} public key.
+Decrypt it with{{if eq .Encryption "age"}} "age --decrypt -i <your identity file> -o kubeconfig {{.Attachment}}"{{else}} "gpg --decrypt --output kubeconfig {{.Attachment}}"{{end}}
+and use it with "kubectl --kubeconfig kubeconfig get pods".
+`
+)
+
+type Config struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	// SubjectTemplate and BodyTemplateFile are Go templates for the message, see TemplateData
+	SubjectTemplate  string
+	BodyTemplateFile string
+	// RateLimit is the maximum number of emails sent per minute
+	RateLimit int
+}
+
+func (c *Config) Enabled() bool {
+	return c.Host != "" && c.From != ""
+}
+
+// TemplateData holds the fields available to the subject and body templates
+type TemplateData struct {
+	User       string
+	Email      string
+	Attachment string
+	// Encryption is age or pgp
+	Encryption string
+	// Rotated is false when the kubeconfig is sent for the first time
+	Rotated bool
+}
+
+type templates struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+func parseTemplates(cfg Config) (*templates, error) {
+	subject := cfg.SubjectTemplate
+	if subject == "" {
+		subject = defaultSubject
+	}
+	body := defaultBody
+	if cfg.BodyTemplateFile != "" {
+		data, err := os.ReadFile(cfg.BodyTemplateFile)
+		if err != nil {
+			return nil, err
+		}
+		body = string(data)
+	}
+
+	subjectTemplate, err := template.New("subject").Option("missingkey=error").Parse(subject)
+	if err != nil {
+		return nil, fmt.Errorf("invalid email subject template: %w", err)
+	}
+	bodyTemplate, err := template.New("body").Option("missingkey=error").Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid email body template: %w", err)
+	}
+	return &templates{subject: subjectTemplate, body: bodyTemplate}, nil
+}
+
+func (t *templates) render(data *TemplateData) (string, string, error) {
+	subject := &strings.Builder{}
+	if err := t.subject.Execute(subject, data); err != nil {
+		return "", "", err
+	}
+	body := &strings.Builder{}
+	if err := t.body.Execute(body, data); err != nil {
+		return "", "", err
+	}
+	return strings.TrimSpace(subject.String()), body.String(), nil
+}
+
+// message builds a multipart MIME message with a text body and one attachment
+func message(from, to, subject, body, attachmentName string, attachment []byte, date time.Time) ([]byte, error) {
+	out := &bytes.Buffer{}
+	w := multipart.NewWriter(out)
+
+	fmt.Fprintf(out, "From: %s\r\n", from)
+	fmt.Fprintf(out, "To: %s\r\n", to)
+	fmt.Fprintf(out, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(out, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	fmt.Fprintf(out, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(out, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", w.Boundary())
+
+	text, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeBase64(text, []byte(body)); err != nil {
+		return nil, err
+	}
+
+	file, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"application/octet-stream"},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachmentName})},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeBase64(file, attachment); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// writeBase64 writes data base64 encoded in lines of 76 characters as required by RFC 2045
+func writeBase64(w interface{ Write([]byte) (int, error) }, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 0 {
+		n := min(76, len(encoded))
+		if _, err := fmt.Fprintf(w, "%s\r\n", encoded[:n]); err != nil {
+			return err
+		}
+		encoded = encoded[n:]
+	}
+	return nil
+}
+
+// sendMail sends msg like smtp.SendMail, but the connection is bound to ctx so a server that
+// stops answering doesn't block the worker
+func sendMail(ctx context.Context, addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+	// Cancelling ctx unblocks reads and writes in progress
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if a != nil {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return fmt.Errorf("smtp server %s doesn't support AUTH", host)
+		}
+		if err := client.Auth(a); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := client.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,80 @@
+package email
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// smtpServer answers the commands of one client, or never greets it when silent
+func smtpServer(t *testing.T, silent bool) (string, <-chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if silent {
+			_, _ = bufio.NewReader(conn).ReadString('\n')
+			return
+		}
+
+		text := textproto.NewConn(conn)
+		_ = text.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			switch command := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); command {
+			case "EHLO":
+				_ = text.PrintfLine("250 localhost")
+			case "DATA":
+				_ = text.PrintfLine("354 go ahead")
+				data, err := text.ReadDotLines()
+				if err != nil {
+					return
+				}
+				received <- strings.Join(data, "\n")
+				_ = text.PrintfLine("250 queued")
+			case "QUIT":
+				_ = text.PrintfLine("221 bye")
+				return
+			default:
+				_ = text.PrintfLine("250 ok")
+			}
+		}
+	}()
+	return listener.Addr().String(), received
+}
+
+func TestSendMail(t *testing.T) {
+	addr, received := smtpServer(t, false)
+
+	err := sendMail(context.Background(), addr, nil, "klum@example.com", []string{"darren@example.com"}, []byte("Subject: test\r\n\r\nbody\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "Subject: test\n\nbody", <-received)
+}
+
+func TestSendMailDeadline(t *testing.T) {
+	addr, _ := smtpServer(t, true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := sendMail(ctx, addr, nil, "klum@example.com", []string{"darren@example.com"}, []byte("body"))
+	assert.Error(t, err, "a server that doesn't answer fails the delivery")
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
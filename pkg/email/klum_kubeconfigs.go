@@ -0,0 +1,133 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	"github.com/jadolg/klum/pkg/encrypt"
+	"github.com/jadolg/klum/pkg/usersync"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+const defaultRateLimit = 10
+
+// Provider emails encrypted kubeconfigs to users
+type Provider struct {
+	cfg       Config
+	templates *templates
+	limiter   *rate.Limiter
+	// send is sendMail, replaced in tests
+	send func(ctx context.Context, addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewProvider returns an email provider, it fails when the templates in cfg are invalid
+func NewProvider(cfg Config) (*Provider, error) {
+	templates, err := parseTemplates(cfg)
+	if err != nil {
+		return nil, err
+	}
+	rateLimit := cfg.RateLimit
+	if rateLimit <= 0 {
+		rateLimit = defaultRateLimit
+	}
+	return &Provider{
+		cfg:       cfg,
+		templates: templates,
+		limiter:   rate.NewLimiter(rate.Every(time.Minute/time.Duration(rateLimit)), rateLimit),
+		send:      sendMail,
+	}, nil
+}
+
+func (p *Provider) Name() string {
+	return "email"
+}
+
+func (p *Provider) Enabled() bool {
+	return p.cfg.Enabled()
+}
+
+// Upload emails the kubeconfig. The handler only calls it for new content, so every
+// issued or rotated kubeconfig is sent once.
+func (p *Provider) Upload(ctx context.Context, sync usersync.Object, payload []byte) error {
+	userSync, err := asUserSyncEmail(sync)
+	if err != nil {
+		return err
+	}
+	spec := userSync.Spec
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+
+	encrypted, err := encrypt.Encrypt(spec.PublicKey, payload)
+	if err != nil {
+		return err
+	}
+
+	data := &TemplateData{
+		User:       spec.User,
+		Email:      spec.Email,
+		Attachment: spec.User + ".kubeconfig" + encrypt.Extension(spec.PublicKey),
+		Encryption: "age",
+		Rotated:    usersync.LastUploadHash(userSync.Status, sync, p.Name()) != "",
+	}
+	if encrypt.Extension(spec.PublicKey) == ".asc" {
+		data.Encryption = "pgp"
+	}
+	subject, body, err := p.templates.render(data)
+	if err != nil {
+		return err
+	}
+
+	msg, err := message(p.cfg.From, spec.Email, subject, body, data.Attachment, encrypted, time.Now())
+	if err != nil {
+		return err
+	}
+
+	// Waiting for the limiter would block the worker, the sync is requeued instead
+	reservation := p.limiter.Reserve()
+	if delay := reservation.Delay(); delay > 0 {
+		reservation.Cancel()
+		return &usersync.RetryAfterError{Err: fmt.Errorf("email rate limit reached"), After: delay}
+	}
+
+	log.WithFields(log.Fields{
+		"user":    spec.User,
+		"email":   spec.Email,
+		"rotated": data.Rotated,
+	}).Info("Sending kubeconfig by email")
+
+	var auth smtp.Auth
+	if p.cfg.Username != "" {
+		auth = smtp.PlainAuth("", p.cfg.Username, p.cfg.Password, p.cfg.Host)
+	}
+	return p.send(ctx, net.JoinHostPort(p.cfg.Host, strconv.Itoa(p.cfg.Port)), auth, p.cfg.From, []string{spec.Email}, msg)
+}
+
+// Delete does nothing, sent emails can't be taken back. Revoking the credentials invalidates the kubeconfig.
+func (p *Provider) Delete(ctx context.Context, sync usersync.Object) error {
+	return nil
+}
+
+// Verify always reports true, there is no way to check a mailbox
+func (p *Provider) Verify(ctx context.Context, sync usersync.Object) (bool, error) {
+	return true, nil
+}
+
+// DetectsDrift is false, delivered emails can't be read back
+func (p *Provider) DetectsDrift() bool {
+	return false
+}
+
+func asUserSyncEmail(sync usersync.Object) (*klum.UserSyncEmail, error) {
+	userSync, ok := sync.(*klum.UserSyncEmail)
+	if !ok {
+		return nil, fmt.Errorf("email provider can't synchronize %T", sync)
+	}
+	return userSync, nil
+}
@@ -0,0 +1,198 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	"github.com/jadolg/klum/pkg/usersync"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type sentEmail struct {
+	addr string
+	auth smtp.Auth
+	from string
+	to   []string
+	msg  []byte
+}
+
+type parsedEmail struct {
+	subject        string
+	body           string
+	attachmentName string
+	attachment     []byte
+}
+
+func newTestProvider(t *testing.T, cfg Config) (*Provider, *[]sentEmail) {
+	cfg.Host = "smtp.example.com"
+	cfg.Port = 587
+	cfg.From = "klum@example.com"
+	provider, err := NewProvider(cfg)
+	require.NoError(t, err)
+
+	sent := &[]sentEmail{}
+	provider.send = func(ctx context.Context, addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		*sent = append(*sent, sentEmail{addr: addr, auth: a, from: from, to: to, msg: msg})
+		return nil
+	}
+	return provider, sent
+}
+
+func newTestSync(t *testing.T) (*klum.UserSyncEmail, *age.X25519Identity) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	return &klum.UserSyncEmail{
+		ObjectMeta: metav1.ObjectMeta{Name: "darren-email"},
+		Spec: klum.UserSyncEmailSpec{
+			User:      "darren",
+			Email:     "darren@example.com",
+			PublicKey: identity.Recipient().String(),
+		},
+	}, identity
+}
+
+func parse(t *testing.T, data []byte) *parsedEmail {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	require.NoError(t, err)
+	result := &parsedEmail{subject: subject}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	require.Equal(t, "multipart/mixed", mediaType)
+
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, part))
+		require.NoError(t, err)
+		if part.FileName() != "" {
+			result.attachmentName = part.FileName()
+			result.attachment = data
+		} else {
+			result.body = string(data)
+		}
+	}
+	return result
+}
+
+func TestUpload(t *testing.T) {
+	provider, sent := newTestProvider(t, Config{Username: "klum", Password: "secret"})
+	sync, identity := newTestSync(t)
+
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	require.Len(t, *sent, 1)
+	assert.Equal(t, "smtp.example.com:587", (*sent)[0].addr)
+	assert.Equal(t, "klum@example.com", (*sent)[0].from)
+	assert.Equal(t, []string{"darren@example.com"}, (*sent)[0].to)
+	assert.NotNil(t, (*sent)[0].auth)
+
+	email := parse(t, (*sent)[0].msg)
+	assert.Equal(t, "Your kubeconfig for darren", email.subject)
+	assert.Contains(t, email.body, "An account was created for you")
+	assert.Contains(t, email.body, "age --decrypt")
+	assert.Equal(t, "darren.kubeconfig.age", email.attachmentName)
+
+	plaintext, err := age.Decrypt(bytes.NewReader(email.attachment), identity)
+	require.NoError(t, err)
+	data, err := io.ReadAll(plaintext)
+	require.NoError(t, err)
+	assert.Equal(t, "kubeconfig", string(data))
+}
+
+func TestUploadRotated(t *testing.T) {
+	provider, sent := newTestProvider(t, Config{})
+	sync, _ := newTestSync(t)
+	sync.Status.Hash = "hash"
+
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	require.Len(t, *sent, 1)
+	assert.Nil(t, (*sent)[0].auth, "no authentication without username")
+	assert.Contains(t, parse(t, (*sent)[0].msg).body, "Your credentials were rotated")
+}
+
+func TestCustomTemplates(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "body.tmpl")
+	require.NoError(t, os.WriteFile(file, []byte("{{.User}} <{{.Email}}>: {{.Attachment}} rotated={{.Rotated}}"), 0o600))
+
+	provider, sent := newTestProvider(t, Config{
+		SubjectTemplate:  "[cluster] {{.User}}",
+		BodyTemplateFile: file,
+	})
+	sync, _ := newTestSync(t)
+
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	email := parse(t, (*sent)[0].msg)
+	assert.Equal(t, "[cluster] darren", email.subject)
+	assert.Equal(t, "darren <darren@example.com>: darren.kubeconfig.age rotated=false", email.body)
+}
+
+func TestInvalidTemplates(t *testing.T) {
+	_, err := NewProvider(Config{SubjectTemplate: "{{.User"})
+	assert.Error(t, err)
+
+	_, err = NewProvider(Config{BodyTemplateFile: filepath.Join(t.TempDir(), "missing")})
+	assert.Error(t, err)
+
+	provider, sent := newTestProvider(t, Config{SubjectTemplate: "{{.Missing}}"})
+	sync, _ := newTestSync(t)
+	assert.Error(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	assert.Empty(t, *sent)
+}
+
+func TestUploadRequiresPublicKey(t *testing.T) {
+	provider, sent := newTestProvider(t, Config{})
+	sync, _ := newTestSync(t)
+	sync.Spec.PublicKey = ""
+
+	assert.Error(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	assert.Empty(t, *sent)
+}
+
+func TestUploadRequiresValidEmail(t *testing.T) {
+	provider, sent := newTestProvider(t, Config{})
+	sync, _ := newTestSync(t)
+
+	for _, email := range []string{"darren", "Darren <darren@example.com>", "darren@example.com\r\nBcc: eve@example.com"} {
+		sync.Spec.Email = email
+		assert.Error(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")), email)
+	}
+	assert.Empty(t, *sent)
+}
+
+func TestRateLimit(t *testing.T) {
+	provider, sent := newTestProvider(t, Config{RateLimit: 1})
+	sync, _ := newTestSync(t)
+
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+
+	err := provider.Upload(context.Background(), sync, []byte("rotated"))
+	var retryAfter *usersync.RetryAfterError
+	require.ErrorAs(t, err, &retryAfter, "the second email is requeued instead of waiting for the limiter")
+	assert.InDelta(t, time.Minute, retryAfter.After, float64(time.Second))
+	assert.Len(t, *sent, 1)
+
+	// The cancelled reservation doesn't push the next email further back
+	require.ErrorAs(t, provider.Upload(context.Background(), sync, []byte("rotated")), &retryAfter)
+	assert.InDelta(t, time.Minute, retryAfter.After, float64(time.Second))
+}
@@ -0,0 +1,108 @@
+// Package encrypt encrypts kubeconfigs for a recipient before they leave the cluster.
+// Recipients are age X25519 public keys (age1...) or ASCII armored PGP public keys.
+package encrypt
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+const (
+	agePrefix = "age1"
+	pgpHeader = "-----BEGIN PGP PUBLIC KEY BLOCK-----"
+)
+
+// Validate checks that publicKey is a supported recipient
+func Validate(publicKey string) error {
+	_, err := encrypter(publicKey)
+	return err
+}
+
+// Encrypt encrypts plaintext for publicKey. age recipients produce binary age files,
+// PGP recipients produce ASCII armored messages.
+func Encrypt(publicKey string, plaintext []byte) ([]byte, error) {
+	encrypt, err := encrypter(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return encrypt(plaintext)
+}
+
+// Extension returns the file extension matching the output of Encrypt for publicKey
+func Extension(publicKey string) string {
+	if isPGP(publicKey) {
+		return ".asc"
+	}
+	return ".age"
+}
+
+func isPGP(publicKey string) bool {
+	return strings.HasPrefix(strings.TrimSpace(publicKey), pgpHeader)
+}
+
+func encrypter(publicKey string) (func([]byte) ([]byte, error), error) {
+	publicKey = strings.TrimSpace(publicKey)
+	switch {
+	case strings.HasPrefix(publicKey, agePrefix):
+		recipient, err := age.ParseX25519Recipient(publicKey)
+		if err != nil {
+			return nil, err
+		}
+		return func(plaintext []byte) ([]byte, error) {
+			return encryptAge(recipient, plaintext)
+		}, nil
+	case isPGP(publicKey):
+		entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(publicKey))
+		if err != nil {
+			return nil, err
+		}
+		return func(plaintext []byte) ([]byte, error) {
+			return encryptPGP(entities, plaintext)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key, expected an age (%s...) or an armored PGP public key", agePrefix)
+	}
+}
+
+func encryptAge(recipient age.Recipient, plaintext []byte) ([]byte, error) {
+	out := &bytes.Buffer{}
+	w, err := age.Encrypt(out, recipient)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func encryptPGP(entities openpgp.EntityList, plaintext []byte) ([]byte, error) {
+	out := &bytes.Buffer{}
+	armored, err := armor.Encode(out, "PGP MESSAGE", nil)
+	if err != nil {
+		return nil, err
+	}
+	w, err := openpgp.Encrypt(armored, entities, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(w, bytes.NewReader(plaintext)); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	if err := armored.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,62 @@
+package encrypt
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncrypt_Age(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	publicKey := identity.Recipient().String()
+
+	require.NoError(t, Validate(publicKey))
+	assert.Equal(t, ".age", Extension(publicKey))
+
+	ciphertext, err := Encrypt(publicKey, []byte("kubeconfig"))
+	require.NoError(t, err)
+
+	r, err := age.Decrypt(bytes.NewReader(ciphertext), identity)
+	require.NoError(t, err)
+	plaintext, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "kubeconfig", string(plaintext))
+}
+
+func TestEncrypt_PGP(t *testing.T) {
+	entity, err := openpgp.NewEntity("klum", "", "klum@example.com", nil)
+	require.NoError(t, err)
+	publicKey := &bytes.Buffer{}
+	w, err := armor.Encode(publicKey, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(w))
+	require.NoError(t, w.Close())
+
+	require.NoError(t, Validate(publicKey.String()))
+	assert.Equal(t, ".asc", Extension(publicKey.String()))
+
+	ciphertext, err := Encrypt(publicKey.String(), []byte("kubeconfig"))
+	require.NoError(t, err)
+
+	block, err := armor.Decode(bytes.NewReader(ciphertext))
+	require.NoError(t, err)
+	md, err := openpgp.ReadMessage(block.Body, openpgp.EntityList{entity}, nil, nil)
+	require.NoError(t, err)
+	plaintext, err := io.ReadAll(md.UnverifiedBody)
+	require.NoError(t, err)
+	assert.Equal(t, "kubeconfig", string(plaintext))
+}
+
+func TestValidate_Invalid(t *testing.T) {
+	assert.Error(t, Validate(""))
+	assert.Error(t, Validate("ssh-ed25519 AAAA"))
+	assert.Error(t, Validate("age1invalid"))
+	assert.Error(t, Validate("-----BEGIN PGP PUBLIC KEY BLOCK-----\n\ngarbage\n-----END PGP PUBLIC KEY BLOCK-----"))
+}
@@ -33,7 +33,16 @@ func init() {
 type Interface interface {
 	Kubeconfig() KubeconfigController
 	User() UserController
+	UserSyncAWSSecret() UserSyncAWSSecretController
+	UserSyncBitbucket() UserSyncBitbucketController
+	UserSyncEmail() UserSyncEmailController
+	UserSyncGitea() UserSyncGiteaController
 	UserSyncGithub() UserSyncGithubController
+	UserSyncGitlab() UserSyncGitlabController
+	UserSyncRemoteSecret() UserSyncRemoteSecretController
+	UserSyncS3() UserSyncS3Controller
+	UserSyncVault() UserSyncVaultController
+	UserSyncWebhook() UserSyncWebhookController
 }
 
 func New(controllerFactory controller.SharedControllerFactory) Interface {
@@ -54,6 +63,42 @@ func (v *version) User() UserController {
 	return generic.NewNonNamespacedController[*v1alpha1.User, *v1alpha1.UserList](schema.GroupVersionKind{Group: "klum.cattle.io", Version: "v1alpha1", Kind: "User"}, "users", v.controllerFactory)
 }
 
+func (v *version) UserSyncAWSSecret() UserSyncAWSSecretController {
+	return generic.NewNonNamespacedController[*v1alpha1.UserSyncAWSSecret, *v1alpha1.UserSyncAWSSecretList](schema.GroupVersionKind{Group: "klum.cattle.io", Version: "v1alpha1", Kind: "UserSyncAWSSecret"}, "usersyncawssecrets", v.controllerFactory)
+}
+
+func (v *version) UserSyncBitbucket() UserSyncBitbucketController {
+	return generic.NewNonNamespacedController[*v1alpha1.UserSyncBitbucket, *v1alpha1.UserSyncBitbucketList](schema.GroupVersionKind{Group: "klum.cattle.io", Version: "v1alpha1", Kind: "UserSyncBitbucket"}, "usersyncbitbuckets", v.controllerFactory)
+}
+
+func (v *version) UserSyncEmail() UserSyncEmailController {
+	return generic.NewNonNamespacedController[*v1alpha1.UserSyncEmail, *v1alpha1.UserSyncEmailList](schema.GroupVersionKind{Group: "klum.cattle.io", Version: "v1alpha1", Kind: "UserSyncEmail"}, "usersyncemails", v.controllerFactory)
+}
+
+func (v *version) UserSyncGitea() UserSyncGiteaController {
+	return generic.NewNonNamespacedController[*v1alpha1.UserSyncGitea, *v1alpha1.UserSyncGiteaList](schema.GroupVersionKind{Group: "klum.cattle.io", Version: "v1alpha1", Kind: "UserSyncGitea"}, "usersyncgiteas", v.controllerFactory)
+}
+
 func (v *version) UserSyncGithub() UserSyncGithubController {
 	return generic.NewNonNamespacedController[*v1alpha1.UserSyncGithub, *v1alpha1.UserSyncGithubList](schema.GroupVersionKind{Group: "klum.cattle.io", Version: "v1alpha1", Kind: "UserSyncGithub"}, "usersyncgithubs", v.controllerFactory)
 }
+
+func (v *version) UserSyncGitlab() UserSyncGitlabController {
+	return generic.NewNonNamespacedController[*v1alpha1.UserSyncGitlab, *v1alpha1.UserSyncGitlabList](schema.GroupVersionKind{Group: "klum.cattle.io", Version: "v1alpha1", Kind: "UserSyncGitlab"}, "usersyncgitlabs", v.controllerFactory)
+}
+
+func (v *version) UserSyncRemoteSecret() UserSyncRemoteSecretController {
+	return generic.NewNonNamespacedController[*v1alpha1.UserSyncRemoteSecret, *v1alpha1.UserSyncRemoteSecretList](schema.GroupVersionKind{Group: "klum.cattle.io", Version: "v1alpha1", Kind: "UserSyncRemoteSecret"}, "usersyncremotesecrets", v.controllerFactory)
+}
+
+func (v *version) UserSyncS3() UserSyncS3Controller {
+	return generic.NewNonNamespacedController[*v1alpha1.UserSyncS3, *v1alpha1.UserSyncS3List](schema.GroupVersionKind{Group: "klum.cattle.io", Version: "v1alpha1", Kind: "UserSyncS3"}, "usersyncs3s", v.controllerFactory)
+}
+
+func (v *version) UserSyncVault() UserSyncVaultController {
+	return generic.NewNonNamespacedController[*v1alpha1.UserSyncVault, *v1alpha1.UserSyncVaultList](schema.GroupVersionKind{Group: "klum.cattle.io", Version: "v1alpha1", Kind: "UserSyncVault"}, "usersyncvaults", v.controllerFactory)
+}
+
+func (v *version) UserSyncWebhook() UserSyncWebhookController {
+	return generic.NewNonNamespacedController[*v1alpha1.UserSyncWebhook, *v1alpha1.UserSyncWebhookList](schema.GroupVersionKind{Group: "klum.cattle.io", Version: "v1alpha1", Kind: "UserSyncWebhook"}, "usersyncwebhooks", v.controllerFactory)
+}
@@ -0,0 +1,134 @@
+//go:build e2e
+
+package gitea
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jadolg/klum/pkg/apiclient"
+	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const appIni = `APP_NAME = klum e2e
+RUN_MODE = prod
+WORK_PATH = {{dir}}
+I_AM_BEING_UNSAFE_RUNNING_AS_ROOT = true
+
+[server]
+HTTP_ADDR = 127.0.0.1
+HTTP_PORT = {{port}}
+ROOT_URL = http://127.0.0.1:{{port}}/
+OFFLINE_MODE = true
+DISABLE_SSH = true
+
+[database]
+DB_TYPE = sqlite3
+PATH = {{dir}}/gitea.db
+
+[repository]
+ROOT = {{dir}}/repositories
+
+[security]
+INSTALL_LOCK = true
+
+[actions]
+ENABLED = true
+
+[log]
+LEVEL = Warn
+ROOT_PATH = {{dir}}/log
+`
+
+// startGitea runs the binary in GITEA_BINARY (a Gitea or Forgejo binary) with a fresh sqlite database
+// and returns its URL and an admin token
+func startGitea(t *testing.T) (string, string) {
+	binary := os.Getenv("GITEA_BINARY")
+	if binary == "" {
+		t.Skip("GITEA_BINARY is not set")
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := fmt.Sprint(listener.Addr().(*net.TCPAddr).Port)
+	require.NoError(t, listener.Close())
+
+	dir := t.TempDir()
+	config := filepath.Join(dir, "app.ini")
+	require.NoError(t, os.WriteFile(config, []byte(strings.NewReplacer("{{dir}}", dir, "{{port}}", port).Replace(appIni)), 0o600))
+
+	run := func(args ...string) string {
+		cmd := exec.Command(binary, append(args, "--config", config)...)
+		cmd.Dir = dir
+		output, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(output))
+		return strings.TrimSpace(string(output))
+	}
+	run("migrate")
+	run("admin", "user", "create", "--admin", "--username", "klum", "--password", "klum-e2e-password",
+		"--email", "klum@example.com", "--must-change-password=false")
+	token := run("admin", "user", "generate-access-token", "--username", "klum", "--token-name", "e2e",
+		"--scopes", "all", "--raw")
+
+	server := exec.Command(binary, "web", "--config", config)
+	server.Dir = dir
+	require.NoError(t, server.Start())
+	t.Cleanup(func() {
+		_ = server.Process.Kill()
+		_ = server.Wait()
+	})
+
+	url := "http://127.0.0.1:" + port
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(url + "/api/healthz")
+		if err != nil {
+			return false
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, time.Minute, 250*time.Millisecond, "gitea didn't start")
+	return url, token
+}
+
+func TestE2E_Secrets(t *testing.T) {
+	url, token := startGitea(t)
+	ctx := context.Background()
+
+	admin := apiclient.NewClient("gitea", url+"/api/v1", func(req *http.Request) {
+		req.Header.Set("Authorization", "token "+token)
+	})
+	require.NoError(t, admin.Do(ctx, http.MethodPost, "/user/repos", map[string]string{"name": "klum-example"}, nil))
+	require.NoError(t, admin.Do(ctx, http.MethodPost, "/orgs", map[string]string{"username": "platform"}, nil))
+
+	provider := NewProvider(Config{BaseURL: url, Token: token})
+	for _, spec := range []klum.GiteaSyncSpec{
+		{Owner: "klum", Repository: "klum-example", SecretName: "kube_config"},
+		{Owner: "platform", SecretName: "KUBE_CONFIG"},
+	} {
+		t.Run(spec.Owner, func(t *testing.T) {
+			sync := newTestSync(spec)
+
+			require.NoError(t, provider.Upload(ctx, sync, []byte("kubeconfig")))
+			exists, err := provider.Verify(ctx, sync)
+			require.NoError(t, err)
+			assert.True(t, exists)
+
+			require.NoError(t, provider.Upload(ctx, sync, []byte("rotated")), "existing secrets are replaced")
+
+			require.NoError(t, provider.Delete(ctx, sync))
+			exists, err = provider.Verify(ctx, sync)
+			require.NoError(t, err)
+			assert.False(t, exists)
+		})
+	}
+}
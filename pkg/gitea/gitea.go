@@ -0,0 +1,84 @@
+package gitea
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jadolg/klum/pkg/apiclient"
+)
+
+// listPageSize is the number of secrets requested per page, Gitea caps it to its MAX_RESPONSE_ITEMS setting
+const listPageSize = 50
+
+// Config works for Gitea and Forgejo, they share the same API
+type Config struct {
+	BaseURL string
+	Token   string
+}
+
+func (c *Config) Enabled() bool {
+	return c.Token != "" && c.BaseURL != ""
+}
+
+// secret is an Actions secret as listed by the API. Values are never returned.
+type secret struct {
+	Name string `json:"name"`
+}
+
+type client struct {
+	*apiclient.Client
+}
+
+func newGiteaClient(cfg Config) (*client, error) {
+	if !cfg.Enabled() {
+		return nil, fmt.Errorf("insufficient information provided. Gitea client can't be created")
+	}
+	if _, err := url.Parse(cfg.BaseURL); err != nil {
+		return nil, err
+	}
+	return &client{apiclient.NewClient("gitea", strings.TrimSuffix(cfg.BaseURL, "/")+"/api/v1", func(req *http.Request) {
+		req.Header.Set("Authorization", "token "+cfg.Token)
+	})}, nil
+}
+
+// secretsPath returns the path of the Actions secrets of a repository, or of an organization if repo is empty
+func secretsPath(owner, repo string) string {
+	if repo == "" {
+		return fmt.Sprintf("/orgs/%s/actions/secrets", url.PathEscape(owner))
+	}
+	return fmt.Sprintf("/repos/%s/%s/actions/secrets", url.PathEscape(owner), url.PathEscape(repo))
+}
+
+// createOrUpdateSecret stores value in the secret. Gitea only accepts secrets over an authenticated
+// connection and encrypts them at rest, so unlike GitHub no client side encryption is involved.
+func (c *client) createOrUpdateSecret(ctx context.Context, owner, repo, name string, value []byte) error {
+	body := map[string]string{"data": string(value)}
+	return c.Do(ctx, http.MethodPut, secretsPath(owner, repo)+"/"+url.PathEscape(name), body, nil)
+}
+
+func (c *client) deleteSecret(ctx context.Context, owner, repo, name string) error {
+	return c.Do(ctx, http.MethodDelete, secretsPath(owner, repo)+"/"+url.PathEscape(name), nil, nil)
+}
+
+// secretExists looks for name in the secrets list as the API has no endpoint to get a single secret
+func (c *client) secretExists(ctx context.Context, owner, repo, name string) (bool, error) {
+	for page := 1; ; page++ {
+		var secrets []secret
+		query := url.Values{"page": {fmt.Sprint(page)}, "limit": {fmt.Sprint(listPageSize)}}
+		if err := c.Do(ctx, http.MethodGet, secretsPath(owner, repo)+"?"+query.Encode(), nil, &secrets); err != nil {
+			return false, err
+		}
+		for _, s := range secrets {
+			// Secret names are stored in upper case
+			if strings.EqualFold(s.Name, name) {
+				return true, nil
+			}
+		}
+		if len(secrets) < listPageSize {
+			return false, nil
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package gitea
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jadolg/klum/pkg/apiclient"
+	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	"github.com/jadolg/klum/pkg/usersync"
+	log "github.com/sirupsen/logrus"
+)
+
+// Provider synchronizes kubeconfigs to Gitea and Forgejo Actions secrets
+type Provider struct {
+	cfg Config
+}
+
+func NewProvider(cfg Config) *Provider {
+	return &Provider{cfg: cfg}
+}
+
+func (p *Provider) Name() string {
+	return "gitea"
+}
+
+func (p *Provider) Enabled() bool {
+	return p.cfg.Enabled()
+}
+
+func (p *Provider) Upload(ctx context.Context, sync usersync.Object, payload []byte) error {
+	userSync, err := asUserSyncGitea(sync)
+	if err != nil {
+		return err
+	}
+	giteaSync := userSync.Spec.Gitea
+	if err := giteaSync.Validate(); err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"secret": giteaSync.SecretName,
+		"user":   userSync.Spec.User,
+		"owner":  giteaSync.Owner,
+		"repo":   giteaSync.Repository,
+	}).Info("Adding secret")
+
+	client, err := newGiteaClient(p.cfg)
+	if err != nil {
+		return err
+	}
+	return client.createOrUpdateSecret(ctx, giteaSync.Owner, giteaSync.Repository, giteaSync.SecretName, payload)
+}
+
+func (p *Provider) Delete(ctx context.Context, sync usersync.Object) error {
+	userSync, err := asUserSyncGitea(sync)
+	if err != nil {
+		return err
+	}
+	giteaSync := userSync.Spec.Gitea
+	if err := giteaSync.Validate(); err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"secret": giteaSync.SecretName,
+		"user":   userSync.Spec.User,
+		"owner":  giteaSync.Owner,
+		"repo":   giteaSync.Repository,
+	}).Info("Deleting secret")
+
+	client, err := newGiteaClient(p.cfg)
+	if err != nil {
+		return err
+	}
+
+	err = client.deleteSecret(ctx, giteaSync.Owner, giteaSync.Repository, giteaSync.SecretName)
+	if apiclient.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func (p *Provider) Verify(ctx context.Context, sync usersync.Object) (bool, error) {
+	userSync, err := asUserSyncGitea(sync)
+	if err != nil {
+		return false, err
+	}
+	giteaSync := userSync.Spec.Gitea
+	if err := giteaSync.Validate(); err != nil {
+		return false, err
+	}
+
+	client, err := newGiteaClient(p.cfg)
+	if err != nil {
+		return false, err
+	}
+	return client.secretExists(ctx, giteaSync.Owner, giteaSync.Repository, giteaSync.SecretName)
+}
+
+func asUserSyncGitea(sync usersync.Object) (*klum.UserSyncGitea, error) {
+	userSync, ok := sync.(*klum.UserSyncGitea)
+	if !ok {
+		return nil, fmt.Errorf("gitea provider can't synchronize %T", sync)
+	}
+	return userSync, nil
+}
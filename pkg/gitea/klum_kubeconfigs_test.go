@@ -0,0 +1,139 @@
+package gitea
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeGitea stores secrets by path like the Gitea Actions secrets API
+type fakeGitea struct {
+	lock    sync.Mutex
+	secrets map[string]map[string]string
+	paths   []string
+}
+
+func (f *fakeGitea) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if r.Header.Get("Authorization") != "token token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	f.paths = append(f.paths, r.Method+" "+r.URL.Path)
+
+	if r.Method == http.MethodGet {
+		var list []secret
+		if r.URL.Query().Get("page") == "1" {
+			for name := range f.secrets[r.URL.Path] {
+				list = append(list, secret{Name: name})
+			}
+		}
+		_ = json.NewEncoder(w).Encode(list)
+		return
+	}
+
+	i := strings.LastIndex(r.URL.Path, "/")
+	collection, name := r.URL.Path[:i], strings.ToUpper(r.URL.Path[i+1:])
+	switch r.Method {
+	case http.MethodPut:
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if f.secrets[collection] == nil {
+			f.secrets[collection] = map[string]string{}
+		}
+		f.secrets[collection][name] = body["data"]
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		if _, found := f.secrets[collection][name]; !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.secrets[collection], name)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func newTestProvider(t *testing.T) (*Provider, *fakeGitea) {
+	fake := &fakeGitea{secrets: map[string]map[string]string{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return NewProvider(Config{BaseURL: server.URL + "/", Token: "token"}), fake
+}
+
+func newTestSync(spec klum.GiteaSyncSpec) *klum.UserSyncGitea {
+	return &klum.UserSyncGitea{
+		ObjectMeta: metav1.ObjectMeta{Name: "darren"},
+		Spec:       klum.UserSyncGiteaSpec{User: "darren", Gitea: spec},
+	}
+}
+
+func TestRepositorySecret(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	sync := newTestSync(klum.GiteaSyncSpec{Owner: "jadolg", Repository: "klum-example", SecretName: "kube_config"})
+
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	assert.Equal(t, "kubeconfig", fake.secrets["/api/v1/repos/jadolg/klum-example/actions/secrets"]["KUBE_CONFIG"])
+
+	exists, err := provider.Verify(context.Background(), sync)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	require.NoError(t, provider.Delete(context.Background(), sync))
+	exists, err = provider.Verify(context.Background(), sync)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// Deleting a secret that is already gone is not an error
+	require.NoError(t, provider.Delete(context.Background(), sync))
+}
+
+func TestOrganizationSecret(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	sync := newTestSync(klum.GiteaSyncSpec{Owner: "platform", SecretName: "KUBE_CONFIG"})
+
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	assert.Equal(t, []string{"PUT /api/v1/orgs/platform/actions/secrets/KUBE_CONFIG"}, fake.paths)
+	assert.Equal(t, "kubeconfig", fake.secrets["/api/v1/orgs/platform/actions/secrets"]["KUBE_CONFIG"])
+}
+
+func TestSecretExistsPaginates(t *testing.T) {
+	pages := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pages++
+		var list []secret
+		if r.URL.Query().Get("page") == "1" {
+			for i := 0; i < listPageSize; i++ {
+				list = append(list, secret{Name: fmt.Sprintf("SECRET_%d", i)})
+			}
+		} else {
+			list = append(list, secret{Name: "KUBE_CONFIG"})
+		}
+		_ = json.NewEncoder(w).Encode(list)
+	}))
+	defer server.Close()
+
+	c, err := newGiteaClient(Config{BaseURL: server.URL, Token: "token"})
+	require.NoError(t, err)
+	exists, err := c.secretExists(context.Background(), "jadolg", "klum-example", "kube_config")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, 2, pages)
+}
+
+func TestConfigEnabled(t *testing.T) {
+	assert.False(t, (&Config{Token: "token"}).Enabled())
+	assert.False(t, (&Config{BaseURL: "https://gitea.example.com"}).Enabled())
+	assert.True(t, (&Config{BaseURL: "https://gitea.example.com", Token: "token"}).Enabled())
+}
@@ -0,0 +1,239 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v63/github"
+)
+
+const (
+	installationTTL = time.Hour
+	repositoryTTL   = time.Hour
+	publicKeyTTL    = time.Hour
+	// selectionTTL is how long the repositories matching a selector are reused, new repositories
+	// are picked up once it expires. Syncs with a selector are verified as often, see ResyncInterval
+	selectionTTL = 10 * time.Minute
+)
+
+// ttlCache holds values for ttl
+type ttlCache[T any] struct {
+	lock    sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]ttlEntry[T]
+}
+
+type ttlEntry[T any] struct {
+	value   T
+	expires time.Time
+}
+
+func newTTLCache[T any](ttl time.Duration, now func() time.Time) *ttlCache[T] {
+	return &ttlCache[T]{ttl: ttl, now: now, entries: map[string]ttlEntry[T]{}}
+}
+
+func (c *ttlCache[T]) get(key string) (T, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || !c.now().Before(entry.expires) {
+		var zero T
+		return zero, false
+	}
+	return entry.value, true
+}
+
+func (c *ttlCache[T]) set(key string, value T) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entries[key] = ttlEntry[T]{value: value, expires: c.now().Add(c.ttl)}
+}
+
+func (c *ttlCache[T]) delete(key string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.entries, key)
+}
+
+// deletePrefix deletes the values of the keys starting with prefix
+func (c *ttlCache[T]) deletePrefix(prefix string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// clientCache reuses the clients of a Provider and caches the lookups made before every request.
+// Each installation of a GitHub App has its own client, so its installation token is reused
+// until it expires.
+type clientCache struct {
+	cfg     Config
+	limiter *rateLimiter
+	base    http.RoundTripper
+
+	lock sync.Mutex
+	// clients by installation, 0 is the client authenticated with the token
+	clients    map[int64]*github.Client
+	appsClient *github.Client
+
+	installations *ttlCache[int64]
+	repositories  *ttlCache[int]
+	publicKeys    *ttlCache[*github.PublicKey]
+	selections    *ttlCache[[]string]
+}
+
+func newClientCache(cfg Config, limiter *rateLimiter, base http.RoundTripper) *clientCache {
+	return &clientCache{
+		cfg:           cfg,
+		limiter:       limiter,
+		base:          base,
+		clients:       map[int64]*github.Client{},
+		installations: newTTLCache[int64](installationTTL, time.Now),
+		repositories:  newTTLCache[int](repositoryTTL, time.Now),
+		publicKeys:    newTTLCache[*github.PublicKey](publicKeyTTL, time.Now),
+		selections:    newTTLCache[[]string](selectionTTL, time.Now),
+	}
+}
+
+// cachedClient is a client for the repository or organization of a target
+type cachedClient struct {
+	*github.Client
+	cache          *clientCache
+	installationID int64
+}
+
+// client returns a client for the repository of owner, or for the organization owner if repo is empty
+func (c *clientCache) client(ctx context.Context, owner, repo string) (*cachedClient, error) {
+	if c.cfg.Token != "" {
+		client, err := c.installationClient(0)
+		if err != nil {
+			return nil, err
+		}
+		return &cachedClient{Client: client, cache: c}, nil
+	}
+	if c.cfg.AppID == 0 || c.cfg.PrivateKeyFile == "" {
+		return nil, fmt.Errorf("insufficient information provided. Github client can't be created")
+	}
+
+	key := cacheKey(owner, repo)
+	installationID, ok := c.installations.get(key)
+	if !ok {
+		appsClient, err := c.getAppsClient()
+		if err != nil {
+			return nil, err
+		}
+		installationID, err = findInstallationID(ctx, appsClient, owner, repo)
+		if err != nil {
+			return nil, err
+		}
+		c.installations.set(key, installationID)
+	}
+
+	client, err := c.installationClient(installationID)
+	if err != nil {
+		return nil, err
+	}
+	return &cachedClient{Client: client, cache: c, installationID: installationID}, nil
+}
+
+func (c *clientCache) installationClient(installationID int64) (*github.Client, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if client, ok := c.clients[installationID]; ok {
+		return client, nil
+	}
+
+	var client *github.Client
+	var err error
+	transport := c.limiter.transport(c.base, installationLabel(installationID))
+	if installationID == 0 {
+		client, err = newGithubClientWithToken(c.cfg.Token, c.cfg.BaseURL, transport)
+	} else {
+		client, err = newGithubClientWithApp(c.cfg.PrivateKeyFile, c.cfg.AppID, installationID, c.cfg.BaseURL, transport)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.clients[installationID] = client
+	return client, nil
+}
+
+func (c *clientCache) getAppsClient() (*github.Client, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.appsClient == nil {
+		appsClient, err := newGithubAppsClient(c.cfg, c.limiter.transport(c.base, appLabel))
+		if err != nil {
+			return nil, err
+		}
+		c.appsClient = appsClient
+	}
+	return c.appsClient, nil
+}
+
+// invalidate forgets what is cached for the repository of owner, or the organization owner, when
+// err shows it is outdated: a 404 when the repository was renamed or the App uninstalled, a 401
+// when the credentials of client were revoked and a 422 when the public key was rotated
+func (c *clientCache) invalidate(client *cachedClient, owner, repo string, err error) {
+	var ghErr *github.ErrorResponse
+	if !errors.As(err, &ghErr) || ghErr.Response == nil {
+		return
+	}
+
+	key := cacheKey(owner, repo)
+	switch ghErr.Response.StatusCode {
+	case http.StatusUnauthorized:
+		c.lock.Lock()
+		delete(c.clients, client.installationID)
+		c.lock.Unlock()
+		fallthrough
+	case http.StatusNotFound:
+		c.installations.delete(key)
+		c.repositories.delete(key)
+		fallthrough
+	case http.StatusUnprocessableEntity:
+		c.publicKeys.deletePrefix(key + "/")
+	}
+}
+
+// repoID returns the ID of the repository of owner
+func (c *cachedClient) repoID(ctx context.Context, owner, repo string) (int, error) {
+	key := cacheKey(owner, repo)
+	if repositoryID, ok := c.cache.repositories.get(key); ok {
+		return repositoryID, nil
+	}
+	repositoryID, err := getRepoID(ctx, c.Client, owner, repo)
+	if err != nil {
+		return 0, err
+	}
+	c.cache.repositories.set(key, repositoryID)
+	return repositoryID, nil
+}
+
+// publicKey returns the public key of the secrets of scope in the repository of owner, or in the
+// organization owner if repo is empty, fetching it with get
+func (c *cachedClient) publicKey(owner, repo, scope string, get func() (*github.PublicKey, *github.Response, error)) (*github.PublicKey, error) {
+	key := cacheKey(owner, repo) + "/" + scope
+	if publicKey, ok := c.cache.publicKeys.get(key); ok {
+		return publicKey, nil
+	}
+	publicKey, _, err := get()
+	if err != nil {
+		return nil, err
+	}
+	c.cache.publicKeys.set(key, publicKey)
+	return publicKey, nil
+}
+
+func cacheKey(owner, repo string) string {
+	return owner + "/" + repo
+}
@@ -0,0 +1,95 @@
+package github
+
+import (
+	"context"
+	"crypto/rand"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/nacl/box"
+)
+
+func TestCacheReusesPublicKey(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	sync := newTestSync(klum.GithubSyncSpec{Owner: "jadolg", Repository: "klum", SecretName: "KUBE_CONFIG"})
+
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	assert.Equal(t, 4, fake.requests)
+
+	// Only the secret is sent and read back again
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("new kubeconfig")))
+	assert.Equal(t, 6, fake.requests)
+	assert.Equal(t, "new kubeconfig", fake.secrets["actions:jadolg/klum/KUBE_CONFIG"].value)
+}
+
+func TestCacheInvalidatesRotatedPublicKey(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	sync := newTestSync(klum.GithubSyncSpec{Owner: "jadolg", Repository: "klum", SecretName: "KUBE_CONFIG"})
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+
+	var err error
+	fake.publicKey, fake.privateKey, err = box.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	require.Error(t, provider.Upload(context.Background(), sync, []byte("new kubeconfig")))
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("new kubeconfig")))
+	assert.Equal(t, "new kubeconfig", fake.secrets["actions:jadolg/klum/KUBE_CONFIG"].value)
+}
+
+func TestCacheInvalidatesRepositoryID(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	sync := newTestSync(klum.GithubSyncSpec{Owner: "jadolg", Repository: "api", Environment: "prod", SecretName: "KUBE_CONFIG"})
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+
+	// The repository is deleted and created again with a new ID
+	fake.repositories["api"] = 5
+	fake.override = func(w http.ResponseWriter, r *http.Request) bool {
+		if strings.HasPrefix(r.URL.Path, "/api/v3/repositories/2/") {
+			w.WriteHeader(http.StatusNotFound)
+			return true
+		}
+		return false
+	}
+
+	require.Error(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	assert.Equal(t, "kubeconfig", fake.secrets["actions:5@prod/KUBE_CONFIG"].value)
+}
+
+func TestCacheReusesRepositorySelection(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	now := time.Now()
+	provider.clients.selections.now = func() time.Time { return now }
+	sync := newTestSync(klum.GithubSyncSpec{
+		Owner:              "jadolg",
+		SecretName:         "KUBE_CONFIG",
+		RepositorySelector: &klum.GithubRepositorySelector{Topic: "deploy"},
+	})
+	listed := 0
+	fake.override = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.URL.Path == "/api/v3/orgs/jadolg/repos" {
+			listed++
+		}
+		return false
+	}
+
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	assert.Equal(t, 1, listed, "the organization is listed once")
+
+	// New matching repositories are picked up once the selection expires
+	fake.topics["klum"] = []string{"deploy"}
+	sync.Status.Targets = provider.Targets(sync)
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	assert.NotContains(t, fake.secrets, "actions:jadolg/klum/KUBE_CONFIG")
+
+	now = now.Add(selectionTTL)
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	assert.Equal(t, 2, listed)
+	assert.Contains(t, fake.secrets, "actions:jadolg/klum/KUBE_CONFIG")
+}
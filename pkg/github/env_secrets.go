@@ -2,37 +2,27 @@ package github
 
 import (
 	"context"
-	"errors"
 
 	"github.com/google/go-github/v63/github"
 	"github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
-	log "github.com/sirupsen/logrus"
 )
 
-func createRepositoryEnvSecret(ctx context.Context, client *github.Client, syncSpec *v1alpha1.GithubSyncSpec, secretValue []byte) error {
+func createRepositoryEnvSecret(ctx context.Context, client *cachedClient, syncSpec *v1alpha1.GithubSyncSpec, secretValue []byte) error {
 	var key *github.PublicKey
 	var repositoryID int
 
-	repositoryID, err := getRepoID(ctx, client, syncSpec.Owner, syncSpec.Repository)
+	repositoryID, err := client.repoID(ctx, syncSpec.Owner, syncSpec.Repository)
 	if err != nil {
 		return err
 	}
 
-	_, _, err = client.Repositories.GetEnvironment(ctx, syncSpec.Owner, syncSpec.Repository, syncSpec.Environment)
-	if err != nil {
-		var ghErr *github.ErrorResponse
-		if errors.As(err, &ghErr) && ghErr.Response.StatusCode == 404 {
-			log.WithFields(log.Fields{"environment": syncSpec.Environment, "repository": syncSpec.Repository}).Warn("Environment not found. Creating new environment.")
-			_, _, err = client.Repositories.CreateUpdateEnvironment(ctx, syncSpec.Owner, syncSpec.Repository, syncSpec.Environment, &github.CreateUpdateEnvironment{})
-			if err != nil {
-				return err
-			}
-		} else {
-			return err
-		}
+	if err := ensureEnvironment(ctx, client, syncSpec); err != nil {
+		return err
 	}
 
-	key, _, err = client.Actions.GetEnvPublicKey(ctx, repositoryID, syncSpec.Environment)
+	key, err = client.publicKey(syncSpec.Owner, syncSpec.Repository, "environments/"+syncSpec.Environment, func() (*github.PublicKey, *github.Response, error) {
+		return client.Actions.GetEnvPublicKey(ctx, repositoryID, syncSpec.Environment)
+	})
 	if err != nil {
 		return err
 	}
@@ -44,6 +34,7 @@ func createRepositoryEnvSecret(ctx context.Context, client *github.Client, syncS
 
 	secret := &github.EncryptedSecret{
 		Name:           syncSpec.SecretName,
+		KeyID:          key.GetKeyID(),
 		EncryptedValue: encryptedSecret,
 	}
 
@@ -51,8 +42,8 @@ func createRepositoryEnvSecret(ctx context.Context, client *github.Client, syncS
 	return err
 }
 
-func deleteRepositoryEnvSecret(ctx context.Context, client *github.Client, syncSpec *v1alpha1.GithubSyncSpec) error {
-	repositoryID, err := getRepoID(ctx, client, syncSpec.Owner, syncSpec.Repository)
+func deleteRepositoryEnvSecret(ctx context.Context, client *cachedClient, syncSpec *v1alpha1.GithubSyncSpec) error {
+	repositoryID, err := client.repoID(ctx, syncSpec.Owner, syncSpec.Repository)
 	if err != nil {
 		return err
 	}
@@ -60,3 +51,13 @@ func deleteRepositoryEnvSecret(ctx context.Context, client *github.Client, syncS
 	_, err = client.Actions.DeleteEnvSecret(ctx, repositoryID, syncSpec.Environment, syncSpec.SecretName)
 	return err
 }
+
+func getRepositoryEnvSecret(ctx context.Context, client *cachedClient, syncSpec *v1alpha1.GithubSyncSpec) (*github.Secret, error) {
+	repositoryID, err := client.repoID(ctx, syncSpec.Owner, syncSpec.Repository)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, _, err := client.Actions.GetEnvSecret(ctx, repositoryID, syncSpec.Environment, syncSpec.SecretName)
+	return secret, err
+}
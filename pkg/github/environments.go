@@ -0,0 +1,165 @@
+package github
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v63/github"
+	"github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	branchPolicyBranch = "branch"
+	branchPolicyTag    = "tag"
+)
+
+// ensureEnvironment creates the environment of syncSpec with its protection rules if it doesn't
+// exist. The rules of an existing environment are only updated when they are enforced.
+func ensureEnvironment(ctx context.Context, client *cachedClient, syncSpec *v1alpha1.GithubSyncSpec) error {
+	protection := syncSpec.EnvironmentProtection
+	enforce := protection != nil && protection.Enforce
+
+	existing, _, err := client.Repositories.GetEnvironment(ctx, syncSpec.Owner, syncSpec.Repository, syncSpec.Environment)
+	if err != nil {
+		if !isNotFound(err) {
+			return err
+		}
+		log.WithFields(log.Fields{"environment": syncSpec.Environment, "repository": syncSpec.Repository}).Warn("Environment not found. Creating new environment.")
+	} else if !enforce {
+		return repairBranchPolicies(ctx, client, syncSpec, existing)
+	}
+
+	environment, err := environmentSettings(ctx, client, syncSpec.Owner, protection)
+	if err != nil {
+		return err
+	}
+	_, _, err = client.Repositories.CreateUpdateEnvironment(ctx, syncSpec.Owner, syncSpec.Repository, syncSpec.Environment, environment)
+	if err != nil {
+		return err
+	}
+
+	if protection == nil || protection.DeploymentBranchPolicy == nil || protection.DeploymentBranchPolicy.ProtectedBranches {
+		return nil
+	}
+	return applyBranchPolicies(ctx, client, syncSpec, protection.DeploymentBranchPolicy)
+}
+
+// repairBranchPolicies creates the branch and tag patterns of an existing environment using custom
+// branch policies without any. GitHub only accepts patterns once custom branch policies are enabled,
+// so an environment is left without them, and can't be deployed from, when creating them failed
+// after creating the environment.
+func repairBranchPolicies(ctx context.Context, client *cachedClient, syncSpec *v1alpha1.GithubSyncSpec, environment *github.Environment) error {
+	protection := syncSpec.EnvironmentProtection
+	if protection == nil || protection.DeploymentBranchPolicy == nil || protection.DeploymentBranchPolicy.ProtectedBranches ||
+		!environment.GetDeploymentBranchPolicy().GetCustomBranchPolicies() {
+		return nil
+	}
+
+	existing, _, err := client.Repositories.ListDeploymentBranchPolicies(ctx, syncSpec.Owner, syncSpec.Repository, syncSpec.Environment)
+	if err != nil {
+		return err
+	}
+	if len(existing.BranchPolicies) > 0 {
+		return nil
+	}
+	return applyBranchPolicies(ctx, client, syncSpec, protection.DeploymentBranchPolicy)
+}
+
+// environmentSettings returns the request setting the protection rules of an environment, an
+// unprotected environment if protection is nil
+func environmentSettings(ctx context.Context, client *cachedClient, owner string, protection *v1alpha1.GithubEnvironmentProtection) (*github.CreateUpdateEnvironment, error) {
+	if protection == nil {
+		return &github.CreateUpdateEnvironment{}, nil
+	}
+
+	reviewers, err := environmentReviewers(ctx, client, owner, protection.Reviewers)
+	if err != nil {
+		return nil, err
+	}
+	environment := &github.CreateUpdateEnvironment{
+		WaitTimer:         github.Int(protection.WaitTimer),
+		Reviewers:         reviewers,
+		PreventSelfReview: github.Bool(protection.PreventSelfReview),
+	}
+	if policy := protection.DeploymentBranchPolicy; policy != nil {
+		environment.DeploymentBranchPolicy = &github.BranchPolicy{
+			ProtectedBranches:    github.Bool(policy.ProtectedBranches),
+			CustomBranchPolicies: github.Bool(!policy.ProtectedBranches),
+		}
+	}
+	return environment, nil
+}
+
+// environmentReviewers looks up the IDs of the users and teams of owner in reviewers
+func environmentReviewers(ctx context.Context, client *cachedClient, owner string, reviewers []v1alpha1.GithubReviewer) ([]*github.EnvReviewers, error) {
+	var envReviewers []*github.EnvReviewers
+	for _, reviewer := range reviewers {
+		if reviewer.Team != "" {
+			team, _, err := client.Teams.GetTeamBySlug(ctx, owner, reviewer.Team)
+			if err != nil {
+				return nil, fmt.Errorf("reviewer team %s: %w", reviewer.Team, err)
+			}
+			envReviewers = append(envReviewers, &github.EnvReviewers{Type: github.String("Team"), ID: team.ID})
+			continue
+		}
+		user, _, err := client.Users.Get(ctx, reviewer.User)
+		if err != nil {
+			return nil, fmt.Errorf("reviewer user %s: %w", reviewer.User, err)
+		}
+		envReviewers = append(envReviewers, &github.EnvReviewers{Type: github.String("User"), ID: user.ID})
+	}
+	return envReviewers, nil
+}
+
+// applyBranchPolicies creates the branch and tag patterns of policy in the environment of syncSpec
+// and deletes the patterns that are not in policy
+func applyBranchPolicies(ctx context.Context, client *cachedClient, syncSpec *v1alpha1.GithubSyncSpec, policy *v1alpha1.GithubDeploymentBranchPolicy) error {
+	var wanted []*github.DeploymentBranchPolicyRequest
+	for _, name := range policy.Branches {
+		wanted = append(wanted, &github.DeploymentBranchPolicyRequest{Name: github.String(name), Type: github.String(branchPolicyBranch)})
+	}
+	for _, name := range policy.Tags {
+		wanted = append(wanted, &github.DeploymentBranchPolicyRequest{Name: github.String(name), Type: github.String(branchPolicyTag)})
+	}
+	keep := map[string]bool{}
+	for _, request := range wanted {
+		keep[branchPolicyKey(request.GetType(), request.GetName())] = true
+	}
+
+	existing, _, err := client.Repositories.ListDeploymentBranchPolicies(ctx, syncSpec.Owner, syncSpec.Repository, syncSpec.Environment)
+	if err != nil {
+		return err
+	}
+	exists := map[string]bool{}
+	for _, branchPolicy := range existing.BranchPolicies {
+		key := branchPolicyKey(branchPolicy.GetType(), branchPolicy.GetName())
+		if keep[key] {
+			exists[key] = true
+			continue
+		}
+		_, err := client.Repositories.DeleteDeploymentBranchPolicy(ctx, syncSpec.Owner, syncSpec.Repository, syncSpec.Environment, branchPolicy.GetID())
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, request := range wanted {
+		if exists[branchPolicyKey(request.GetType(), request.GetName())] {
+			continue
+		}
+		_, _, err := client.Repositories.CreateDeploymentBranchPolicy(ctx, syncSpec.Owner, syncSpec.Repository, syncSpec.Environment, request)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// branchPolicyKey identifies a branch or tag pattern, policies created before tags were supported have no type
+func branchPolicyKey(policyType, name string) string {
+	if policyType == "" {
+		policyType = branchPolicyBranch
+	}
+	return policyType + "/" + name
+}
@@ -10,8 +10,8 @@ import (
 	"strings"
 
 	"github.com/bradleyfalzon/ghinstallation/v2"
-
 	"github.com/google/go-github/v63/github"
+	"github.com/jadolg/klum/pkg/apiclient"
 	"golang.org/x/crypto/nacl/box"
 	"golang.org/x/oauth2"
 )
@@ -27,27 +27,15 @@ func (c *Config) Enabled() bool {
 	return c.Token != "" || (c.AppID != 0 && c.PrivateKeyFile != "")
 }
 
-func newGithubClient(cfg Config, owner, repo string) (*github.Client, error) {
-	if cfg.Token != "" {
-		return newGithubClientWithToken(cfg.Token, cfg.BaseURL)
-	}
-	if cfg.AppID != 0 && cfg.PrivateKeyFile != "" {
-		installationID, err := getInstallationID(cfg, owner, repo)
-		if err != nil {
-			return nil, err
-		}
-		return newGithubClientWithApp(cfg.PrivateKeyFile, cfg.AppID, installationID, cfg.BaseURL)
-	}
-	return nil, fmt.Errorf("insufficient information provided. Github client can't be created")
-}
-
-func newGithubClientWithToken(token, privateURL string) (*github.Client, error) {
+func newGithubClientWithToken(token, privateURL string, transport http.RoundTripper) (*github.Client, error) {
 	var httpClient *http.Client
 
 	ts := oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: token},
 	)
-	httpClient = oauth2.NewClient(context.Background(), ts)
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: transport})
+	httpClient = oauth2.NewClient(ctx, ts)
+	httpClient.Timeout = apiclient.Timeout
 
 	client := github.NewClient(httpClient)
 	err := injectGithubClientPrivateURL(privateURL, client)
@@ -57,8 +45,8 @@ func newGithubClientWithToken(token, privateURL string) (*github.Client, error)
 	return client, nil
 }
 
-func newGithubClientWithApp(privateKeyFile string, appID int64, installationID int64, privateURL string) (*github.Client, error) {
-	itr, err := ghinstallation.NewKeyFromFile(http.DefaultTransport, appID, installationID, privateKeyFile)
+func newGithubClientWithApp(privateKeyFile string, appID int64, installationID int64, privateURL string, transport http.RoundTripper) (*github.Client, error) {
+	itr, err := ghinstallation.NewKeyFromFile(transport, appID, installationID, privateKeyFile)
 	if err != nil {
 		return nil, err
 	}
@@ -67,7 +55,7 @@ func newGithubClientWithApp(privateKeyFile string, appID int64, installationID i
 		return nil, err
 	}
 
-	client := github.NewClient(&http.Client{Transport: itr})
+	client := github.NewClient(&http.Client{Transport: itr, Timeout: apiclient.Timeout})
 
 	err = injectGithubClientPrivateURL(privateURL, client)
 	if err != nil {
@@ -76,21 +64,34 @@ func newGithubClientWithApp(privateKeyFile string, appID int64, installationID i
 	return client, nil
 }
 
-func getInstallationID(cfg Config, owner string, repo string) (int64, error) {
-	itr, err := ghinstallation.NewAppsTransportKeyFromFile(http.DefaultTransport, cfg.AppID, cfg.PrivateKeyFile)
+// newGithubAppsClient returns a client authenticated as the GitHub App, to look up its installations
+func newGithubAppsClient(cfg Config, transport http.RoundTripper) (*github.Client, error) {
+	itr, err := ghinstallation.NewAppsTransportKeyFromFile(transport, cfg.AppID, cfg.PrivateKeyFile)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	err = injectAppTransportPrivateURL(cfg.BaseURL, itr)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	client := github.NewClient(&http.Client{Transport: itr})
+	client := github.NewClient(&http.Client{Transport: itr, Timeout: apiclient.Timeout})
 	err = injectGithubClientPrivateURL(cfg.BaseURL, client)
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+	return client, nil
+}
+
+// findInstallationID returns the installation of the App in the repository of owner, or in the
+// organization owner if repo is empty
+func findInstallationID(ctx context.Context, appsClient *github.Client, owner string, repo string) (int64, error) {
+	var installation *github.Installation
+	var err error
+	if repo == "" {
+		installation, _, err = appsClient.Apps.FindOrganizationInstallation(ctx, owner)
+	} else {
+		installation, _, err = appsClient.Apps.FindRepositoryInstallation(ctx, owner, repo)
 	}
-	installation, _, err := client.Apps.FindRepositoryInstallation(context.Background(), owner, repo)
 	if err != nil {
 		return 0, err
 	}
@@ -2,121 +2,583 @@ package github
 
 import (
 	"context"
-	"crypto/sha256"
+	"errors"
 	"fmt"
+	"net/http"
+	"slices"
+	"sync"
 	"time"
 
+	"github.com/google/go-github/v63/github"
 	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	"github.com/jadolg/klum/pkg/usersync"
 	log "github.com/sirupsen/logrus"
-	"sigs.k8s.io/yaml"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func UploadKubeconfig(userSync *klum.UserSyncGithub, kubeconfig *klum.Kubeconfig, cfg Config) error {
+// Provider synchronizes kubeconfigs to GitHub Actions, Dependabot and Codespaces secrets
+type Provider struct {
+	cfg     Config
+	limiter *rateLimiter
+	clients *clientCache
+
+	lock    sync.Mutex
+	targets map[string][]klum.TargetStatus
+	// drifted are the targets found deleted or changed by Verify, by sync and targetKey
+	drifted map[string]map[string]bool
+}
+
+func NewProvider(cfg Config) *Provider {
+	limiter := newRateLimiter()
+	return &Provider{
+		cfg:     cfg,
+		limiter: limiter,
+		clients: newClientCache(cfg, limiter, http.DefaultTransport),
+		targets: map[string][]klum.TargetStatus{},
+		drifted: map[string]map[string]bool{},
+	}
+}
+
+func (p *Provider) Name() string {
+	return "github"
+}
+
+func (p *Provider) Enabled() bool {
+	return p.cfg.Enabled()
+}
+
+func (p *Provider) Targets(sync usersync.Object) []klum.TargetStatus {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.targets[sync.GetName()]
+}
+
+// Upload creates the secret, and sets the variables, in every target of sync. Targets that fail don't stop the others,
+// and targets already holding payload are skipped, so a failed Upload only retries the failed
+// targets, unless Verify found them drifted. The secrets of targets removed from sync are deleted. Once a rate limit is exceeded the
+// remaining targets are left for the retry after the limit is reset.
+func (p *Provider) Upload(ctx context.Context, sync usersync.Object, payload []byte) error {
+	userSync, err := asUserSyncGithub(sync)
+	if err != nil {
+		return err
+	}
 	githubSync := userSync.Spec.Github
+	p.setTargets(userSync, userSync.Status.Targets)
 	if err := githubSync.Validate(); err != nil {
 		return err
 	}
 
-	kubeconfigYAML, err := toYAMLString(kubeconfig.Spec)
+	secrets, err := renderSecrets(&githubSync, payload)
 	if err != nil {
 		return err
 	}
+	variables, err := renderVariables(&githubSync, payload)
+	if err != nil {
+		return err
+	}
+	targets, err := p.resolveTargets(ctx, &githubSync)
+	if err != nil {
+		return p.retryError(err)
+	}
 
-	upToDate, hash := isSecretUpToDate(userSync, kubeconfigYAML)
+	previous := map[string]klum.TargetStatus{}
+	for _, status := range userSync.Status.Targets {
+		previous[targetKey(status)] = status
+	}
 
-	if upToDate {
-		return nil
+	var statuses []klum.TargetStatus
+	var errs []error
+	var retryAfter time.Duration
+	current := map[string]bool{}
+	for _, target := range targets {
+		status := targetStatus(&target)
+		key := targetKey(status)
+		if current[key] {
+			continue
+		}
+		current[key] = true
+
+		hash := targetHash(&target, renderedPayload(secrets, variables))
+		drifted := p.isDrifted(userSync, key)
+		if last, ok := previous[key]; ok {
+			if (last.Hash == hash && last.Error == "" && !drifted) || retryAfter > 0 {
+				statuses = append(statuses, last)
+				continue
+			}
+			status.RepositoryID = last.RepositoryID
+			status.Hash = last.Hash
+			status.SecretHashes = last.SecretHashes
+			status.VariableHashes = last.VariableHashes
+			status.LastUpload = last.LastUpload
+		} else if retryAfter > 0 {
+			statuses = append(statuses, status)
+			continue
+		}
+
+		if err := p.uploadValues(ctx, userSync, &target, secrets, variables, &status, drifted); err != nil {
+			status.Error = err.Error()
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			if after, limited := p.limiter.retryAfter(err); limited {
+				retryAfter = after
+			}
+		} else {
+			now := metav1.Now()
+			status.Hash = hash
+			status.LastUpload = &now
+			status.UpdatedAt = p.updatedAt(ctx, &target, secrets)
+			status.RepositoryID = p.repositoryID(ctx, &target)
+			p.setDrifted(userSync, key, false)
+		}
+		statuses = append(statuses, status)
 	}
 
-	ctx := context.Background()
-	time.Sleep(time.Second) // Calling GitHub continuously creates problems. This adds a buffer so all operations succeed.
+	for _, status := range userSync.Status.Targets {
+		if current[targetKey(status)] {
+			continue
+		}
+		if retryAfter > 0 {
+			statuses = append(statuses, status)
+			continue
+		}
+		target := targetSpec(&githubSync, status)
+		if err := p.delete(ctx, userSync, &target, status); err != nil {
+			// Kept to retry the deletion
+			status.Error = err.Error()
+			statuses = append(statuses, status)
+			errs = append(errs, fmt.Errorf("%s: %w", targetKey(status), err))
+			if after, limited := p.limiter.retryAfter(err); limited {
+				retryAfter = after
+			}
+		}
+	}
 
-	log.WithFields(log.Fields{
-		"secret": githubSync.SecretName,
-		"user":   userSync.Spec.User,
-		"repo":   fmt.Sprintf("%s/%s", githubSync.Owner, githubSync.Repository),
-		"env":    githubSync.Environment,
-	}).Info("Adding secret")
+	p.setTargets(userSync, statuses)
+	err = errors.Join(errs...)
+	if retryAfter > 0 {
+		return &usersync.RetryAfterError{Err: err, After: retryAfter}
+	}
+	return err
+}
+
+// Delete removes the secret and the variables from every target of sync, including the targets it was uploaded to
+// before they were removed from sync
+func (p *Provider) Delete(ctx context.Context, sync usersync.Object) error {
+	userSync, err := asUserSyncGithub(sync)
+	if err != nil {
+		return err
+	}
+	githubSync := userSync.Spec.Github
+	if err := githubSync.Validate(); err != nil {
+		return err
+	}
+
+	targets, err := p.resolveTargets(ctx, &githubSync)
+	errs := []error{err}
+	for _, status := range userSync.Status.Targets {
+		targets = append(targets, targetSpec(&githubSync, status))
+	}
+	uploaded := map[string]klum.TargetStatus{}
+	for _, status := range userSync.Status.Targets {
+		uploaded[targetKey(status)] = status
+	}
+
+	deleted := map[string]bool{}
+	for _, target := range targets {
+		key := targetKey(targetStatus(&target))
+		if deleted[key] {
+			continue
+		}
+		deleted[key] = true
+		if err := p.delete(ctx, userSync, &target, uploaded[key]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return p.retryError(err)
+	}
+	p.forget(userSync)
+	return nil
+}
+
+// Verify reports whether the secret exists in every target of sync and wasn't updated since it
+// was uploaded. The targets that drifted are uploaded again by the next Upload.
+func (p *Provider) Verify(ctx context.Context, sync usersync.Object) (bool, error) {
+	userSync, err := asUserSyncGithub(sync)
+	if err != nil {
+		return false, err
+	}
+	githubSync := userSync.Spec.Github
+	if err := githubSync.Validate(); err != nil {
+		return false, err
+	}
 
-	client, err := newGithubClient(cfg, githubSync.Owner, githubSync.Repository)
+	targets, err := p.resolveTargets(ctx, &githubSync)
+	if err != nil {
+		return false, p.retryError(err)
+	}
+	uploaded := map[string]klum.TargetStatus{}
+	for _, status := range userSync.Status.Targets {
+		uploaded[targetKey(status)] = status
+	}
+
+	inSync := true
+	for _, target := range targets {
+		key := targetKey(targetStatus(&target))
+		if status, ok := uploaded[key]; ok && status.Error != "" {
+			// Failed targets are retried anyway
+			continue
+		}
+		for _, name := range secretNames(&target) {
+			secret, err := p.get(ctx, withSecretName(&target, name))
+			if isNotFound(err) {
+				logFields(userSync, withSecretName(&target, name)).Warning("Secret was deleted")
+				p.setDrifted(userSync, key, true)
+				inSync = false
+				break
+			}
+			if err != nil {
+				return false, p.retryError(err)
+			}
+			if status, ok := uploaded[key]; ok && status.UpdatedAt != nil && changedSince(secret.UpdatedAt.Time, status.UpdatedAt.Time) {
+				logFields(userSync, withSecretName(&target, name)).WithField("updatedAt", secret.UpdatedAt.Time).Warning("Secret was changed")
+				p.setDrifted(userSync, key, true)
+				inSync = false
+				break
+			}
+		}
+	}
+
+	selected := map[string]bool{}
+	for _, target := range targets {
+		selected[targetKey(targetStatus(&target))] = true
+	}
+	for key := range uploaded {
+		if !selected[key] {
+			// The repository no longer matches the selector, the next Upload deletes its secret
+			logFields(userSync, &githubSync).WithField("target", key).Warning("Target is no longer selected")
+			inSync = false
+		}
+	}
+	return inSync, nil
+}
+
+// ResyncInterval is selectionTTL for syncs with a repository selector, so repositories matching it
+// or no longer matching it are picked up without waiting for the drift detection
+func (p *Provider) ResyncInterval(sync usersync.Object) time.Duration {
+	userSync, err := asUserSyncGithub(sync)
+	if err != nil || userSync.Spec.Github.RepositorySelector == nil {
+		return 0
+	}
+	return selectionTTL
+}
+
+// uploadValues creates secrets and sets variables in the single target of githubSync. Values
+// whose hash in status is up to date are skipped unless force is set, and the values in status
+// that are no longer rendered are deleted. The hashes in status are updated as the values are uploaded.
+func (p *Provider) uploadValues(ctx context.Context, userSync *klum.UserSyncGithub, githubSync *klum.GithubSyncSpec, secrets, variables []renderedValue, status *klum.TargetStatus, force bool) error {
+	var err error
+	status.SecretHashes, err = syncValues(githubSync, secrets, status.SecretHashes, force,
+		func(name string, value []byte) error {
+			return p.upload(ctx, userSync, withSecretName(githubSync, name), value)
+		},
+		func(name string) error {
+			return p.deleteSecret(ctx, userSync, withSecretName(githubSync, name))
+		})
 	if err != nil {
 		return err
 	}
+	status.VariableHashes, err = syncValues(githubSync, variables, status.VariableHashes, force,
+		func(name string, value []byte) error {
+			return p.setVariable(ctx, userSync, githubSync, name, value)
+		},
+		func(name string) error {
+			return p.deleteVariable(ctx, userSync, githubSync, name)
+		})
+	return err
+}
+
+// syncValues sets the values that changed since hashes were recorded, or all of them if force is
+// set, and removes the values in hashes that are not in values. It returns the hashes of the
+// values set so far, also on error.
+func syncValues(githubSync *klum.GithubSyncSpec, values []renderedValue, hashes map[string]string, force bool, set func(name string, value []byte) error, remove func(name string) error) (map[string]string, error) {
+	updated := map[string]string{}
+	for name, hash := range hashes {
+		updated[name] = hash
+	}
 
-	if githubSync.Environment == "" {
+	rendered := map[string]bool{}
+	for _, value := range values {
+		rendered[value.name] = true
+		hash := targetHash(withSecretName(githubSync, value.name), value.value)
+		if !force && updated[value.name] == hash {
+			continue
+		}
+		if err := set(value.name, value.value); err != nil {
+			return updated, err
+		}
+		updated[value.name] = hash
+	}
+
+	for name := range updated {
+		if rendered[name] {
+			continue
+		}
+		if err := remove(name); err != nil {
+			return updated, err
+		}
+		delete(updated, name)
+	}
+	if len(updated) == 0 {
+		return nil, nil
+	}
+	return updated, nil
+}
+
+// upload creates the secret in the single target of githubSync
+func (p *Provider) upload(ctx context.Context, userSync *klum.UserSyncGithub, githubSync *klum.GithubSyncSpec, payload []byte) error {
+	logFields(userSync, githubSync).Info("Adding secret")
+
+	client, err := p.clients.client(ctx, githubSync.Owner, githubSync.Repository)
+	if err != nil {
+		return err
+	}
+
+	if githubSync.Repository == "" {
+		err = createOrganizationSecret(
+			ctx,
+			client,
+			githubSync,
+			payload,
+		)
+	} else if githubSync.Environment == "" {
 		err = createRepositorySecret(
 			ctx,
 			client,
-			&githubSync,
-			kubeconfigYAML,
+			githubSync,
+			payload,
 		)
 	} else {
 		err = createRepositoryEnvSecret(
 			ctx,
 			client,
-			&githubSync,
-			kubeconfigYAML,
+			githubSync,
+			payload,
 		)
 	}
+	p.clients.invalidate(client, githubSync.Owner, githubSync.Repository, err)
+	return err
+}
+
+// delete removes the secrets and variables of githubSync, and those uploaded to status, from the
+// single target of githubSync
+func (p *Provider) delete(ctx context.Context, userSync *klum.UserSyncGithub, githubSync *klum.GithubSyncSpec, status klum.TargetStatus) error {
+	for _, name := range uploadedNames(secretNames(githubSync), status.SecretHashes) {
+		if err := p.deleteSecret(ctx, userSync, withSecretName(githubSync, name)); err != nil {
+			return err
+		}
+	}
+	if githubSync.Repository == "" {
+		// Variables are only set in repositories and environments
+		return nil
+	}
+	for _, name := range uploadedNames(variableNames(githubSync), status.VariableHashes) {
+		if err := p.deleteVariable(ctx, userSync, githubSync, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	if err == nil {
-		userSync.Annotations["klum.cattle.io/lastest.upload.github"] = hash
+// deleteSecret removes the secret from the single target of githubSync, a missing secret is not an error
+func (p *Provider) deleteSecret(ctx context.Context, userSync *klum.UserSyncGithub, githubSync *klum.GithubSyncSpec) error {
+	logFields(userSync, githubSync).Info("Deleting secret")
+
+	client, err := p.clients.client(ctx, githubSync.Owner, githubSync.Repository)
+	if err != nil {
+		return err
 	}
 
+	if githubSync.Repository == "" {
+		err = deleteOrganizationSecret(ctx, client, githubSync)
+	} else if githubSync.Environment == "" {
+		err = deleteRepositorySecret(ctx, client, githubSync)
+	} else {
+		err = deleteRepositoryEnvSecret(ctx, client, githubSync)
+	}
+	p.clients.invalidate(client, githubSync.Owner, githubSync.Repository, err)
+	if isNotFound(err) {
+		return nil
+	}
 	return err
 }
 
-func isSecretUpToDate(userSync *klum.UserSyncGithub, kubeconfigYAML []byte) (bool, string) {
-	h := sha256.New()
-	h.Write(kubeconfigYAML)
-	hash := fmt.Sprintf("%x", h.Sum(nil))
+// setVariable creates or updates the variable in the single target of githubSync
+func (p *Provider) setVariable(ctx context.Context, userSync *klum.UserSyncGithub, githubSync *klum.GithubSyncSpec, name string, value []byte) error {
+	logFields(userSync, githubSync).WithField("variable", name).Info("Setting variable")
 
-	latestKubeconfigUploaded, present := userSync.Annotations["klum.cattle.io/lastest.upload.github"]
-	if present && latestKubeconfigUploaded == hash {
-		return true, hash
+	client, err := p.clients.client(ctx, githubSync.Owner, githubSync.Repository)
+	if err != nil {
+		return err
 	}
 
-	return false, hash
+	err = setVariable(ctx, client, githubSync, name, value)
+	p.clients.invalidate(client, githubSync.Owner, githubSync.Repository, err)
+	return err
 }
 
-func DeleteKubeconfig(userSync *klum.UserSyncGithub, cfg Config) error {
-	githubSync := userSync.Spec.Github
-	if err := githubSync.Validate(); err != nil {
+// deleteVariable removes the variable from the single target of githubSync, a missing variable is not an error
+func (p *Provider) deleteVariable(ctx context.Context, userSync *klum.UserSyncGithub, githubSync *klum.GithubSyncSpec, name string) error {
+	logFields(userSync, githubSync).WithField("variable", name).Info("Deleting variable")
+
+	client, err := p.clients.client(ctx, githubSync.Owner, githubSync.Repository)
+	if err != nil {
 		return err
 	}
 
-	log.WithFields(log.Fields{
-		"secret": githubSync.SecretName,
-		"user":   userSync.Spec.User,
-		"repo":   fmt.Sprintf("%s/%s", githubSync.Owner, githubSync.Repository),
-		"env":    githubSync.Environment,
-	}).Info("Deleting secret")
+	err = deleteVariable(ctx, client, githubSync, name)
+	p.clients.invalidate(client, githubSync.Owner, githubSync.Repository, err)
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
 
-	client, err := newGithubClient(cfg, githubSync.Owner, githubSync.Repository)
+// get returns the secret in the single target of githubSync
+func (p *Provider) get(ctx context.Context, githubSync *klum.GithubSyncSpec) (*github.Secret, error) {
+	client, err := p.clients.client(ctx, githubSync.Owner, githubSync.Repository)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	ctx := context.Background()
-	if githubSync.Environment == "" {
-		return deleteRepositorySecret(
-			ctx,
-			client,
-			&githubSync,
-		)
+	var secret *github.Secret
+	if githubSync.Repository == "" {
+		secret, err = getOrganizationSecret(ctx, client, githubSync)
+	} else if githubSync.Environment == "" {
+		secret, err = getRepositorySecret(ctx, client, githubSync)
 	} else {
-		return deleteRepositoryEnvSecret(
-			ctx,
-			client,
-			&githubSync,
-		)
+		secret, err = getRepositoryEnvSecret(ctx, client, githubSync)
 	}
+	p.clients.invalidate(client, githubSync.Owner, githubSync.Repository, err)
+	return secret, err
 }
 
-func toYAMLString(x interface{}) ([]byte, error) {
-	b, err := yaml.Marshal(x)
+// updatedAt returns the last time GitHub recorded for the update of secrets in the single target
+// of githubSync, to detect later updates. It is nil if it can't be read, which only skips that check.
+func (p *Provider) updatedAt(ctx context.Context, githubSync *klum.GithubSyncSpec, secrets []renderedValue) *metav1.Time {
+	var updatedAt *metav1.Time
+	for _, rendered := range secrets {
+		secret, err := p.get(ctx, withSecretName(githubSync, rendered.name))
+		if err != nil {
+			log.WithError(err).Warning("Failed to read the secret after uploading it")
+			return nil
+		}
+		if updatedAt == nil || secret.UpdatedAt.After(updatedAt.Time) {
+			updatedAt = &metav1.Time{Time: secret.UpdatedAt.Time}
+		}
+	}
+	return updatedAt
+}
+
+// repositoryID returns the ID of the repository of the single target of githubSync. It is zero for
+// organization secrets and if it can't be read.
+func (p *Provider) repositoryID(ctx context.Context, githubSync *klum.GithubSyncSpec) int64 {
+	if githubSync.Repository == "" {
+		return 0
+	}
+	client, err := p.clients.client(ctx, githubSync.Owner, githubSync.Repository)
 	if err != nil {
-		return []byte{}, err
+		log.WithError(err).Warning("Failed to read the ID of the repository")
+		return 0
+	}
+	id, err := client.repoID(ctx, githubSync.Owner, githubSync.Repository)
+	p.clients.invalidate(client, githubSync.Owner, githubSync.Repository, err)
+	if err != nil {
+		log.WithError(err).Warning("Failed to read the ID of the repository")
+		return 0
+	}
+	return int64(id)
+}
+
+// uploadedNames returns names and the names in hashes, the values uploaded to a target
+func uploadedNames(names []string, hashes map[string]string) []string {
+	for name := range hashes {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// retryError returns err as a usersync.RetryAfterError if it is caused by a rate limit
+func (p *Provider) retryError(err error) error {
+	if after, limited := p.limiter.retryAfter(err); limited {
+		return &usersync.RetryAfterError{Err: err, After: after}
+	}
+	return err
+}
+
+func (p *Provider) setTargets(userSync *klum.UserSyncGithub, targets []klum.TargetStatus) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.targets[userSync.Name] = targets
+}
+
+func (p *Provider) forget(userSync *klum.UserSyncGithub) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	delete(p.targets, userSync.Name)
+	delete(p.drifted, userSync.Name)
+}
+
+func (p *Provider) isDrifted(userSync *klum.UserSyncGithub, key string) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.drifted[userSync.Name][key]
+}
+
+func (p *Provider) setDrifted(userSync *klum.UserSyncGithub, key string, drifted bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if !drifted {
+		delete(p.drifted[userSync.Name], key)
+		return
+	}
+	if p.drifted[userSync.Name] == nil {
+		p.drifted[userSync.Name] = map[string]bool{}
 	}
-	return b, nil
+	p.drifted[userSync.Name][key] = true
+}
+
+// changedSince compares times at the precision of the timestamps of GitHub and of the status
+func changedSince(updatedAt, uploadedAt time.Time) bool {
+	return updatedAt.Truncate(time.Second).After(uploadedAt.Truncate(time.Second))
+}
+
+func logFields(userSync *klum.UserSyncGithub, githubSync *klum.GithubSyncSpec) *log.Entry {
+	fields := log.Fields{
+		"secret": githubSync.SecretName,
+		"user":   userSync.Spec.User,
+	}
+	if githubSync.Repository == "" {
+		fields["org"] = githubSync.Owner
+		fields["visibility"] = githubSync.Visibility
+	} else {
+		fields["repo"] = fmt.Sprintf("%s/%s", githubSync.Owner, githubSync.Repository)
+		fields["env"] = githubSync.Environment
+	}
+	return log.WithFields(fields)
+}
+
+func asUserSyncGithub(sync usersync.Object) (*klum.UserSyncGithub, error) {
+	userSync, ok := sync.(*klum.UserSyncGithub)
+	if !ok {
+		return nil, fmt.Errorf("github provider can't synchronize %T", sync)
+	}
+	return userSync, nil
+}
+
+func isNotFound(err error) bool {
+	var ghErr *github.ErrorResponse
+	return errors.As(err, &ghErr) && ghErr.Response != nil && ghErr.Response.StatusCode == http.StatusNotFound
 }
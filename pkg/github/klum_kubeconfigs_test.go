@@ -0,0 +1,832 @@
+package github
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v63/github"
+	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/nacl/box"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+type secret struct {
+	value                 string
+	visibility            string
+	selectedRepositoryIDs []int64
+	updatedAt             time.Time
+}
+
+// fakeGithub implements the GitHub API calls made by the provider under /api/v3, like GitHub Enterprise
+type fakeGithub struct {
+	lock         sync.Mutex
+	publicKey    *[32]byte
+	privateKey   *[32]byte
+	repositories map[string]int64
+	topics       map[string][]string
+	environments map[string]bool
+	// environmentSettings are the settings of the environments created or updated by klum
+	environmentSettings map[string]*github.CreateUpdateEnvironment
+	branchPolicies      map[string][]*github.DeploymentBranchPolicy
+	secrets             map[string]*secret
+	// variables are stored by scope, e.g. jadolg/klum/KUBE_SERVER and jadolg/klum@prod/KUBE_SERVER
+	variables map[string]string
+	// failing repositories reject secrets
+	failing map[string]bool
+	// override answers the requests it returns true for instead of the fake
+	override func(w http.ResponseWriter, r *http.Request) bool
+	requests int
+}
+
+func newFakeGithub(t *testing.T) *fakeGithub {
+	publicKey, privateKey, err := box.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return &fakeGithub{
+		publicKey:           publicKey,
+		privateKey:          privateKey,
+		repositories:        map[string]int64{"klum": 1, "api": 2, "web": 3},
+		topics:              map[string][]string{"api": {"deploy"}, "web": {"deploy", "frontend"}},
+		environments:        map[string]bool{},
+		environmentSettings: map[string]*github.CreateUpdateEnvironment{},
+		branchPolicies:      map[string][]*github.DeploymentBranchPolicy{},
+		secrets:             map[string]*secret{},
+		variables:           map[string]string{},
+		failing:             map[string]bool{},
+	}
+}
+
+func (f *fakeGithub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.requests++
+	if f.override != nil && f.override(w, r) {
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	path := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v3/"), "/")
+
+	// Secrets are stored by kind and scope, e.g. actions:jadolg/KUBE_CONFIG for an organization secret
+	// and dependabot:jadolg/klum/KUBE_CONFIG for a repository secret
+	var kind, scope string
+	var rest []string
+	switch {
+	case len(path) == 3 && path[0] == "repos":
+		id, ok := f.repositories[path[2]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "name": path[2]})
+		return
+	case len(path) == 3 && path[0] == "orgs" && path[2] == "repos":
+		var repositories []map[string]interface{}
+		for name, id := range f.repositories {
+			repositories = append(repositories, map[string]interface{}{"id": id, "name": name, "topics": f.topics[name]})
+		}
+		repositories = append(repositories, map[string]interface{}{"id": 4, "name": "old-api", "archived": true, "topics": []string{"deploy"}})
+		_ = json.NewEncoder(w).Encode(repositories)
+		return
+	case len(path) == 2 && path[0] == "users":
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"id": 100 + len(path[1]), "login": path[1]})
+		return
+	case len(path) == 4 && path[0] == "orgs" && path[2] == "teams":
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"id": 200 + len(path[3]), "slug": path[3]})
+		return
+	case len(path) >= 5 && path[0] == "repos" && path[3] == "actions" && path[4] == "variables":
+		f.variable(w, r, path[1]+"/"+path[2], path[5:])
+		return
+	case len(path) >= 6 && path[0] == "repos" && path[3] == "environments" && path[5] == "variables":
+		f.variable(w, r, path[1]+"/"+path[2]+"@"+path[4], path[6:])
+		return
+	case len(path) >= 6 && path[0] == "repos" && path[3] == "environments" && path[5] == "deployment-branch-policies":
+		f.branchPolicy(w, r, path[2]+"/"+path[4], path[6:])
+		return
+	case len(path) == 5 && path[0] == "repos" && path[3] == "environments":
+		key := path[2] + "/" + path[4]
+		if r.Method == http.MethodPut {
+			f.environments[key] = true
+			settings := &github.CreateUpdateEnvironment{}
+			_ = json.NewDecoder(r.Body).Decode(settings)
+			f.environmentSettings[key] = settings
+		} else if !f.environments[key] {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		environment := &github.Environment{Name: github.String(path[4])}
+		if settings := f.environmentSettings[key]; settings != nil {
+			environment.DeploymentBranchPolicy = settings.DeploymentBranchPolicy
+		}
+		_ = json.NewEncoder(w).Encode(environment)
+		return
+	case len(path) > 5 && path[0] == "repos" && path[4] == "secrets":
+		if f.failing[path[2]] {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		kind, scope, rest = path[3], path[1]+"/"+path[2], path[5:]
+	case len(path) > 5 && path[0] == "repositories" && path[2] == "environments" && path[4] == "secrets":
+		kind, scope, rest = "actions", path[1]+"@"+path[3], path[5:]
+	case len(path) > 4 && path[0] == "orgs" && path[3] == "secrets":
+		kind, scope, rest = path[2], path[1], path[4:]
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case len(rest) == 1 && rest[0] == "public-key":
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"key_id": kind,
+			"key":    base64.StdEncoding.EncodeToString(f.publicKey[:]),
+		})
+	case len(rest) == 1:
+		f.secret(w, r, kind, kind+":"+scope+"/"+rest[0])
+	case len(rest) == 2 && rest[1] == "repositories" && r.Method == http.MethodPut:
+		s, ok := f.secrets[kind+":"+scope+"/"+rest[0]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		in := struct {
+			SelectedRepositoryIDs []int64 `json:"selected_repository_ids"`
+		}{}
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		s.selectedRepositoryIDs = in.SelectedRepositoryIDs
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeGithub) secret(w http.ResponseWriter, r *http.Request, kind, key string) {
+	s, ok := f.secrets[key]
+	switch r.Method {
+	case http.MethodPut:
+		in := struct {
+			KeyID                 string  `json:"key_id"`
+			EncryptedValue        string  `json:"encrypted_value"`
+			Visibility            string  `json:"visibility"`
+			SelectedRepositoryIDs []int64 `json:"selected_repository_ids"`
+		}{}
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		encrypted, _ := base64.StdEncoding.DecodeString(in.EncryptedValue)
+		value, decrypted := box.OpenAnonymous(nil, encrypted, f.publicKey, f.privateKey)
+		if in.KeyID != kind || !decrypted {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+		if !ok {
+			s = &secret{}
+			f.secrets[key] = s
+		}
+		s.value = string(value)
+		s.visibility = in.Visibility
+		s.updatedAt = time.Now().UTC().Truncate(time.Second)
+		if in.SelectedRepositoryIDs != nil {
+			s.selectedRepositoryIDs = in.SelectedRepositoryIDs
+		}
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodGet:
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"name": key, "visibility": s.visibility, "updated_at": s.updatedAt})
+	case http.MethodDelete:
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.secrets, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (f *fakeGithub) branchPolicy(w http.ResponseWriter, r *http.Request, key string, rest []string) {
+	switch {
+	case len(rest) == 0 && r.Method == http.MethodGet:
+		_ = json.NewEncoder(w).Encode(github.DeploymentBranchPolicyResponse{
+			TotalCount:     github.Int(len(f.branchPolicies[key])),
+			BranchPolicies: f.branchPolicies[key],
+		})
+	case len(rest) == 0 && r.Method == http.MethodPost:
+		request := &github.DeploymentBranchPolicyRequest{}
+		_ = json.NewDecoder(r.Body).Decode(request)
+		policy := &github.DeploymentBranchPolicy{ID: github.Int64(int64(len(f.branchPolicies[key]) + 1)), Name: request.Name, Type: request.Type}
+		f.branchPolicies[key] = append(f.branchPolicies[key], policy)
+		_ = json.NewEncoder(w).Encode(policy)
+	case len(rest) == 1 && r.Method == http.MethodDelete:
+		var kept []*github.DeploymentBranchPolicy
+		for _, policy := range f.branchPolicies[key] {
+			if strconv.FormatInt(policy.GetID(), 10) != rest[0] {
+				kept = append(kept, policy)
+			}
+		}
+		f.branchPolicies[key] = kept
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeGithub) variable(w http.ResponseWriter, r *http.Request, scope string, rest []string) {
+	in := &github.ActionsVariable{}
+	_ = json.NewDecoder(r.Body).Decode(in)
+	switch {
+	case len(rest) == 0 && r.Method == http.MethodPost:
+		if _, ok := f.variables[scope+"/"+in.Name]; ok {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		f.variables[scope+"/"+in.Name] = in.Value
+		w.WriteHeader(http.StatusCreated)
+	case len(rest) == 1 && (r.Method == http.MethodPatch || r.Method == http.MethodDelete):
+		key := scope + "/" + rest[0]
+		if _, ok := f.variables[key]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodPatch {
+			f.variables[key] = in.Value
+		} else {
+			delete(f.variables, key)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestProvider(t *testing.T) (*Provider, *fakeGithub) {
+	fake := newFakeGithub(t)
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return NewProvider(Config{BaseURL: server.URL, Token: "token"}), fake
+}
+
+func newTestSync(spec klum.GithubSyncSpec) *klum.UserSyncGithub {
+	return &klum.UserSyncGithub{
+		ObjectMeta: metav1.ObjectMeta{Name: "darren"},
+		Spec:       klum.UserSyncGithubSpec{User: "darren", Github: spec},
+	}
+}
+
+func TestOrganizationSecret(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	sync := newTestSync(klum.GithubSyncSpec{Owner: "jadolg", SecretName: "KUBE_CONFIG"})
+
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	secret := fake.secrets["actions:jadolg/KUBE_CONFIG"]
+	require.NotNil(t, secret)
+	assert.Equal(t, "kubeconfig", secret.value)
+	assert.Equal(t, klum.GithubVisibilityPrivate, secret.visibility, "default visibility")
+
+	exists, err := provider.Verify(context.Background(), sync)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	require.NoError(t, provider.Delete(context.Background(), sync))
+	assert.Empty(t, fake.secrets)
+
+	exists, err = provider.Verify(context.Background(), sync)
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestOrganizationSecretSelectedRepositories(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	sync := newTestSync(klum.GithubSyncSpec{
+		Owner:                "jadolg",
+		SecretName:           "KUBE_CONFIG",
+		Visibility:           klum.GithubVisibilitySelected,
+		SelectedRepositories: []string{"klum", "api"},
+	})
+
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	secret := fake.secrets["actions:jadolg/KUBE_CONFIG"]
+	require.NotNil(t, secret)
+	assert.Equal(t, klum.GithubVisibilitySelected, secret.visibility)
+	assert.Equal(t, []int64{1, 2}, secret.selectedRepositoryIDs)
+
+	sync.Spec.Github.SelectedRepositories = []string{"web"}
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	assert.Equal(t, []int64{3}, secret.selectedRepositoryIDs)
+
+	sync.Spec.Github.SelectedRepositories = nil
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	assert.Empty(t, secret.selectedRepositoryIDs, "the last repository is removed")
+
+	sync.Spec.Github.SelectedRepositories = []string{"missing"}
+	assert.Error(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+}
+
+func TestKinds(t *testing.T) {
+	for _, kind := range []string{klum.GithubKindActions, klum.GithubKindDependabot, klum.GithubKindCodespaces} {
+		t.Run(kind, func(t *testing.T) {
+			provider, fake := newTestProvider(t)
+			repository := newTestSync(klum.GithubSyncSpec{Owner: "jadolg", Repository: "klum", SecretName: "KUBE_CONFIG", Kind: kind})
+			organization := newTestSync(klum.GithubSyncSpec{
+				Owner:                "jadolg",
+				SecretName:           "KUBE_CONFIG",
+				Kind:                 kind,
+				Visibility:           klum.GithubVisibilitySelected,
+				SelectedRepositories: []string{"klum"},
+			})
+
+			require.NoError(t, provider.Upload(context.Background(), repository, []byte("kubeconfig")))
+			require.NoError(t, provider.Upload(context.Background(), organization, []byte("kubeconfig")))
+			require.Contains(t, fake.secrets, kind+":jadolg/klum/KUBE_CONFIG")
+			require.Contains(t, fake.secrets, kind+":jadolg/KUBE_CONFIG")
+			assert.Equal(t, "kubeconfig", fake.secrets[kind+":jadolg/klum/KUBE_CONFIG"].value)
+			assert.Equal(t, []int64{1}, fake.secrets[kind+":jadolg/KUBE_CONFIG"].selectedRepositoryIDs)
+
+			for _, sync := range []*klum.UserSyncGithub{repository, organization} {
+				exists, err := provider.Verify(context.Background(), sync)
+				require.NoError(t, err)
+				assert.True(t, exists)
+				require.NoError(t, provider.Delete(context.Background(), sync))
+			}
+			assert.Empty(t, fake.secrets)
+		})
+	}
+}
+
+func TestTargets(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	sync := newTestSync(klum.GithubSyncSpec{
+		Owner:      "jadolg",
+		SecretName: "KUBE_CONFIG",
+		Targets: []klum.GithubTarget{
+			{Repository: "klum"},
+			{Repository: "api", Environment: "prod"},
+		},
+	})
+
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	assert.Equal(t, "kubeconfig", fake.secrets["actions:jadolg/klum/KUBE_CONFIG"].value)
+	assert.Equal(t, "kubeconfig", fake.secrets["actions:2@prod/KUBE_CONFIG"].value)
+	assert.True(t, fake.environments["api/prod"], "the environment is created")
+
+	targets := provider.Targets(sync)
+	require.Len(t, targets, 2)
+	for _, target := range targets {
+		assert.Len(t, target.Hash, 64)
+		assert.NotNil(t, target.LastUpload)
+		assert.Empty(t, target.Error)
+	}
+	assert.Equal(t, klum.TargetStatus{Owner: "jadolg", Repository: "api", Environment: "prod"},
+		klum.TargetStatus{Owner: targets[1].Owner, Repository: targets[1].Repository, Environment: targets[1].Environment})
+	assert.Equal(t, int64(1), targets[0].RepositoryID)
+	assert.Equal(t, int64(2), targets[1].RepositoryID)
+
+	exists, err := provider.Verify(context.Background(), sync)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	// Removing a target deletes its secret
+	sync.Status.Targets = targets
+	sync.Spec.Github.Targets = sync.Spec.Github.Targets[1:]
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	assert.NotContains(t, fake.secrets, "actions:jadolg/klum/KUBE_CONFIG")
+	assert.Equal(t, targets[1:], provider.Targets(sync), "the remaining target is up to date")
+
+	sync.Status.Targets = provider.Targets(sync)
+	require.NoError(t, provider.Delete(context.Background(), sync))
+	assert.Empty(t, fake.secrets)
+	assert.Empty(t, provider.Targets(sync))
+}
+
+func TestTargetsPartialFailure(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	fake.failing["api"] = true
+	sync := newTestSync(klum.GithubSyncSpec{
+		Owner:      "jadolg",
+		SecretName: "KUBE_CONFIG",
+		Targets:    []klum.GithubTarget{{Repository: "api"}, {Repository: "klum"}},
+	})
+
+	err := provider.Upload(context.Background(), sync, []byte("kubeconfig"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "jadolg/api/")
+	assert.Equal(t, "kubeconfig", fake.secrets["actions:jadolg/klum/KUBE_CONFIG"].value, "the failure doesn't block other targets")
+
+	targets := provider.Targets(sync)
+	require.Len(t, targets, 2)
+	assert.NotEmpty(t, targets[0].Error)
+	assert.Empty(t, targets[0].Hash)
+	assert.Empty(t, targets[1].Error)
+
+	// Only the failed target is retried
+	fake.failing["api"] = false
+	fake.secrets["actions:jadolg/klum/KUBE_CONFIG"].value = "unchanged"
+	sync.Status.Targets = targets
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	assert.Equal(t, "kubeconfig", fake.secrets["actions:jadolg/api/KUBE_CONFIG"].value)
+	assert.Equal(t, "unchanged", fake.secrets["actions:jadolg/klum/KUBE_CONFIG"].value)
+	assert.Equal(t, targets[1], provider.Targets(sync)[1])
+}
+
+func TestRepositorySelector(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	sync := newTestSync(klum.GithubSyncSpec{
+		Owner:              "jadolg",
+		SecretName:         "KUBE_CONFIG",
+		RepositorySelector: &klum.GithubRepositorySelector{Topic: "deploy"},
+	})
+
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	assert.Len(t, fake.secrets, 2, "archived repositories are ignored")
+	assert.Contains(t, fake.secrets, "actions:jadolg/api/KUBE_CONFIG")
+	assert.Contains(t, fake.secrets, "actions:jadolg/web/KUBE_CONFIG")
+
+	// The repository no longer matching the selector loses the secret
+	sync.Status.Targets = provider.Targets(sync)
+	sync.Spec.Github.RepositorySelector.NamePattern = "w*"
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	assert.Len(t, fake.secrets, 1)
+	assert.Contains(t, fake.secrets, "actions:jadolg/web/KUBE_CONFIG")
+}
+
+func TestValidate(t *testing.T) {
+	for _, spec := range []klum.GithubSyncSpec{
+		{},
+		{Owner: "jadolg"},
+		{Owner: "jadolg", SecretName: "KUBE_CONFIG", Environment: "prod"},
+		{Owner: "jadolg", SecretName: "KUBE_CONFIG", Visibility: "public"},
+		{Owner: "jadolg", SecretName: "KUBE_CONFIG", SelectedRepositories: []string{"klum"}},
+		{Owner: "jadolg", Repository: "klum", SecretName: "KUBE_CONFIG", Visibility: klum.GithubVisibilityAll},
+		{Owner: "jadolg", Repository: "klum", SecretName: "KUBE_CONFIG", Kind: "packages"},
+		{Owner: "jadolg", Repository: "klum", Environment: "prod", SecretName: "KUBE_CONFIG", Kind: klum.GithubKindDependabot},
+		{Owner: "jadolg", SecretName: "KUBE_CONFIG", Targets: []klum.GithubTarget{{}}},
+		{Owner: "jadolg", SecretName: "KUBE_CONFIG", Targets: []klum.GithubTarget{{Repository: "klum", Environment: "prod"}}, Kind: klum.GithubKindCodespaces},
+		{Owner: "jadolg", Repository: "klum", SecretName: "KUBE_CONFIG", Targets: []klum.GithubTarget{{Repository: "api"}}},
+		{Owner: "jadolg", SecretName: "KUBE_CONFIG", RepositorySelector: &klum.GithubRepositorySelector{}},
+		{Owner: "jadolg", SecretName: "KUBE_CONFIG", RepositorySelector: &klum.GithubRepositorySelector{NamePattern: "["}},
+		{Owner: "jadolg", SecretName: "KUBE_CONFIG", RepositorySelector: &klum.GithubRepositorySelector{Topic: "deploy"}, Visibility: klum.GithubVisibilityAll},
+		{Owner: "jadolg", Repository: "klum", SecretName: "KUBE_CONFIG", EnvironmentProtection: &klum.GithubEnvironmentProtection{}},
+		{Owner: "jadolg", Repository: "klum", SecretName: "KUBE_CONFIG", Format: "toml"},
+		{Owner: "jadolg", Repository: "klum", SecretName: "KUBE_CONFIG", Format: klum.GithubFormatTemplate},
+		{Owner: "jadolg", Repository: "klum", SecretName: "KUBE_CONFIG", Template: "{{ .Token }}"},
+		{Owner: "jadolg", Repository: "klum", SecretName: "KUBE_CONFIG", SplitPrefix: "K8S_"},
+		{Owner: "jadolg", Repository: "klum", Environment: "prod", SecretName: "KUBE_CONFIG", EnvironmentProtection: &klum.GithubEnvironmentProtection{Reviewers: []klum.GithubReviewer{{}}}},
+		{Owner: "jadolg", Repository: "klum", Environment: "prod", SecretName: "KUBE_CONFIG", EnvironmentProtection: &klum.GithubEnvironmentProtection{WaitTimer: 50000}},
+		{Owner: "jadolg", Repository: "klum", Environment: "prod", SecretName: "KUBE_CONFIG", EnvironmentProtection: &klum.GithubEnvironmentProtection{DeploymentBranchPolicy: &klum.GithubDeploymentBranchPolicy{}}},
+		{Owner: "jadolg", Repository: "klum", Environment: "prod", SecretName: "KUBE_CONFIG", EnvironmentProtection: &klum.GithubEnvironmentProtection{DeploymentBranchPolicy: &klum.GithubDeploymentBranchPolicy{ProtectedBranches: true, Branches: []string{"main"}}}},
+		{Owner: "jadolg", Repository: "klum", SecretName: "KUBE_CONFIG", Variables: &klum.GithubVariables{}},
+		{Owner: "jadolg", SecretName: "KUBE_CONFIG", Variables: &klum.GithubVariables{Server: true}},
+		{Owner: "jadolg", Repository: "klum", SecretName: "KUBE_CONFIG", Kind: klum.GithubKindDependabot, Variables: &klum.GithubVariables{Server: true}},
+	} {
+		assert.Error(t, spec.Validate(), "%+v", spec)
+	}
+
+	for _, spec := range []klum.GithubSyncSpec{
+		{Owner: "jadolg", Repository: "klum", SecretName: "KUBE_CONFIG"},
+		{Owner: "jadolg", Repository: "klum", Environment: "prod", SecretName: "KUBE_CONFIG"},
+		{Owner: "jadolg", SecretName: "KUBE_CONFIG", Visibility: klum.GithubVisibilityAll},
+		{Owner: "jadolg", SecretName: "KUBE_CONFIG", Visibility: klum.GithubVisibilitySelected},
+		{Owner: "jadolg", Repository: "klum", SecretName: "KUBE_CONFIG", Kind: klum.GithubKindCodespaces},
+		{Owner: "jadolg", SecretName: "KUBE_CONFIG", Targets: []klum.GithubTarget{{Owner: "other", Repository: "klum", Environment: "prod"}}},
+		{Owner: "jadolg", SecretName: "KUBE_CONFIG", RepositorySelector: &klum.GithubRepositorySelector{Topic: "deploy", NamePattern: "api-*"}},
+		{Owner: "jadolg", Repository: "klum", Environment: "prod", SecretName: "KUBE_CONFIG", EnvironmentProtection: &klum.GithubEnvironmentProtection{Reviewers: []klum.GithubReviewer{{Team: "ops"}}, DeploymentBranchPolicy: &klum.GithubDeploymentBranchPolicy{ProtectedBranches: true}}},
+		{Owner: "jadolg", Repository: "klum", SecretName: "KUBE_CONFIG", Format: klum.GithubFormatSplit, SplitPrefix: "K8S_"},
+		{Owner: "jadolg", Repository: "klum", SecretName: "KUBE_CONFIG", Format: klum.GithubFormatTemplate, Template: "{{ .Token }}"},
+		{Owner: "jadolg", Repository: "klum", Environment: "prod", SecretName: "KUBE_CONFIG", Variables: &klum.GithubVariables{Cluster: true}},
+		{Owner: "jadolg", SecretName: "KUBE_CONFIG", Targets: []klum.GithubTarget{{Repository: "klum"}}, Variables: &klum.GithubVariables{Server: true}},
+	} {
+		assert.NoError(t, spec.Validate(), "%+v", spec)
+	}
+}
+
+func TestVerifyDetectsDrift(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	sync := newTestSync(klum.GithubSyncSpec{Owner: "jadolg", Repository: "klum", SecretName: "KUBE_CONFIG"})
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	sync.Status.Targets = provider.Targets(sync)
+	require.NotNil(t, sync.Status.Targets[0].UpdatedAt)
+
+	inSync, err := provider.Verify(context.Background(), sync)
+	require.NoError(t, err)
+	assert.True(t, inSync)
+
+	// Deleted secrets are uploaded again, even though the kubeconfig didn't change
+	delete(fake.secrets, "actions:jadolg/klum/KUBE_CONFIG")
+	inSync, err = provider.Verify(context.Background(), sync)
+	require.NoError(t, err)
+	assert.False(t, inSync)
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	assert.Equal(t, "kubeconfig", fake.secrets["actions:jadolg/klum/KUBE_CONFIG"].value)
+	sync.Status.Targets = provider.Targets(sync)
+
+	// So are overwritten secrets
+	fake.secrets["actions:jadolg/klum/KUBE_CONFIG"].value = "something else"
+	fake.secrets["actions:jadolg/klum/KUBE_CONFIG"].updatedAt = sync.Status.Targets[0].UpdatedAt.Add(time.Minute)
+	inSync, err = provider.Verify(context.Background(), sync)
+	require.NoError(t, err)
+	assert.False(t, inSync)
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	assert.Equal(t, "kubeconfig", fake.secrets["actions:jadolg/klum/KUBE_CONFIG"].value)
+
+	sync.Status.Targets = provider.Targets(sync)
+	inSync, err = provider.Verify(context.Background(), sync)
+	require.NoError(t, err)
+	assert.True(t, inSync)
+}
+
+func TestVerifySelectorChanges(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	now := time.Now()
+	provider.clients.selections.now = func() time.Time { return now }
+	sync := newTestSync(klum.GithubSyncSpec{
+		Owner:              "jadolg",
+		SecretName:         "KUBE_CONFIG",
+		RepositorySelector: &klum.GithubRepositorySelector{Topic: "deploy"},
+	})
+	assert.Equal(t, selectionTTL, provider.ResyncInterval(sync))
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	sync.Status.Targets = provider.Targets(sync)
+
+	inSync, err := provider.Verify(context.Background(), sync)
+	require.NoError(t, err)
+	assert.True(t, inSync)
+
+	// A new matching repository is uploaded to once the selection expires
+	fake.topics["klum"] = []string{"deploy"}
+	now = now.Add(selectionTTL)
+	inSync, err = provider.Verify(context.Background(), sync)
+	require.NoError(t, err)
+	assert.False(t, inSync)
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	assert.Contains(t, fake.secrets, "actions:jadolg/klum/KUBE_CONFIG")
+	sync.Status.Targets = provider.Targets(sync)
+
+	// So is a repository that no longer matches
+	fake.topics["klum"] = nil
+	now = now.Add(selectionTTL)
+	inSync, err = provider.Verify(context.Background(), sync)
+	require.NoError(t, err)
+	assert.False(t, inSync)
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	assert.NotContains(t, fake.secrets, "actions:jadolg/klum/KUBE_CONFIG")
+
+	sync.Spec.Github.RepositorySelector = nil
+	sync.Spec.Github.Repository = "klum"
+	assert.Zero(t, provider.ResyncInterval(sync))
+}
+
+func TestEnvironmentProtection(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	protection := &klum.GithubEnvironmentProtection{
+		Reviewers:         []klum.GithubReviewer{{User: "jadolg"}, {Team: "ops"}},
+		WaitTimer:         30,
+		PreventSelfReview: true,
+		DeploymentBranchPolicy: &klum.GithubDeploymentBranchPolicy{
+			Branches: []string{"main", "release/*"},
+			Tags:     []string{"v*"},
+		},
+	}
+	sync := newTestSync(klum.GithubSyncSpec{
+		Owner:                 "jadolg",
+		Repository:            "api",
+		Environment:           "prod",
+		SecretName:            "KUBE_CONFIG",
+		EnvironmentProtection: protection,
+	})
+
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	settings := fake.environmentSettings["api/prod"]
+	require.NotNil(t, settings)
+	assert.Equal(t, 30, settings.GetWaitTimer())
+	assert.True(t, settings.GetPreventSelfReview())
+	require.Len(t, settings.Reviewers, 2)
+	assert.Equal(t, "User", settings.Reviewers[0].GetType())
+	assert.Equal(t, int64(106), settings.Reviewers[0].GetID())
+	assert.Equal(t, "Team", settings.Reviewers[1].GetType())
+	assert.Equal(t, int64(203), settings.Reviewers[1].GetID())
+	assert.True(t, settings.DeploymentBranchPolicy.GetCustomBranchPolicies())
+	assert.False(t, settings.DeploymentBranchPolicy.GetProtectedBranches())
+	assert.Equal(t, []string{"branch/main", "branch/release/*", "tag/v*"}, branchPolicyKeys(fake.branchPolicies["api/prod"]))
+
+	// Existing environments are left alone
+	sync.Status.Targets = provider.Targets(sync)
+	delete(fake.environmentSettings, "api/prod")
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("new kubeconfig")))
+	assert.NotContains(t, fake.environmentSettings, "api/prod")
+
+	// Unless the protection is enforced
+	protection.Enforce = true
+	protection.DeploymentBranchPolicy.Branches = []string{"main"}
+	sync.Status.Targets = provider.Targets(sync)
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("new kubeconfig")))
+	assert.Contains(t, fake.environmentSettings, "api/prod")
+	assert.Equal(t, []string{"branch/main", "tag/v*"}, branchPolicyKeys(fake.branchPolicies["api/prod"]))
+}
+
+func TestEnvironmentBranchPoliciesRepaired(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	fake.override = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/deployment-branch-policies") {
+			return false
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return true
+	}
+	sync := newTestSync(klum.GithubSyncSpec{
+		Owner:       "jadolg",
+		Repository:  "api",
+		Environment: "prod",
+		SecretName:  "KUBE_CONFIG",
+		EnvironmentProtection: &klum.GithubEnvironmentProtection{
+			DeploymentBranchPolicy: &klum.GithubDeploymentBranchPolicy{Branches: []string{"main"}},
+		},
+	})
+
+	require.Error(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	require.True(t, fake.environments["api/prod"], "the environment is created before its patterns")
+	assert.Empty(t, fake.branchPolicies["api/prod"])
+
+	// The patterns of the environment left without any are created, although it exists and isn't enforced
+	fake.override = nil
+	sync.Status.Targets = provider.Targets(sync)
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	assert.Equal(t, []string{"branch/main"}, branchPolicyKeys(fake.branchPolicies["api/prod"]))
+
+	// And left alone once they exist
+	fake.branchPolicies["api/prod"] = []*github.DeploymentBranchPolicy{{ID: github.Int64(1), Name: github.String("develop")}}
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("new kubeconfig")))
+	assert.Equal(t, []string{"branch/develop"}, branchPolicyKeys(fake.branchPolicies["api/prod"]))
+}
+
+func TestEnvironmentWithoutProtection(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	sync := newTestSync(klum.GithubSyncSpec{Owner: "jadolg", Repository: "api", Environment: "prod", SecretName: "KUBE_CONFIG"})
+
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	settings := fake.environmentSettings["api/prod"]
+	require.NotNil(t, settings)
+	assert.Empty(t, settings.Reviewers)
+	assert.Zero(t, settings.GetWaitTimer())
+	assert.Nil(t, settings.DeploymentBranchPolicy)
+	assert.Empty(t, fake.branchPolicies)
+}
+
+func branchPolicyKeys(policies []*github.DeploymentBranchPolicy) []string {
+	var keys []string
+	for _, policy := range policies {
+		keys = append(keys, branchPolicyKey(policy.GetType(), policy.GetName()))
+	}
+	return keys
+}
+
+func testKubeconfig(t *testing.T, token string) []byte {
+	payload, err := yaml.Marshal(klum.KubeconfigSpec{
+		Clusters: []klum.NamedCluster{{Name: "cluster", Cluster: klum.Cluster{
+			Server:                   "https://k8s.example.com:6443",
+			CertificateAuthorityData: base64.StdEncoding.EncodeToString([]byte("PEM")),
+		}}},
+		AuthInfos:      []klum.NamedAuthInfo{{Name: "darren", AuthInfo: klum.AuthInfo{Token: token}}},
+		Contexts:       []klum.NamedContext{{Name: "default", Context: klum.Context{Cluster: "cluster", AuthInfo: "darren", Namespace: "apps"}}},
+		CurrentContext: "default",
+	})
+	require.NoError(t, err)
+	return payload
+}
+
+func TestFormats(t *testing.T) {
+	payload := testKubeconfig(t, "token")
+	for format, expected := range map[string]string{
+		"":                          string(payload),
+		klum.GithubFormatYAML:       string(payload),
+		klum.GithubFormatBase64YAML: base64.StdEncoding.EncodeToString(payload),
+		klum.GithubFormatTemplate:   "https://k8s.example.com:6443 apps UEVN",
+	} {
+		provider, fake := newTestProvider(t)
+		sync := newTestSync(klum.GithubSyncSpec{Owner: "jadolg", Repository: "klum", SecretName: "KUBE_CONFIG", Format: format})
+		if format == klum.GithubFormatTemplate {
+			sync.Spec.Github.Template = "{{ .Server }} {{ .Namespace }} {{ base64 .CA }}"
+		}
+		require.NoError(t, provider.Upload(context.Background(), sync, payload))
+		assert.Equal(t, expected, fake.secrets["actions:jadolg/klum/KUBE_CONFIG"].value, format)
+	}
+
+	provider, fake := newTestProvider(t)
+	sync := newTestSync(klum.GithubSyncSpec{Owner: "jadolg", Repository: "klum", SecretName: "KUBE_CONFIG", Format: klum.GithubFormatJSON})
+	require.NoError(t, provider.Upload(context.Background(), sync, payload))
+	kubeconfig := klum.KubeconfigSpec{}
+	require.NoError(t, json.Unmarshal([]byte(fake.secrets["actions:jadolg/klum/KUBE_CONFIG"].value), &kubeconfig))
+	assert.Equal(t, "default", kubeconfig.CurrentContext)
+
+	sync.Spec.Github.Format = klum.GithubFormatTemplate
+	sync.Spec.Github.Template = "{{ .Missing }}"
+	assert.Error(t, provider.Upload(context.Background(), sync, payload))
+}
+
+func TestSplitFormat(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	sync := newTestSync(klum.GithubSyncSpec{Owner: "jadolg", Repository: "klum", SecretName: "KUBE_CONFIG", Format: klum.GithubFormatSplit, SplitPrefix: "K8S_"})
+
+	require.NoError(t, provider.Upload(context.Background(), sync, testKubeconfig(t, "token")))
+	assert.Equal(t, "https://k8s.example.com:6443", fake.secrets["actions:jadolg/klum/K8S_SERVER"].value)
+	assert.Equal(t, "PEM", fake.secrets["actions:jadolg/klum/K8S_CA"].value)
+	assert.Equal(t, "token", fake.secrets["actions:jadolg/klum/K8S_TOKEN"].value)
+	assert.NotContains(t, fake.secrets, "actions:jadolg/klum/KUBE_CONFIG")
+	sync.Status.Targets = provider.Targets(sync)
+	assert.Len(t, sync.Status.Targets[0].SecretHashes, 3)
+
+	// Only the secret that changed is uploaded again
+	fake.secrets["actions:jadolg/klum/K8S_SERVER"].value = "untouched"
+	require.NoError(t, provider.Upload(context.Background(), sync, testKubeconfig(t, "new-token")))
+	assert.Equal(t, "untouched", fake.secrets["actions:jadolg/klum/K8S_SERVER"].value)
+	assert.Equal(t, "new-token", fake.secrets["actions:jadolg/klum/K8S_TOKEN"].value)
+	sync.Status.Targets = provider.Targets(sync)
+
+	// Secrets that are no longer rendered are deleted
+	sync.Spec.Github.Format = klum.GithubFormatYAML
+	sync.Spec.Github.SplitPrefix = ""
+	require.NoError(t, provider.Upload(context.Background(), sync, testKubeconfig(t, "new-token")))
+	assert.Contains(t, fake.secrets, "actions:jadolg/klum/KUBE_CONFIG")
+	assert.Len(t, fake.secrets, 1)
+	sync.Status.Targets = provider.Targets(sync)
+
+	require.NoError(t, provider.Delete(context.Background(), sync))
+	assert.Empty(t, fake.secrets)
+}
+
+func TestSplitFormatNeedsToken(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	sync := newTestSync(klum.GithubSyncSpec{Owner: "jadolg", Repository: "klum", SecretName: "KUBE_CONFIG", Format: klum.GithubFormatSplit})
+
+	// A kubeconfig authenticating with a client certificate only
+	err := provider.Upload(context.Background(), sync, testKubeconfig(t, ""))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "needs a token")
+	assert.Empty(t, fake.secrets, "no empty token is uploaded")
+}
+
+func TestVariables(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	sync := newTestSync(klum.GithubSyncSpec{Owner: "jadolg", Repository: "klum", SecretName: "KUBE_CONFIG", Variables: &klum.GithubVariables{
+		Server:    true,
+		Context:   true,
+		Namespace: true,
+		Cluster:   true,
+	}})
+
+	require.NoError(t, provider.Upload(context.Background(), sync, testKubeconfig(t, "token")))
+	assert.Equal(t, map[string]string{
+		"jadolg/klum/KUBE_SERVER":    "https://k8s.example.com:6443",
+		"jadolg/klum/KUBE_CONTEXT":   "default",
+		"jadolg/klum/KUBE_NAMESPACE": "apps",
+		"jadolg/klum/KUBE_CLUSTER":   "cluster",
+	}, fake.variables)
+	sync.Status.Targets = provider.Targets(sync)
+	assert.Len(t, sync.Status.Targets[0].VariableHashes, 4)
+
+	// Variables are only set again when their values change
+	fake.variables["jadolg/klum/KUBE_SERVER"] = "untouched"
+	require.NoError(t, provider.Upload(context.Background(), sync, testKubeconfig(t, "new-token")))
+	assert.Equal(t, "untouched", fake.variables["jadolg/klum/KUBE_SERVER"])
+	assert.Equal(t, string(testKubeconfig(t, "new-token")), fake.secrets["actions:jadolg/klum/KUBE_CONFIG"].value)
+	sync.Status.Targets = provider.Targets(sync)
+
+	// Variables that are no longer selected are deleted
+	sync.Spec.Github.Variables = &klum.GithubVariables{Prefix: "K8S_", Server: true}
+	require.NoError(t, provider.Upload(context.Background(), sync, testKubeconfig(t, "new-token")))
+	assert.Equal(t, map[string]string{"jadolg/klum/K8S_SERVER": "https://k8s.example.com:6443"}, fake.variables)
+	sync.Status.Targets = provider.Targets(sync)
+
+	require.NoError(t, provider.Delete(context.Background(), sync))
+	assert.Empty(t, fake.variables)
+	assert.Empty(t, fake.secrets)
+}
+
+func TestEnvironmentVariables(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	sync := newTestSync(klum.GithubSyncSpec{Owner: "jadolg", Repository: "klum", Environment: "prod", SecretName: "KUBE_CONFIG", Variables: &klum.GithubVariables{Namespace: true}})
+
+	require.NoError(t, provider.Upload(context.Background(), sync, testKubeconfig(t, "token")))
+	assert.Equal(t, map[string]string{"jadolg/klum@prod/KUBE_NAMESPACE": "apps"}, fake.variables)
+	sync.Status.Targets = provider.Targets(sync)
+
+	// Removing the variables deletes them without uploading the secret again
+	delete(fake.secrets, "actions:1@prod/KUBE_CONFIG")
+	sync.Spec.Github.Variables = nil
+	require.NoError(t, provider.Upload(context.Background(), sync, testKubeconfig(t, "token")))
+	assert.Empty(t, fake.variables)
+	assert.Empty(t, fake.secrets)
+}
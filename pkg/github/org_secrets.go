@@ -0,0 +1,68 @@
+package github
+
+import (
+	"context"
+
+	"github.com/google/go-github/v63/github"
+	"github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+)
+
+func createOrganizationSecret(ctx context.Context, client *cachedClient, syncSpec *v1alpha1.GithubSyncSpec, secretValue []byte) error {
+	visibility := syncSpec.Visibility
+	if visibility == "" {
+		visibility = v1alpha1.GithubVisibilityPrivate
+	}
+
+	var selectedRepositoryIDs github.SelectedRepoIDs
+	if visibility == v1alpha1.GithubVisibilitySelected {
+		selectedRepositoryIDs = github.SelectedRepoIDs{}
+		for _, repository := range syncSpec.SelectedRepositories {
+			repositoryID, err := client.repoID(ctx, syncSpec.Owner, repository)
+			if err != nil {
+				return err
+			}
+			selectedRepositoryIDs = append(selectedRepositoryIDs, int64(repositoryID))
+		}
+	}
+
+	secrets := secretsAPIFor(client.Client, syncSpec.Kind)
+	key, err := client.publicKey(syncSpec.Owner, "", kind(syncSpec), func() (*github.PublicKey, *github.Response, error) {
+		return secrets.GetOrgPublicKey(ctx, syncSpec.Owner)
+	})
+	if err != nil {
+		return err
+	}
+
+	encryptedSecret, err := encodeWithPublicKey(secretValue, key.GetKey())
+	if err != nil {
+		return err
+	}
+
+	secret := &github.EncryptedSecret{
+		Name:                  syncSpec.SecretName,
+		KeyID:                 key.GetKeyID(),
+		EncryptedValue:        encryptedSecret,
+		Visibility:            visibility,
+		SelectedRepositoryIDs: selectedRepositoryIDs,
+	}
+
+	_, err = secrets.CreateOrUpdateOrgSecret(ctx, syncSpec.Owner, secret)
+	if err != nil || visibility != v1alpha1.GithubVisibilitySelected {
+		return err
+	}
+
+	// An empty list of repositories is omitted when the secret is written, so the
+	// repositories are always replaced to remove the ones no longer selected
+	_, err = secrets.SetSelectedReposForOrgSecret(ctx, syncSpec.Owner, syncSpec.SecretName, selectedRepositoryIDs)
+	return err
+}
+
+func deleteOrganizationSecret(ctx context.Context, client *cachedClient, syncSpec *v1alpha1.GithubSyncSpec) error {
+	_, err := secretsAPIFor(client.Client, syncSpec.Kind).DeleteOrgSecret(ctx, syncSpec.Owner, syncSpec.SecretName)
+	return err
+}
+
+func getOrganizationSecret(ctx context.Context, client *cachedClient, syncSpec *v1alpha1.GithubSyncSpec) (*github.Secret, error) {
+	secret, _, err := secretsAPIFor(client.Client, syncSpec.Kind).GetOrgSecret(ctx, syncSpec.Owner, syncSpec.SecretName)
+	return secret, err
+}
@@ -0,0 +1,203 @@
+package github
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"text/template"
+
+	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	"github.com/jadolg/klum/pkg/render"
+	"sigs.k8s.io/yaml"
+)
+
+// renderedValue is a secret or variable to create in every target
+type renderedValue struct {
+	name  string
+	value []byte
+}
+
+// templateData is available to the template format
+type templateData struct {
+	Kubeconfig string
+	Server     string
+	CA         string
+	Token      string
+	Context    string
+	Namespace  string
+	Cluster    string
+}
+
+var templateFuncs = template.FuncMap{
+	"base64": func(value string) string {
+		return base64.StdEncoding.EncodeToString([]byte(value))
+	},
+}
+
+// renderSecrets returns the secrets holding payload, the YAML of a kubeconfig, in the format of spec
+func renderSecrets(spec *klum.GithubSyncSpec, payload []byte) ([]renderedValue, error) {
+	switch spec.Format {
+	case klum.GithubFormatJSON:
+		value, err := yaml.YAMLToJSON(payload)
+		if err != nil {
+			return nil, err
+		}
+		return []renderedValue{{name: spec.SecretName, value: value}}, nil
+	case klum.GithubFormatBase64YAML:
+		value := base64.StdEncoding.EncodeToString(payload)
+		return []renderedValue{{name: spec.SecretName, value: []byte(value)}}, nil
+	case klum.GithubFormatSplit:
+		data, err := kubeconfigData(payload)
+		if err != nil {
+			return nil, err
+		}
+		if data.Token == "" {
+			// Only the token is split out, a client certificate would be lost
+			return nil, fmt.Errorf("the %s format needs a token but the current context %q has none, use another format for client certificates",
+				klum.GithubFormatSplit, data.Context)
+		}
+		names := secretNames(spec)
+		return []renderedValue{
+			{name: names[0], value: []byte(data.Server)},
+			{name: names[1], value: []byte(data.CA)},
+			{name: names[2], value: []byte(data.Token)},
+		}, nil
+	case klum.GithubFormatTemplate:
+		tmpl, err := template.New("secret").Funcs(templateFuncs).Option("missingkey=error").Parse(spec.Template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template: %w", err)
+		}
+		data, err := kubeconfigData(payload)
+		if err != nil {
+			return nil, err
+		}
+		var value bytes.Buffer
+		if err := tmpl.Execute(&value, data); err != nil {
+			return nil, fmt.Errorf("invalid template: %w", err)
+		}
+		return []renderedValue{{name: spec.SecretName, value: value.Bytes()}}, nil
+	default:
+		return []renderedValue{{name: spec.SecretName, value: payload}}, nil
+	}
+}
+
+// secretNames returns the names of the secrets created in every target of spec
+func secretNames(spec *klum.GithubSyncSpec) []string {
+	if spec.Format != klum.GithubFormatSplit {
+		return []string{spec.SecretName}
+	}
+	prefix := spec.SplitPrefix
+	if prefix == "" {
+		prefix = spec.SecretName + "_"
+	}
+	return []string{prefix + "SERVER", prefix + "CA", prefix + "TOKEN"}
+}
+
+// renderVariables returns the variables of spec with the values of payload, the YAML of a kubeconfig
+func renderVariables(spec *klum.GithubSyncSpec, payload []byte) ([]renderedValue, error) {
+	if spec.Variables == nil {
+		return nil, nil
+	}
+	data, err := kubeconfigData(payload)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]string{
+		"SERVER":    data.Server,
+		"CONTEXT":   data.Context,
+		"NAMESPACE": data.Namespace,
+		"CLUSTER":   data.Cluster,
+	}
+	var variables []renderedValue
+	prefix := variablePrefix(spec.Variables)
+	for _, name := range variableNames(spec) {
+		variables = append(variables, renderedValue{name: name, value: []byte(values[name[len(prefix):]])})
+	}
+	return variables, nil
+}
+
+// variableNames returns the names of the variables created in every target of spec
+func variableNames(spec *klum.GithubSyncSpec) []string {
+	variables := spec.Variables
+	if variables == nil {
+		return nil
+	}
+	prefix := variablePrefix(variables)
+	var names []string
+	if variables.Server {
+		names = append(names, prefix+"SERVER")
+	}
+	if variables.Context {
+		names = append(names, prefix+"CONTEXT")
+	}
+	if variables.Namespace {
+		names = append(names, prefix+"NAMESPACE")
+	}
+	if variables.Cluster {
+		names = append(names, prefix+"CLUSTER")
+	}
+	return names
+}
+
+func variablePrefix(variables *klum.GithubVariables) string {
+	if variables.Prefix == "" {
+		return "KUBE_"
+	}
+	return variables.Prefix
+}
+
+func kubeconfigData(payload []byte) (*templateData, error) {
+	kubeconfig := klum.KubeconfigSpec{}
+	if err := yaml.Unmarshal(payload, &kubeconfig); err != nil {
+		return nil, err
+	}
+	cluster, authInfo, err := render.CurrentCredentials(&kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	ca, err := base64.StdEncoding.DecodeString(cluster.CertificateAuthorityData)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &templateData{
+		Kubeconfig: string(payload),
+		Server:     cluster.Server,
+		CA:         string(ca),
+		Token:      authInfo.Token,
+		Context:    kubeconfig.CurrentContext,
+	}
+	for _, named := range kubeconfig.Contexts {
+		if named.Name == kubeconfig.CurrentContext {
+			data.Namespace = named.Context.Namespace
+			data.Cluster = named.Context.Cluster
+		}
+	}
+	return data, nil
+}
+
+// renderedPayload is what the hash of a target covers. A single secret is covered by its value
+// alone, so the hash of the default format didn't change when formats and variables were added.
+func renderedPayload(secrets, variables []renderedValue) []byte {
+	if len(secrets) == 1 && len(variables) == 0 {
+		return secrets[0].value
+	}
+	hashes := map[string]string{}
+	for _, secret := range secrets {
+		hashes[secret.name] = fmt.Sprintf("%x", sha256.Sum256(secret.value))
+	}
+	for _, variable := range variables {
+		hashes["variables/"+variable.name] = fmt.Sprintf("%x", sha256.Sum256(variable.value))
+	}
+	payload, _ := json.Marshal(hashes)
+	return payload
+}
+
+// withSecretName returns spec for the secret name
+func withSecretName(spec *klum.GithubSyncSpec, name string) *klum.GithubSyncSpec {
+	single := *spec
+	single.SecretName = name
+	return &single
+}
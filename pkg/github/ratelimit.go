@@ -0,0 +1,236 @@
+package github
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v63/github"
+	"github.com/jadolg/klum/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// secondaryLimitBackoff is the wait after a secondary rate limit without Retry-After, it doubles
+	// on every consecutive one up to maxSecondaryLimitBackoff
+	secondaryLimitBackoff    = time.Minute
+	maxSecondaryLimitBackoff = 30 * time.Minute
+)
+
+// allResources is the resource of the limits blocking every resource of an installation, GitHub
+// doesn't tell which resource a secondary rate limit applies to
+const allResources = ""
+
+// limitKey identifies a rate limit. GitHub counts requests by installation, or by token, and by the
+// resource reported in X-RateLimit-Resource.
+type limitKey struct {
+	installation string
+	resource     string
+}
+
+// rateLimitedError is returned for requests not sent because a rate limit is exceeded
+type rateLimitedError struct {
+	key   limitKey
+	until time.Time
+}
+
+func (e *rateLimitedError) Error() string {
+	return fmt.Sprintf("GitHub rate limit of %s exceeded until %s", e.key.installation, e.until.Format(time.RFC3339))
+}
+
+// rateLimiter remembers the rate limits reported by GitHub. It is shared by all the clients of a
+// Provider, so once a limit is hit no request counted against it is sent until it is reset, without
+// blocking a worker.
+type rateLimiter struct {
+	lock         sync.Mutex
+	blockedUntil map[limitKey]time.Time
+	// secondaryLimits is the number of consecutive secondary rate limits without Retry-After, by installation
+	secondaryLimits map[string]int
+	now             func() time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		blockedUntil:    map[limitKey]time.Time{},
+		secondaryLimits: map[string]int{},
+		now:             time.Now,
+	}
+}
+
+// installationLabel names the installation whose limits apply to the requests of a client, 0 is
+// the client authenticated with the token
+func installationLabel(installationID int64) string {
+	if installationID == 0 {
+		return "token"
+	}
+	return strconv.FormatInt(installationID, 10)
+}
+
+// appLabel names the limits of the requests authenticated as the GitHub App itself
+const appLabel = "app"
+
+// transport returns a transport sending the requests of installation with base while none of its
+// rate limits is exceeded. Rate limited responses are returned as a rateLimitedError.
+func (l *rateLimiter) transport(base http.RoundTripper, installation string) http.RoundTripper {
+	return &rateLimitTransport{base: base, limiter: l, installation: installation}
+}
+
+type rateLimitTransport struct {
+	base         http.RoundTripper
+	limiter      *rateLimiter
+	installation string
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := limitKey{installation: t.installation, resource: requestResource(req)}
+	if until := t.limiter.blocked(key); !until.IsZero() {
+		metrics.GithubRequestsDeferredTotal.Inc()
+		return nil, &rateLimitedError{key: key, until: until}
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if t.limiter.observe(t.installation, resp) {
+		if until := t.limiter.blocked(key); !until.IsZero() {
+			_ = resp.Body.Close()
+			return nil, &rateLimitedError{key: key, until: until}
+		}
+	}
+	return resp, nil
+}
+
+// requestResource guesses the resource a request is counted against before it is sent
+func requestResource(req *http.Request) string {
+	switch {
+	case strings.Contains(req.URL.Path, "/search/"):
+		return "search"
+	case strings.HasSuffix(req.URL.Path, "/graphql"):
+		return "graphql"
+	default:
+		return "core"
+	}
+}
+
+// blocked returns when the exceeded rate limit of key is reset, zero if there is none
+func (l *rateLimiter) blocked(key limitKey) time.Time {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	until := l.blockedUntil[key]
+	if all := l.blockedUntil[limitKey{installation: key.installation, resource: allResources}]; all.After(until) {
+		until = all
+	}
+	if l.now().Before(until) {
+		return until
+	}
+	return time.Time{}
+}
+
+// observe records the rate limit headers of resp to a request of installation and returns whether
+// it was rejected by a rate limit
+func (l *rateLimiter) observe(installation string, resp *http.Response) bool {
+	resource := resp.Header.Get("X-RateLimit-Resource")
+	if resource == "" {
+		resource = requestResource(resp.Request)
+	}
+	key := limitKey{installation: installation, resource: resource}
+
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	hasRemaining := err == nil
+	if hasRemaining {
+		metrics.GithubRateLimitRemaining.WithLabelValues(installation, resource).Set(float64(remaining))
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if hasRemaining && remaining == 0 {
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			l.block(key, time.Unix(reset, 0))
+		}
+	}
+
+	if !isRateLimited(resp) {
+		if resp.StatusCode < http.StatusBadRequest {
+			delete(l.secondaryLimits, installation)
+		}
+		return false
+	}
+
+	if hasRemaining && remaining == 0 {
+		metrics.GithubRateLimitedTotal.WithLabelValues("primary").Inc()
+		return true
+	}
+	metrics.GithubRateLimitedTotal.WithLabelValues("secondary").Inc()
+	key.resource = allResources
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		l.block(key, l.now().Add(time.Duration(seconds)*time.Second))
+		return true
+	}
+	// GitHub asks to wait at least a minute and to increase the wait exponentially
+	backoff := secondaryLimitBackoff << min(l.secondaryLimits[installation], 5)
+	l.secondaryLimits[installation]++
+	l.block(key, l.now().Add(min(backoff, maxSecondaryLimitBackoff)))
+	return true
+}
+
+func (l *rateLimiter) block(key limitKey, until time.Time) {
+	if until.After(l.blockedUntil[key]) {
+		l.blockedUntil[key] = until
+		log.WithFields(log.Fields{
+			"installation": key.installation,
+			"resource":     key.resource,
+			"until":        until.Format(time.RFC3339),
+		}).Warning("GitHub rate limit exceeded")
+	}
+}
+
+// retryAfter returns how long to wait before retrying after err, jittered so syncs don't all
+// retry at the same time. It is false if err isn't caused by a rate limit.
+func (l *rateLimiter) retryAfter(err error) (time.Duration, bool) {
+	var until time.Time
+	var limited *rateLimitedError
+	var primary *github.RateLimitError
+	var secondary *github.AbuseRateLimitError
+	switch {
+	case errors.As(err, &limited):
+		until = limited.until
+	case errors.As(err, &primary):
+		until = primary.Rate.Reset.Time
+	case errors.As(err, &secondary) && secondary.RetryAfter != nil:
+		until = l.now().Add(*secondary.RetryAfter)
+	default:
+		return 0, false
+	}
+
+	delay := max(until.Sub(l.now()), time.Second)
+	return delay + rand.N(delay/5+time.Second), true
+}
+
+// isRateLimited reports whether resp was rejected by a rate limit. GitHub also answers 403 to
+// requests without permission, rate limits are told apart by their headers or message.
+func isRateLimited(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusForbidden:
+	default:
+		return false
+	}
+	if resp.Header.Get("Retry-After") != "" || resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		return true
+	}
+
+	// The body is read again by the client
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return strings.Contains(strings.ToLower(string(body)), "rate limit")
+}
@@ -0,0 +1,165 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	"github.com/jadolg/klum/pkg/usersync"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func requireRetryAfter(t *testing.T, err error, min, max time.Duration) {
+	t.Helper()
+	var retryAfter *usersync.RetryAfterError
+	require.True(t, errors.As(err, &retryAfter), "%v is not a RetryAfterError", err)
+	assert.GreaterOrEqual(t, retryAfter.After, min)
+	assert.LessOrEqual(t, retryAfter.After, max)
+}
+
+func TestPrimaryRateLimit(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	reset := time.Now().Add(time.Hour)
+	fake.override = func(w http.ResponseWriter, r *http.Request) bool {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"message":"API rate limit exceeded"}`))
+		return true
+	}
+	sync := newTestSync(klum.GithubSyncSpec{Owner: "jadolg", Repository: "klum", SecretName: "KUBE_CONFIG"})
+
+	err := provider.Upload(context.Background(), sync, []byte("kubeconfig"))
+	requireRetryAfter(t, err, 59*time.Minute, 73*time.Minute)
+	assert.Equal(t, 1, fake.requests)
+
+	// Nothing is sent until the limit is reset
+	err = provider.Upload(context.Background(), sync, []byte("kubeconfig"))
+	requireRetryAfter(t, err, 59*time.Minute, 73*time.Minute)
+	assert.Equal(t, 1, fake.requests)
+}
+
+func TestSecondaryRateLimit(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	fake.override = func(w http.ResponseWriter, r *http.Request) bool {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+		return true
+	}
+	sync := newTestSync(klum.GithubSyncSpec{Owner: "jadolg", Repository: "klum", SecretName: "KUBE_CONFIG"})
+
+	err := provider.Upload(context.Background(), sync, []byte("kubeconfig"))
+	requireRetryAfter(t, err, 29*time.Second, 38*time.Second)
+}
+
+func TestSecondaryRateLimitBackoff(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	fake.override = func(w http.ResponseWriter, r *http.Request) bool {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"message":"You have exceeded a secondary rate limit"}`))
+		return true
+	}
+	sync := newTestSync(klum.GithubSyncSpec{Owner: "jadolg", Repository: "klum", SecretName: "KUBE_CONFIG"})
+
+	now := time.Now()
+	provider.limiter.now = func() time.Time { return now }
+	err := provider.Upload(context.Background(), sync, []byte("kubeconfig"))
+	requireRetryAfter(t, err, time.Minute, 73*time.Second)
+
+	now = now.Add(time.Hour)
+	err = provider.Upload(context.Background(), sync, []byte("kubeconfig"))
+	requireRetryAfter(t, err, 2*time.Minute, 145*time.Second)
+}
+
+func TestForbiddenIsNotRateLimited(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	fake.override = func(w http.ResponseWriter, r *http.Request) bool {
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"message":"Resource not accessible by integration"}`))
+		return true
+	}
+	sync := newTestSync(klum.GithubSyncSpec{Owner: "jadolg", Repository: "klum", SecretName: "KUBE_CONFIG"})
+
+	err := provider.Upload(context.Background(), sync, []byte("kubeconfig"))
+	require.Error(t, err)
+	var retryAfter *usersync.RetryAfterError
+	assert.False(t, errors.As(err, &retryAfter))
+	assert.Empty(t, provider.limiter.blockedUntil)
+}
+
+func TestRateLimitStopsTargets(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	fake.override = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodPut {
+			return false
+		}
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusForbidden)
+		return true
+	}
+	sync := newTestSync(klum.GithubSyncSpec{
+		Owner:      "jadolg",
+		SecretName: "KUBE_CONFIG",
+		Targets:    []klum.GithubTarget{{Repository: "klum"}, {Repository: "api"}},
+	})
+
+	err := provider.Upload(context.Background(), sync, []byte("kubeconfig"))
+	requireRetryAfter(t, err, time.Minute, 73*time.Second)
+	assert.Equal(t, 2, fake.requests, "the second target is not attempted")
+
+	targets := provider.Targets(sync)
+	require.Len(t, targets, 2)
+	assert.NotEmpty(t, targets[0].Error)
+	assert.Equal(t, klum.TargetStatus{Owner: "jadolg", Repository: "api"}, targets[1])
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRateLimitByInstallationAndResource(t *testing.T) {
+	limiter := newRateLimiter()
+	reset := time.Now().Add(time.Hour)
+	sent := 0
+	base := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		sent++
+		header := http.Header{}
+		header.Set("X-RateLimit-Remaining", "4999")
+		if req.URL.Path == "/search/repositories" {
+			header.Set("X-RateLimit-Resource", "search")
+			header.Set("X-RateLimit-Remaining", "0")
+			header.Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: header, Body: http.NoBody, Request: req}, nil
+	})
+	get := func(transport http.RoundTripper, path string) error {
+		req, err := http.NewRequest(http.MethodGet, "https://api.github.com"+path, nil)
+		require.NoError(t, err)
+		resp, err := transport.RoundTrip(req)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		return err
+	}
+
+	first := limiter.transport(base, installationLabel(1))
+	second := limiter.transport(base, installationLabel(2))
+	require.NoError(t, get(first, "/search/repositories"), "the last request of the window succeeds")
+
+	var limited *rateLimitedError
+	require.ErrorAs(t, get(first, "/search/repositories"), &limited)
+	assert.Equal(t, limitKey{installation: "1", resource: "search"}, limited.key)
+	assert.Equal(t, 1, sent)
+
+	assert.NoError(t, get(first, "/repos/jadolg/klum"), "other resources of the installation are not blocked")
+	assert.NoError(t, get(second, "/search/repositories"), "other installations are not blocked")
+	assert.Equal(t, 3, sent)
+}
@@ -7,9 +7,11 @@ import (
 	"github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
 )
 
-func createRepositorySecret(ctx context.Context, client *github.Client, syncSpec *v1alpha1.GithubSyncSpec, secretValue []byte) error {
-	var key *github.PublicKey
-	key, _, err := client.Actions.GetRepoPublicKey(ctx, syncSpec.Owner, syncSpec.Repository)
+func createRepositorySecret(ctx context.Context, client *cachedClient, syncSpec *v1alpha1.GithubSyncSpec, secretValue []byte) error {
+	secrets := secretsAPIFor(client.Client, syncSpec.Kind)
+	key, err := client.publicKey(syncSpec.Owner, syncSpec.Repository, kind(syncSpec), func() (*github.PublicKey, *github.Response, error) {
+		return secrets.GetRepoPublicKey(ctx, syncSpec.Owner, syncSpec.Repository)
+	})
 	if err != nil {
 		return err
 	}
@@ -21,14 +23,20 @@ func createRepositorySecret(ctx context.Context, client *github.Client, syncSpec
 
 	secret := &github.EncryptedSecret{
 		Name:           syncSpec.SecretName,
+		KeyID:          key.GetKeyID(),
 		EncryptedValue: encryptedSecret,
 	}
 
-	_, err = client.Actions.CreateOrUpdateRepoSecret(ctx, syncSpec.Owner, syncSpec.Repository, secret)
+	_, err = secrets.CreateOrUpdateRepoSecret(ctx, syncSpec.Owner, syncSpec.Repository, secret)
 	return err
 }
 
-func deleteRepositorySecret(ctx context.Context, client *github.Client, syncSpec *v1alpha1.GithubSyncSpec) error {
-	_, err := client.Actions.DeleteRepoSecret(ctx, syncSpec.Owner, syncSpec.Repository, syncSpec.SecretName)
+func deleteRepositorySecret(ctx context.Context, client *cachedClient, syncSpec *v1alpha1.GithubSyncSpec) error {
+	_, err := secretsAPIFor(client.Client, syncSpec.Kind).DeleteRepoSecret(ctx, syncSpec.Owner, syncSpec.Repository, syncSpec.SecretName)
 	return err
 }
+
+func getRepositorySecret(ctx context.Context, client *cachedClient, syncSpec *v1alpha1.GithubSyncSpec) (*github.Secret, error) {
+	secret, _, err := secretsAPIFor(client.Client, syncSpec.Kind).GetRepoSecret(ctx, syncSpec.Owner, syncSpec.Repository, syncSpec.SecretName)
+	return secret, err
+}
@@ -0,0 +1,70 @@
+package github
+
+import (
+	"context"
+
+	"github.com/google/go-github/v63/github"
+	"github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+)
+
+// secretsAPI are the repository and organization secret calls shared by the Actions, Dependabot
+// and Codespaces APIs. Every API encrypts its secrets with its own public key.
+type secretsAPI interface {
+	GetRepoPublicKey(ctx context.Context, owner, repo string) (*github.PublicKey, *github.Response, error)
+	CreateOrUpdateRepoSecret(ctx context.Context, owner, repo string, eSecret *github.EncryptedSecret) (*github.Response, error)
+	DeleteRepoSecret(ctx context.Context, owner, repo, name string) (*github.Response, error)
+	GetRepoSecret(ctx context.Context, owner, repo, name string) (*github.Secret, *github.Response, error)
+
+	GetOrgPublicKey(ctx context.Context, org string) (*github.PublicKey, *github.Response, error)
+	CreateOrUpdateOrgSecret(ctx context.Context, org string, eSecret *github.EncryptedSecret) (*github.Response, error)
+	SetSelectedReposForOrgSecret(ctx context.Context, org, name string, ids github.SelectedRepoIDs) (*github.Response, error)
+	DeleteOrgSecret(ctx context.Context, org, name string) (*github.Response, error)
+	GetOrgSecret(ctx context.Context, org, name string) (*github.Secret, *github.Response, error)
+}
+
+// secretsAPIFor returns the API managing the secrets of kind
+func secretsAPIFor(client *github.Client, kind string) secretsAPI {
+	switch kind {
+	case v1alpha1.GithubKindDependabot:
+		return dependabotSecrets{client.Dependabot}
+	case v1alpha1.GithubKindCodespaces:
+		return client.Codespaces
+	default:
+		return client.Actions
+	}
+}
+
+// dependabotSecrets adapts the Dependabot API, which has its own types for secrets and repository IDs
+type dependabotSecrets struct {
+	*github.DependabotService
+}
+
+func (d dependabotSecrets) CreateOrUpdateRepoSecret(ctx context.Context, owner, repo string, eSecret *github.EncryptedSecret) (*github.Response, error) {
+	return d.DependabotService.CreateOrUpdateRepoSecret(ctx, owner, repo, dependabotSecret(eSecret))
+}
+
+func (d dependabotSecrets) CreateOrUpdateOrgSecret(ctx context.Context, org string, eSecret *github.EncryptedSecret) (*github.Response, error) {
+	return d.DependabotService.CreateOrUpdateOrgSecret(ctx, org, dependabotSecret(eSecret))
+}
+
+func (d dependabotSecrets) SetSelectedReposForOrgSecret(ctx context.Context, org, name string, ids github.SelectedRepoIDs) (*github.Response, error) {
+	return d.DependabotService.SetSelectedReposForOrgSecret(ctx, org, name, github.DependabotSecretsSelectedRepoIDs(ids))
+}
+
+func dependabotSecret(eSecret *github.EncryptedSecret) *github.DependabotEncryptedSecret {
+	return &github.DependabotEncryptedSecret{
+		Name:                  eSecret.Name,
+		KeyID:                 eSecret.KeyID,
+		EncryptedValue:        eSecret.EncryptedValue,
+		Visibility:            eSecret.Visibility,
+		SelectedRepositoryIDs: github.DependabotSecretsSelectedRepoIDs(eSecret.SelectedRepositoryIDs),
+	}
+}
+
+// kind returns the kind of the secrets of syncSpec
+func kind(syncSpec *v1alpha1.GithubSyncSpec) string {
+	if syncSpec.Kind == "" {
+		return v1alpha1.GithubKindActions
+	}
+	return syncSpec.Kind
+}
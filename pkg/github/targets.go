@@ -0,0 +1,140 @@
+package github
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/google/go-github/v63/github"
+	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+)
+
+// resolveTargets returns a spec with a single repository, environment or organization for every
+// target of spec
+func (p *Provider) resolveTargets(ctx context.Context, spec *klum.GithubSyncSpec) ([]klum.GithubSyncSpec, error) {
+	if !spec.FanOut() {
+		return []klum.GithubSyncSpec{*spec}, nil
+	}
+
+	var targets []klum.GithubSyncSpec
+	for _, target := range spec.Targets {
+		targets = append(targets, targetSpec(spec, klum.TargetStatus{
+			Owner:       target.Owner,
+			Repository:  target.Repository,
+			Environment: target.Environment,
+		}))
+	}
+	if spec.RepositorySelector == nil {
+		return targets, nil
+	}
+
+	repositories, err := p.selectRepositories(ctx, spec.Owner, spec.RepositorySelector)
+	if err != nil {
+		return nil, err
+	}
+	for _, repository := range repositories {
+		targets = append(targets, targetSpec(spec, klum.TargetStatus{Repository: repository}))
+	}
+	return targets, nil
+}
+
+// selectRepositories returns the names of the repositories of owner matching selector. Listing every
+// repository of owner is expensive, the result is cached for selectionTTL.
+func (p *Provider) selectRepositories(ctx context.Context, owner string, selector *klum.GithubRepositorySelector) ([]string, error) {
+	key := strings.Join([]string{owner, selector.Topic, selector.NamePattern}, "/")
+	if names, ok := p.clients.selections.get(key); ok {
+		return names, nil
+	}
+
+	client, err := p.clients.client(ctx, owner, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	page := 1
+	for page != 0 {
+		repositories, response, err := client.Repositories.ListByOrg(ctx, owner, &github.RepositoryListByOrgOptions{
+			ListOptions: github.ListOptions{Page: page, PerPage: 100},
+		})
+		if isNotFound(err) {
+			// owner is a user
+			repositories, response, err = client.Repositories.ListByUser(ctx, owner, &github.RepositoryListByUserOptions{
+				ListOptions: github.ListOptions{Page: page, PerPage: 100},
+			})
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, repository := range repositories {
+			if matches(repository, selector) {
+				names = append(names, repository.GetName())
+			}
+		}
+		page = response.NextPage
+	}
+	p.clients.selections.set(key, names)
+	return names, nil
+}
+
+func matches(repository *github.Repository, selector *klum.GithubRepositorySelector) bool {
+	if repository.GetArchived() {
+		return false
+	}
+	if selector.NamePattern != "" {
+		if matched, _ := path.Match(selector.NamePattern, repository.GetName()); !matched {
+			return false
+		}
+	}
+	if selector.Topic == "" {
+		return true
+	}
+	for _, topic := range repository.Topics {
+		if topic == selector.Topic {
+			return true
+		}
+	}
+	return false
+}
+
+// targetSpec returns spec with the repository and environment of target only
+func targetSpec(spec *klum.GithubSyncSpec, target klum.TargetStatus) klum.GithubSyncSpec {
+	single := *spec
+	single.Targets = nil
+	single.RepositorySelector = nil
+	single.Repository = target.Repository
+	if target.Owner != "" {
+		single.Owner = target.Owner
+	}
+	if target.Environment != "" {
+		single.Environment = target.Environment
+	}
+	return single
+}
+
+func targetStatus(spec *klum.GithubSyncSpec) klum.TargetStatus {
+	return klum.TargetStatus{
+		Owner:       spec.Owner,
+		Repository:  spec.Repository,
+		Environment: spec.Environment,
+	}
+}
+
+func targetKey(target klum.TargetStatus) string {
+	return strings.Join([]string{target.Owner, target.Repository, target.Environment}, "/")
+}
+
+// targetHash is the hash of payload and of the settings of the secret, so the secret is uploaded
+// again when either changes
+func targetHash(spec *klum.GithubSyncSpec, payload []byte) string {
+	fields := []interface{}{spec.SecretName, spec.Kind, spec.Visibility, spec.SelectedRepositories}
+	if spec.EnvironmentProtection != nil && spec.EnvironmentProtection.Enforce {
+		// Only enforced rules are applied again, leaving the hash of other secrets unchanged
+		fields = append(fields, spec.EnvironmentProtection)
+	}
+	settings, _ := json.Marshal(fields)
+	return fmt.Sprintf("%x", sha256.Sum256(append(settings, payload...)))
+}
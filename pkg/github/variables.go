@@ -0,0 +1,39 @@
+package github
+
+import (
+	"context"
+
+	"github.com/google/go-github/v63/github"
+	"github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+)
+
+// setVariable creates or updates the variable in the repository or environment of syncSpec
+func setVariable(ctx context.Context, client *cachedClient, syncSpec *v1alpha1.GithubSyncSpec, name string, value []byte) error {
+	variable := &github.ActionsVariable{Name: name, Value: string(value)}
+
+	var err error
+	if syncSpec.Environment == "" {
+		_, err = client.Actions.UpdateRepoVariable(ctx, syncSpec.Owner, syncSpec.Repository, variable)
+		if isNotFound(err) {
+			_, err = client.Actions.CreateRepoVariable(ctx, syncSpec.Owner, syncSpec.Repository, variable)
+		}
+		return err
+	}
+
+	_, err = client.Actions.UpdateEnvVariable(ctx, syncSpec.Owner, syncSpec.Repository, syncSpec.Environment, variable)
+	if isNotFound(err) {
+		_, err = client.Actions.CreateEnvVariable(ctx, syncSpec.Owner, syncSpec.Repository, syncSpec.Environment, variable)
+	}
+	return err
+}
+
+// deleteVariable removes the variable from the repository or environment of syncSpec
+func deleteVariable(ctx context.Context, client *cachedClient, syncSpec *v1alpha1.GithubSyncSpec, name string) error {
+	var err error
+	if syncSpec.Environment == "" {
+		_, err = client.Actions.DeleteRepoVariable(ctx, syncSpec.Owner, syncSpec.Repository, name)
+	} else {
+		_, err = client.Actions.DeleteEnvVariable(ctx, syncSpec.Owner, syncSpec.Repository, syncSpec.Environment, name)
+	}
+	return err
+}
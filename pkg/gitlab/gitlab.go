@@ -0,0 +1,87 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jadolg/klum/pkg/apiclient"
+)
+
+const defaultBaseURL = "https://gitlab.com"
+
+type Config struct {
+	BaseURL string
+	Token   string
+}
+
+func (c *Config) Enabled() bool {
+	return c.Token != ""
+}
+
+// variable is a CI/CD variable as exposed by the GitLab REST API
+type variable struct {
+	Key              string `json:"key"`
+	Value            string `json:"value"`
+	VariableType     string `json:"variable_type,omitempty"`
+	Protected        bool   `json:"protected"`
+	Masked           bool   `json:"masked"`
+	EnvironmentScope string `json:"environment_scope,omitempty"`
+}
+
+type client struct {
+	*apiclient.Client
+}
+
+func newGitlabClient(cfg Config) (*client, error) {
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("insufficient information provided. Gitlab client can't be created")
+	}
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	if _, err := url.Parse(baseURL); err != nil {
+		return nil, err
+	}
+	return &client{apiclient.NewClient("gitlab", strings.TrimSuffix(baseURL, "/")+"/api/v4", func(req *http.Request) {
+		req.Header.Set("PRIVATE-TOKEN", cfg.Token)
+	})}, nil
+}
+
+// variablesPath returns the path of the variables of a project or group.
+// IDs and full paths are both accepted, full paths are URL encoded as required by the API.
+func variablesPath(owner, id string) string {
+	return fmt.Sprintf("/%s/%s/variables", owner, url.PathEscape(id))
+}
+
+func variablePath(owner, id, key, environmentScope string) string {
+	path := variablesPath(owner, id) + "/" + url.PathEscape(key)
+	if environmentScope != "" {
+		path += "?" + url.Values{"filter[environment_scope]": {environmentScope}}.Encode()
+	}
+	return path
+}
+
+// createOrUpdateVariable updates the variable and creates it if it doesn't exist yet
+func (c *client) createOrUpdateVariable(ctx context.Context, owner, id string, v *variable) error {
+	err := c.Do(ctx, http.MethodPut, variablePath(owner, id, v.Key, v.EnvironmentScope), v, nil)
+	if apiclient.IsNotFound(err) {
+		return c.Do(ctx, http.MethodPost, variablesPath(owner, id), v, nil)
+	}
+	return err
+}
+
+func (c *client) deleteVariable(ctx context.Context, owner, id, key, environmentScope string) error {
+	return c.Do(ctx, http.MethodDelete, variablePath(owner, id, key, environmentScope), nil, nil)
+}
+
+func (c *client) getVariable(ctx context.Context, owner, id, key, environmentScope string) (*variable, error) {
+	v := &variable{}
+	if err := c.Do(ctx, http.MethodGet, variablePath(owner, id, key, environmentScope), nil, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
@@ -0,0 +1,152 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/jadolg/klum/pkg/apiclient"
+	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	"github.com/jadolg/klum/pkg/usersync"
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultEnvironmentScope = "*"
+
+// Provider synchronizes kubeconfigs to GitLab CI/CD variables
+type Provider struct {
+	cfg Config
+}
+
+func NewProvider(cfg Config) *Provider {
+	return &Provider{cfg: cfg}
+}
+
+func (p *Provider) Name() string {
+	return "gitlab"
+}
+
+func (p *Provider) Enabled() bool {
+	return p.cfg.Enabled()
+}
+
+func (p *Provider) Upload(ctx context.Context, sync usersync.Object, payload []byte) error {
+	userSync, err := asUserSyncGitlab(sync)
+	if err != nil {
+		return err
+	}
+	gitlabSync := userSync.Spec.Gitlab
+	if err := gitlabSync.Validate(); err != nil {
+		return err
+	}
+
+	owner, id := target(&gitlabSync)
+	log.WithFields(log.Fields{
+		"variable":    gitlabSync.VariableName,
+		"user":        userSync.Spec.User,
+		"project":     gitlabSync.Project,
+		"group":       gitlabSync.Group,
+		"environment": environmentScope(&gitlabSync),
+	}).Info("Adding variable")
+
+	client, err := newGitlabClient(p.cfg)
+	if err != nil {
+		return err
+	}
+
+	value := string(payload)
+	if gitlabSync.Masked {
+		value = base64.StdEncoding.EncodeToString(payload)
+	}
+
+	variableType := gitlabSync.VariableType
+	if variableType == "" {
+		variableType = klum.GitlabVariableTypeEnvVar
+	}
+
+	return client.createOrUpdateVariable(ctx, owner, id, &variable{
+		Key:              gitlabSync.VariableName,
+		Value:            value,
+		VariableType:     variableType,
+		Protected:        gitlabSync.Protected,
+		Masked:           gitlabSync.Masked,
+		EnvironmentScope: environmentScope(&gitlabSync),
+	})
+}
+
+func (p *Provider) Delete(ctx context.Context, sync usersync.Object) error {
+	userSync, err := asUserSyncGitlab(sync)
+	if err != nil {
+		return err
+	}
+	gitlabSync := userSync.Spec.Gitlab
+	if err := gitlabSync.Validate(); err != nil {
+		return err
+	}
+
+	owner, id := target(&gitlabSync)
+	log.WithFields(log.Fields{
+		"variable":    gitlabSync.VariableName,
+		"user":        userSync.Spec.User,
+		"project":     gitlabSync.Project,
+		"group":       gitlabSync.Group,
+		"environment": environmentScope(&gitlabSync),
+	}).Info("Deleting variable")
+
+	client, err := newGitlabClient(p.cfg)
+	if err != nil {
+		return err
+	}
+
+	err = client.deleteVariable(ctx, owner, id, gitlabSync.VariableName, environmentScope(&gitlabSync))
+	if apiclient.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func (p *Provider) Verify(ctx context.Context, sync usersync.Object) (bool, error) {
+	userSync, err := asUserSyncGitlab(sync)
+	if err != nil {
+		return false, err
+	}
+	gitlabSync := userSync.Spec.Gitlab
+	if err := gitlabSync.Validate(); err != nil {
+		return false, err
+	}
+
+	client, err := newGitlabClient(p.cfg)
+	if err != nil {
+		return false, err
+	}
+
+	owner, id := target(&gitlabSync)
+	_, err = client.getVariable(ctx, owner, id, gitlabSync.VariableName, environmentScope(&gitlabSync))
+	if apiclient.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// target returns the kind of owner (projects or groups) and its ID as used in the API paths
+func target(spec *klum.GitlabSyncSpec) (string, string) {
+	if spec.Group != "" {
+		return "groups", spec.Group
+	}
+	return "projects", spec.Project
+}
+
+func environmentScope(spec *klum.GitlabSyncSpec) string {
+	if spec.EnvironmentScope == "" {
+		return defaultEnvironmentScope
+	}
+	return spec.EnvironmentScope
+}
+
+func asUserSyncGitlab(sync usersync.Object) (*klum.UserSyncGitlab, error) {
+	userSync, ok := sync.(*klum.UserSyncGitlab)
+	if !ok {
+		return nil, fmt.Errorf("gitlab provider can't synchronize %T", sync)
+	}
+	return userSync, nil
+}
@@ -0,0 +1,167 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type request struct {
+	method   string
+	path     string
+	query    string
+	variable variable
+}
+
+// fakeGitlab records the requests it receives and answers 404 for variables it doesn't know
+type fakeGitlab struct {
+	lock      sync.Mutex
+	requests  []request
+	variables map[string]variable
+}
+
+func (f *fakeGitlab) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if r.Header.Get("PRIVATE-TOKEN") != "token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	req := request{method: r.Method, path: r.URL.EscapedPath(), query: r.URL.Query().Get("filter[environment_scope]")}
+	if r.Body != nil {
+		_ = json.NewDecoder(r.Body).Decode(&req.variable)
+	}
+	f.requests = append(f.requests, req)
+
+	switch r.Method {
+	case http.MethodPost:
+		f.variables[req.variable.Key] = req.variable
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodPut, http.MethodGet, http.MethodDelete:
+		v, found := f.variables["KUBECONFIG"]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodPut {
+			f.variables[v.Key] = req.variable
+		}
+		if r.Method == http.MethodDelete {
+			delete(f.variables, v.Key)
+		}
+		_ = json.NewEncoder(w).Encode(v)
+	}
+}
+
+func newTestProvider(t *testing.T) (*Provider, *fakeGitlab) {
+	fake := &fakeGitlab{variables: map[string]variable{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return NewProvider(Config{BaseURL: server.URL, Token: "token"}), fake
+}
+
+func newTestSync(spec klum.GitlabSyncSpec) *klum.UserSyncGitlab {
+	return &klum.UserSyncGitlab{
+		ObjectMeta: metav1.ObjectMeta{Name: "darren"},
+		Spec:       klum.UserSyncGitlabSpec{User: "darren", Gitlab: spec},
+	}
+}
+
+func TestUpload_ProjectVariable(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	sync := newTestSync(klum.GitlabSyncSpec{
+		Project:      "group/project",
+		VariableName: "KUBECONFIG",
+		VariableType: klum.GitlabVariableTypeFile,
+		Protected:    true,
+	})
+
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	require.Len(t, fake.requests, 2)
+	assert.Equal(t, http.MethodPut, fake.requests[0].method)
+	assert.Equal(t, "/api/v4/projects/group%2Fproject/variables/KUBECONFIG", fake.requests[0].path)
+	assert.Equal(t, "*", fake.requests[0].query)
+	assert.Equal(t, http.MethodPost, fake.requests[1].method)
+	assert.Equal(t, "/api/v4/projects/group%2Fproject/variables", fake.requests[1].path)
+	assert.Equal(t, variable{
+		Key:              "KUBECONFIG",
+		Value:            "kubeconfig",
+		VariableType:     klum.GitlabVariableTypeFile,
+		Protected:        true,
+		EnvironmentScope: "*",
+	}, fake.requests[1].variable)
+
+	// Existing variables are updated in place
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("rotated")))
+	require.Len(t, fake.requests, 3)
+	assert.Equal(t, http.MethodPut, fake.requests[2].method)
+	assert.Equal(t, "rotated", fake.variables["KUBECONFIG"].Value)
+}
+
+func TestUpload_MaskedGroupVariable(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	sync := newTestSync(klum.GitlabSyncSpec{
+		Group:            "42",
+		VariableName:     "KUBECONFIG",
+		EnvironmentScope: "production",
+		Masked:           true,
+	})
+
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	v := fake.variables["KUBECONFIG"]
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("kubeconfig")), v.Value)
+	assert.Equal(t, klum.GitlabVariableTypeEnvVar, v.VariableType)
+	assert.Equal(t, "production", v.EnvironmentScope)
+	assert.True(t, v.Masked)
+	assert.Equal(t, "/api/v4/groups/42/variables", fake.requests[1].path)
+}
+
+func TestDeleteAndVerify(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	sync := newTestSync(klum.GitlabSyncSpec{Project: "1", VariableName: "KUBECONFIG"})
+
+	exists, err := provider.Verify(context.Background(), sync)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	exists, err = provider.Verify(context.Background(), sync)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	require.NoError(t, provider.Delete(context.Background(), sync))
+	assert.Empty(t, fake.variables)
+
+	// Deleting a variable that is already gone is not an error
+	require.NoError(t, provider.Delete(context.Background(), sync))
+}
+
+func TestUpload_InvalidSpec(t *testing.T) {
+	provider, fake := newTestProvider(t)
+
+	err := provider.Upload(context.Background(), newTestSync(klum.GitlabSyncSpec{Project: "1", Group: "2", VariableName: "KUBECONFIG"}), []byte("kubeconfig"))
+	assert.Error(t, err)
+	err = provider.Upload(context.Background(), newTestSync(klum.GitlabSyncSpec{Project: "1", VariableName: "KUBECONFIG", VariableType: "secret"}), []byte("kubeconfig"))
+	assert.Error(t, err)
+	assert.Empty(t, fake.requests)
+}
+
+func TestUpload_Unauthorized(t *testing.T) {
+	provider, _ := newTestProvider(t)
+	provider.cfg.Token = "wrong"
+
+	err := provider.Upload(context.Background(), newTestSync(klum.GitlabSyncSpec{Project: "1", VariableName: "KUBECONFIG"}), []byte("kubeconfig"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "401")
+}
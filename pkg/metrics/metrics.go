@@ -15,6 +15,26 @@ var (
 		Name: "klum_errors_total",
 		Help: "The total number of errors found",
 	})
+	CredentialRevocationsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "klum_credential_revocations_total",
+		Help: "The total number of user credentials revoked on demand",
+	})
+	DriftDetectedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "klum_drift_detected_total",
+		Help: "The total number of synchronized kubeconfigs found deleted or changed outside of klum",
+	}, []string{"provider"})
+	GithubRateLimitRemaining = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "klum_github_rate_limit_remaining",
+		Help: "The number of GitHub API requests remaining in the current rate limit window",
+	}, []string{"installation", "resource"})
+	GithubRateLimitedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "klum_github_rate_limited_total",
+		Help: "The total number of GitHub API requests rejected by a primary or secondary rate limit",
+	}, []string{"limit"})
+	GithubRequestsDeferredTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "klum_github_requests_deferred_total",
+		Help: "The total number of GitHub API requests not sent because a rate limit is exceeded",
+	})
 )
 
 func StartMetricsServer(port int) {
@@ -0,0 +1,238 @@
+package remotesecret
+
+import (
+	"context"
+	"fmt"
+
+	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	"github.com/jadolg/klum/pkg/render"
+	"github.com/jadolg/klum/pkg/usersync"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Provider writes kubeconfigs to Secrets in other clusters
+type Provider struct {
+	namespace string
+	// source identifies this cluster in the SourceLabel of remote Secrets
+	source  string
+	secrets render.SecretGetter
+	// newClient is newRemoteClient, replaced in tests
+	newClient func(kubeconfig []byte) (kubernetes.Interface, error)
+}
+
+// NewProvider returns a provider reading the kubeconfigs of remote clusters with secrets from
+// namespace. source identifies this cluster, it must be
+// unique among the clusters writing to the same remote cluster, e.g. the UID of kube-system.
+func NewProvider(namespace, source string, secrets render.SecretGetter) *Provider {
+	return &Provider{
+		namespace: namespace,
+		source:    source,
+		secrets:   secrets,
+		newClient: newRemoteClient,
+	}
+}
+
+func (p *Provider) Name() string {
+	return "remotesecret"
+}
+
+// Enabled is always true, the remote clusters are configured in the sync objects
+func (p *Provider) Enabled() bool {
+	return true
+}
+
+// RemoveDisabledUsers deletes the remote Secret while the user is disabled so consumers
+// don't keep trying a revoked token
+func (p *Provider) RemoveDisabledUsers() bool {
+	return true
+}
+
+func (p *Provider) Reachable(ctx context.Context, sync usersync.Object) error {
+	userSync, err := asUserSyncRemoteSecret(sync)
+	if err != nil {
+		return err
+	}
+	client, err := p.client(&userSync.Spec.RemoteSecret)
+	if err != nil {
+		return err
+	}
+	_, err = client.Discovery().ServerVersion()
+	return err
+}
+
+func (p *Provider) Upload(ctx context.Context, sync usersync.Object, payload []byte) error {
+	userSync, err := asUserSyncRemoteSecret(sync)
+	if err != nil {
+		return err
+	}
+	spec := userSync.Spec.RemoteSecret
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"user":      userSync.Spec.User,
+		"namespace": spec.Namespace,
+		"secret":    spec.Name,
+	}).Info("Writing remote secret")
+
+	client, err := p.client(&spec)
+	if err != nil {
+		return err
+	}
+	desired, err := remoteSecret(userSync, p.source, payload)
+	if err != nil {
+		return err
+	}
+
+	secrets := client.CoreV1().Secrets(spec.Namespace)
+	existing, err := secrets.Get(ctx, spec.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = secrets.Create(ctx, desired, metav1.CreateOptions{})
+	} else if err == nil {
+		if !isOwner(existing, userSync, p.source) {
+			return fmt.Errorf("secret %s/%s already exists in the remote cluster and is not managed by this sync", spec.Namespace, spec.Name)
+		}
+		existing.Labels = desired.Labels
+		existing.Data = desired.Data
+		existing.Type = desired.Type
+		_, err = secrets.Update(ctx, existing, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return err
+	}
+
+	p.deleteOrphans(ctx, client, userSync, &spec)
+	return nil
+}
+
+func (p *Provider) Delete(ctx context.Context, sync usersync.Object) error {
+	userSync, err := asUserSyncRemoteSecret(sync)
+	if err != nil {
+		return err
+	}
+	spec := userSync.Spec.RemoteSecret
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"user":      userSync.Spec.User,
+		"namespace": spec.Namespace,
+		"secret":    spec.Name,
+	}).Info("Deleting remote secret")
+
+	client, err := p.client(&spec)
+	if err != nil {
+		return err
+	}
+
+	secrets := client.CoreV1().Secrets(spec.Namespace)
+	existing, err := secrets.Get(ctx, spec.Name, metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if err == nil && isOwner(existing, userSync, p.source) {
+		err = secrets.Delete(ctx, spec.Name, metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	// Also deletes copies left behind by previous namespaces or names of the sync
+	p.deleteOrphans(ctx, client, userSync, nil)
+	return nil
+}
+
+func (p *Provider) Verify(ctx context.Context, sync usersync.Object) (bool, error) {
+	userSync, err := asUserSyncRemoteSecret(sync)
+	if err != nil {
+		return false, err
+	}
+	spec := userSync.Spec.RemoteSecret
+	if err := spec.Validate(); err != nil {
+		return false, err
+	}
+
+	client, err := p.client(&spec)
+	if err != nil {
+		return false, err
+	}
+	existing, err := client.CoreV1().Secrets(spec.Namespace).Get(ctx, spec.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return isOwner(existing, userSync, p.source), nil
+}
+
+// deleteOrphans deletes the copies of userSync other than current, left behind when the namespace
+// or name of the sync changed. It needs to list Secrets in every namespace of the remote cluster,
+// so failures are only logged.
+func (p *Provider) deleteOrphans(ctx context.Context, client kubernetes.Interface, userSync *klum.UserSyncRemoteSecret, current *klum.RemoteSecretSyncSpec) {
+	logger := log.WithFields(log.Fields{
+		"user":     userSync.Spec.User,
+		"usersync": userSync.Name,
+	})
+
+	list, err := client.CoreV1().Secrets(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		LabelSelector: ownerSelector(userSync, p.source),
+	})
+	if err != nil {
+		logger.WithError(err).Warning("Failed to look for orphaned remote secrets")
+		return
+	}
+
+	for _, secret := range list.Items {
+		if current != nil && secret.Namespace == current.Namespace && secret.Name == current.Name {
+			continue
+		}
+		logger.WithFields(log.Fields{
+			"namespace": secret.Namespace,
+			"secret":    secret.Name,
+		}).Info("Deleting orphaned remote secret")
+		err := client.CoreV1().Secrets(secret.Namespace).Delete(ctx, secret.Name, metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			logger.WithError(err).Warning("Failed to delete orphaned remote secret")
+		}
+	}
+}
+
+// client returns a client for the remote cluster of spec. The kubeconfig Secret is only read from
+// the klum namespace, so a sync can't use the credentials of another namespace.
+func (p *Provider) client(spec *klum.RemoteSecretSyncSpec) (kubernetes.Interface, error) {
+	ref := spec.KubeconfigSecretRef
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = p.namespace
+	}
+	if namespace != p.namespace {
+		return nil, fmt.Errorf("kubeconfigSecretRef must be in the %s namespace, not %s", p.namespace, namespace)
+	}
+	key := ref.Key
+	if key == "" {
+		key = defaultKubeconfigKey
+	}
+
+	secret, err := p.secrets.Get(namespace, ref.Name)
+	if err != nil {
+		return nil, err
+	}
+	kubeconfig, ok := secret.Data[key]
+	if !ok || len(kubeconfig) == 0 {
+		return nil, fmt.Errorf("secret %s/%s has no %s key", namespace, ref.Name, key)
+	}
+	return p.newClient(kubeconfig)
+}
+
+func asUserSyncRemoteSecret(sync usersync.Object) (*klum.UserSyncRemoteSecret, error) {
+	userSync, ok := sync.(*klum.UserSyncRemoteSecret)
+	if !ok {
+		return nil, fmt.Errorf("remotesecret provider can't synchronize %T", sync)
+	}
+	return userSync, nil
+}
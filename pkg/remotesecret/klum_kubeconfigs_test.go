@@ -0,0 +1,238 @@
+package remotesecret
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/yaml"
+)
+
+type fakeSecrets map[string]*v1.Secret
+
+func (f fakeSecrets) Get(namespace, name string) (*v1.Secret, error) {
+	if secret, ok := f[namespace+"/"+name]; ok {
+		return secret, nil
+	}
+	return nil, errors.NewNotFound(schema.GroupResource{Resource: "secrets"}, name)
+}
+
+func newTestProvider(t *testing.T, objects ...runtime.Object) (*Provider, *fake.Clientset) {
+	remote := fake.NewClientset(objects...)
+	provider := NewProvider("klum", "production", fakeSecrets{
+		"klum/argocd": {Data: map[string][]byte{"kubeconfig": []byte("remote kubeconfig")}},
+	})
+	provider.newClient = func(kubeconfig []byte) (kubernetes.Interface, error) {
+		require.Equal(t, "remote kubeconfig", string(kubeconfig))
+		return remote, nil
+	}
+	return provider, remote
+}
+
+func newTestSync(spec klum.RemoteSecretSyncSpec) *klum.UserSyncRemoteSecret {
+	spec.KubeconfigSecretRef = klum.SecretKeyReference{Name: "argocd"}
+	return &klum.UserSyncRemoteSecret{
+		ObjectMeta: metav1.ObjectMeta{Name: "darren"},
+		Spec:       klum.UserSyncRemoteSecretSpec{User: "darren", RemoteSecret: spec},
+	}
+}
+
+func getSecret(t *testing.T, remote *fake.Clientset, namespace, name string) *v1.Secret {
+	secret, err := remote.CoreV1().Secrets(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	require.NoError(t, err)
+	return secret
+}
+
+func TestUploadVerifyDelete(t *testing.T) {
+	provider, remote := newTestProvider(t)
+	sync := newTestSync(klum.RemoteSecretSyncSpec{
+		Namespace: "ci",
+		Name:      "darren-kubeconfig",
+		Labels:    map[string]string{"team": "platform"},
+	})
+
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	secret := getSecret(t, remote, "ci", "darren-kubeconfig")
+	assert.Equal(t, "kubeconfig", string(secret.Data["kubeconfig"]))
+	assert.Equal(t, map[string]string{
+		"team":                         "platform",
+		"app.kubernetes.io/managed-by": "klum",
+		"klum.cattle.io/user":          "darren",
+		"klum.cattle.io/usersync":      "darren",
+		"klum.cattle.io/source":        "production",
+	}, secret.Labels)
+
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("rotated")))
+	assert.Equal(t, "rotated", string(getSecret(t, remote, "ci", "darren-kubeconfig").Data["kubeconfig"]))
+
+	exists, err := provider.Verify(context.Background(), sync)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	require.NoError(t, provider.Delete(context.Background(), sync))
+	exists, err = provider.Verify(context.Background(), sync)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	require.NoError(t, provider.Delete(context.Background(), sync), "deleting a missing secret succeeds")
+}
+
+func TestOrphans(t *testing.T) {
+	provider, remote := newTestProvider(t)
+	sync := newTestSync(klum.RemoteSecretSyncSpec{Namespace: "ci", Name: "darren-kubeconfig"})
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+
+	other := newTestSync(klum.RemoteSecretSyncSpec{Namespace: "ci", Name: "other-kubeconfig"})
+	other.Name = "other"
+	require.NoError(t, provider.Upload(context.Background(), other, []byte("kubeconfig")))
+
+	sync.Spec.RemoteSecret.Namespace = "deploy"
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+
+	list, err := remote.CoreV1().Secrets(metav1.NamespaceAll).List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	var names []string
+	for _, secret := range list.Items {
+		names = append(names, secret.Namespace+"/"+secret.Name)
+	}
+	assert.ElementsMatch(t, []string{"deploy/darren-kubeconfig", "ci/other-kubeconfig"}, names,
+		"the copy in the previous namespace is deleted")
+}
+
+func TestForeignSecretIsNotTouched(t *testing.T) {
+	foreign := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "darren-kubeconfig"}}
+	provider, remote := newTestProvider(t, foreign)
+	sync := newTestSync(klum.RemoteSecretSyncSpec{Namespace: "ci", Name: "darren-kubeconfig"})
+
+	assert.Error(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	exists, err := provider.Verify(context.Background(), sync)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	require.NoError(t, provider.Delete(context.Background(), sync))
+	assert.Empty(t, getSecret(t, remote, "ci", "darren-kubeconfig").Data, "the secret is left alone")
+}
+
+func TestArgoCD(t *testing.T) {
+	provider, remote := newTestProvider(t)
+	sync := newTestSync(klum.RemoteSecretSyncSpec{
+		Namespace: "argocd",
+		Name:      "production",
+		Format:    klum.RemoteSecretFormatArgoCD,
+	})
+
+	payload, err := yaml.Marshal(klum.KubeconfigSpec{
+		Clusters: []klum.NamedCluster{
+			{Name: "cluster", Cluster: klum.Cluster{Server: "https://production:6443", CertificateAuthorityData: "Y2E="}},
+		},
+		AuthInfos: []klum.NamedAuthInfo{{Name: "darren", AuthInfo: klum.AuthInfo{Token: "token"}}},
+		Contexts: []klum.NamedContext{
+			{Name: "production", Context: klum.Context{Cluster: "cluster", AuthInfo: "darren"}},
+		},
+		CurrentContext: "production",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, provider.Upload(context.Background(), sync, payload))
+	secret := getSecret(t, remote, "argocd", "production")
+	assert.Equal(t, "cluster", secret.Labels["argocd.argoproj.io/secret-type"])
+	assert.Equal(t, "production", string(secret.Data["name"]))
+	assert.Equal(t, "https://production:6443", string(secret.Data["server"]))
+
+	config := argoCDConfig{}
+	require.NoError(t, json.Unmarshal(secret.Data["config"], &config))
+	assert.Equal(t, "token", config.BearerToken)
+	assert.Equal(t, "Y2E=", config.TLSClientConfig.CAData)
+
+	sync.Spec.RemoteSecret.ClusterName = "prod-eu"
+	require.NoError(t, provider.Upload(context.Background(), sync, payload))
+	assert.Equal(t, "prod-eu", string(getSecret(t, remote, "argocd", "production").Data["name"]))
+}
+
+func TestReachable(t *testing.T) {
+	provider, remote := newTestProvider(t)
+	sync := newTestSync(klum.RemoteSecretSyncSpec{Namespace: "ci", Name: "darren-kubeconfig"})
+
+	assert.NoError(t, provider.Reachable(context.Background(), sync))
+
+	remote.PrependReactor("get", "version", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("connection refused")
+	})
+	assert.EqualError(t, provider.Reachable(context.Background(), sync), "connection refused")
+
+	sync.Spec.RemoteSecret.KubeconfigSecretRef.Name = "missing"
+	assert.Error(t, provider.Reachable(context.Background(), sync))
+}
+
+func TestKubeconfigSecretOutsideNamespace(t *testing.T) {
+	provider, remote := newTestProvider(t)
+	sync := newTestSync(klum.RemoteSecretSyncSpec{Namespace: "ci", Name: "darren-kubeconfig"})
+	sync.Spec.RemoteSecret.KubeconfigSecretRef.Namespace = "kube-system"
+
+	err := provider.Upload(context.Background(), sync, []byte("kubeconfig"))
+	assert.ErrorContains(t, err, "must be in the klum namespace")
+	assert.Empty(t, remote.Actions())
+
+	sync.Spec.RemoteSecret.KubeconfigSecretRef.Namespace = "klum"
+	assert.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+}
+
+func TestNewRemoteClient(t *testing.T) {
+	kubeconfigWithCluster := func(cluster, user string) []byte {
+		return []byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: remote
+  cluster:
+    server: https://remote:6443
+` + cluster + `contexts:
+- name: remote
+  context:
+    cluster: remote
+    user: klum
+current-context: remote
+users:
+- name: klum
+  user:
+` + user)
+	}
+	kubeconfig := func(user string) []byte {
+		return kubeconfigWithCluster("", user)
+	}
+
+	_, err := newRemoteClient(kubeconfig("    token: secret-token\n"))
+	assert.NoError(t, err)
+
+	_, err = newRemoteClient(kubeconfig("    exec:\n      apiVersion: client.authentication.k8s.io/v1\n      command: /bin/sh\n"))
+	assert.ErrorContains(t, err, "exec or auth-provider")
+
+	_, err = newRemoteClient(kubeconfig("    auth-provider:\n      name: oidc\n"))
+	assert.ErrorContains(t, err, "exec or auth-provider")
+
+	// Files would be read from the klum container
+	for _, user := range []string{
+		"    tokenFile: /var/run/secrets/kubernetes.io/serviceaccount/token\n",
+		"    client-certificate: /etc/klum/tls.crt\n    client-key-data: a2V5\n",
+		"    client-certificate-data: Y2VydA==\n    client-key: /etc/klum/tls.key\n",
+	} {
+		_, err = newRemoteClient(kubeconfig(user))
+		assert.ErrorContains(t, err, "references a file", user)
+	}
+	_, err = newRemoteClient(kubeconfigWithCluster("    certificate-authority: /var/run/secrets/kubernetes.io/serviceaccount/ca.crt\n", "    token: secret-token\n"))
+	assert.ErrorContains(t, err, "references a file")
+
+	_, err = newRemoteClient(kubeconfig("    username: admin\n    password: secret\n"))
+	assert.ErrorContains(t, err, "basic authentication")
+}
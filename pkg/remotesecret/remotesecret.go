@@ -0,0 +1,167 @@
+package remotesecret
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	"github.com/jadolg/klum/pkg/render"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// ManagedByLabel, UserLabel, SyncLabel and SourceLabel are set on every Secret written to a remote
+	// cluster. SyncLabel and SourceLabel identify the copies of a sync to clean up orphans.
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+	UserLabel      = "klum.cattle.io/user"
+	SyncLabel      = "klum.cattle.io/usersync"
+	SourceLabel    = "klum.cattle.io/source"
+
+	managedBy            = "klum"
+	defaultKubeconfigKey = "kubeconfig"
+	requestTimeout       = 10 * time.Second
+
+	argoCDSecretTypeLabel = "argocd.argoproj.io/secret-type"
+)
+
+// newRemoteClient returns a client for the cluster of kubeconfig. Only inline credentials are
+// accepted: exec and auth-provider plugins would run inside klum, and file paths would make klum
+// read its own files, like its ServiceAccount token, and send them to the remote server.
+func newRemoteClient(kubeconfig []byte) (kubernetes.Interface, error) {
+	apiConfig, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote kubeconfig: %w", err)
+	}
+	for name, authInfo := range apiConfig.AuthInfos {
+		if authInfo.Exec != nil || authInfo.AuthProvider != nil {
+			return nil, fmt.Errorf("invalid remote kubeconfig: user %s uses an exec or auth-provider plugin, only tokens and client certificates are supported", name)
+		}
+		if authInfo.TokenFile != "" || authInfo.ClientCertificate != "" || authInfo.ClientKey != "" {
+			return nil, fmt.Errorf("invalid remote kubeconfig: user %s references a file, use token, client-certificate-data and client-key-data", name)
+		}
+		if authInfo.Username != "" || authInfo.Password != "" {
+			return nil, fmt.Errorf("invalid remote kubeconfig: user %s uses basic authentication, only tokens and client certificates are supported", name)
+		}
+	}
+	for name, cluster := range apiConfig.Clusters {
+		if cluster.CertificateAuthority != "" {
+			return nil, fmt.Errorf("invalid remote kubeconfig: cluster %s references a file, use certificate-authority-data", name)
+		}
+	}
+	config, err := clientcmd.NewDefaultClientConfig(*apiConfig, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote kubeconfig: %w", err)
+	}
+	config.Timeout = requestTimeout
+	return kubernetes.NewForConfig(config)
+}
+
+// ownerLabels returns the labels identifying the copies of userSync written by the klum of source
+func ownerLabels(userSync *klum.UserSyncRemoteSecret, source string) map[string]string {
+	return map[string]string{
+		ManagedByLabel: managedBy,
+		UserLabel:      userSync.Spec.User,
+		SyncLabel:      userSync.Name,
+		SourceLabel:    source,
+	}
+}
+
+func ownerSelector(userSync *klum.UserSyncRemoteSecret, source string) string {
+	return labels.SelectorFromSet(map[string]string{
+		SyncLabel:   userSync.Name,
+		SourceLabel: source,
+	}).String()
+}
+
+func isOwner(secret *v1.Secret, userSync *klum.UserSyncRemoteSecret, source string) bool {
+	return secret.Labels[SyncLabel] == userSync.Name && secret.Labels[SourceLabel] == source
+}
+
+// remoteSecret returns the Secret written to the remote cluster for payload
+func remoteSecret(userSync *klum.UserSyncRemoteSecret, source string, payload []byte) (*v1.Secret, error) {
+	spec := userSync.Spec.RemoteSecret
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      spec.Name,
+			Namespace: spec.Namespace,
+			Labels:    map[string]string{},
+		},
+		Type: v1.SecretTypeOpaque,
+	}
+	for key, value := range spec.Labels {
+		secret.Labels[key] = value
+	}
+
+	if spec.Format != klum.RemoteSecretFormatArgoCD {
+		secret.Data = map[string][]byte{defaultKubeconfigKey: payload}
+	} else {
+		data, err := argoCDCluster(&spec, payload)
+		if err != nil {
+			return nil, err
+		}
+		secret.Data = data
+		secret.Labels[argoCDSecretTypeLabel] = "cluster"
+	}
+
+	for key, value := range ownerLabels(userSync, source) {
+		secret.Labels[key] = value
+	}
+	return secret, nil
+}
+
+// argoCDConfig is the config key of an Argo CD cluster Secret
+type argoCDConfig struct {
+	BearerToken     string          `json:"bearerToken,omitempty"`
+	TLSClientConfig argoCDTLSConfig `json:"tlsClientConfig"`
+}
+
+// argoCDTLSConfig holds base64 encoded PEM data, like the kubeconfig
+type argoCDTLSConfig struct {
+	Insecure   bool   `json:"insecure"`
+	ServerName string `json:"serverName,omitempty"`
+	CAData     string `json:"caData,omitempty"`
+	CertData   string `json:"certData,omitempty"`
+	KeyData    string `json:"keyData,omitempty"`
+}
+
+// argoCDCluster returns the data of an Argo CD cluster Secret for the current context of payload
+func argoCDCluster(spec *klum.RemoteSecretSyncSpec, payload []byte) (map[string][]byte, error) {
+	kubeconfig := klum.KubeconfigSpec{}
+	if err := yaml.Unmarshal(payload, &kubeconfig); err != nil {
+		return nil, err
+	}
+	cluster, authInfo, err := render.CurrentCredentials(&kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := json.Marshal(&argoCDConfig{
+		BearerToken: authInfo.Token,
+		TLSClientConfig: argoCDTLSConfig{
+			Insecure:   cluster.InsecureSkipTLSVerify,
+			ServerName: cluster.TLSServerName,
+			CAData:     cluster.CertificateAuthorityData,
+			CertData:   authInfo.ClientCertificateData,
+			KeyData:    authInfo.ClientKeyData,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	name := spec.ClusterName
+	if name == "" {
+		name = kubeconfig.CurrentContext
+	}
+	return map[string][]byte{
+		"name":   []byte(name),
+		"server": []byte(cluster.Server),
+		"config": config,
+	}, nil
+}
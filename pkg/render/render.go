@@ -0,0 +1,111 @@
+package render
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	defaultTokenKey     = "token"
+	defaultClientKeyKey = v1.TLSPrivateKeyKey
+)
+
+// SecretGetter is the subset of the Secret cache needed to resolve token references
+type SecretGetter interface {
+	Get(namespace, name string) (*v1.Secret, error)
+}
+
+// Kubeconfig returns a copy of kubeconfig where every token reference has been replaced
+// by the token it points to, so the result can be handed out as a regular kubeconfig file.
+func Kubeconfig(kubeconfig *klum.Kubeconfig, secrets SecretGetter) (*klum.Kubeconfig, error) {
+	rendered := kubeconfig.DeepCopy()
+	for i := range rendered.Spec.AuthInfos {
+		authInfo := &rendered.Spec.AuthInfos[i].AuthInfo
+		if authInfo.TokenSecretRef != nil {
+			token, err := resolveSecretKey(authInfo.TokenSecretRef, defaultTokenKey, secrets)
+			if err != nil {
+				return nil, err
+			}
+			authInfo.Token = string(token)
+			authInfo.TokenSecretRef = nil
+		}
+
+		if authInfo.ClientKeySecretRef != nil {
+			key, err := resolveSecretKey(authInfo.ClientKeySecretRef, defaultClientKeyKey, secrets)
+			if err != nil {
+				return nil, err
+			}
+			authInfo.ClientKeyData = base64.StdEncoding.EncodeToString(key)
+			authInfo.ClientKeySecretRef = nil
+		}
+	}
+	return rendered, nil
+}
+
+// Validate round-trips spec through clientcmd to make sure it produces a kubeconfig clients can load
+func Validate(spec klum.KubeconfigSpec) error {
+	data, err := yaml.Marshal(spec)
+	if err != nil {
+		return err
+	}
+
+	config, err := clientcmd.Load(data)
+	if err != nil {
+		return fmt.Errorf("invalid kubeconfig: %w", err)
+	}
+
+	if err := clientcmd.Validate(*config); err != nil {
+		return fmt.Errorf("invalid kubeconfig: %w", err)
+	}
+	return nil
+}
+
+// CurrentCredentials returns the cluster and user of the current context
+func CurrentCredentials(kubeconfig *klum.KubeconfigSpec) (*klum.Cluster, *klum.AuthInfo, error) {
+	var clusterName, authInfoName string
+	for _, named := range kubeconfig.Contexts {
+		if named.Name == kubeconfig.CurrentContext {
+			clusterName, authInfoName = named.Context.Cluster, named.Context.AuthInfo
+		}
+	}
+
+	var cluster *klum.Cluster
+	for i := range kubeconfig.Clusters {
+		if kubeconfig.Clusters[i].Name == clusterName {
+			cluster = &kubeconfig.Clusters[i].Cluster
+		}
+	}
+	var authInfo *klum.AuthInfo
+	for i := range kubeconfig.AuthInfos {
+		if kubeconfig.AuthInfos[i].Name == authInfoName {
+			authInfo = &kubeconfig.AuthInfos[i].AuthInfo
+		}
+	}
+	if cluster == nil || authInfo == nil {
+		return nil, nil, fmt.Errorf("current context %q of the kubeconfig is incomplete", kubeconfig.CurrentContext)
+	}
+	return cluster, authInfo, nil
+}
+
+func resolveSecretKey(ref *klum.SecretKeyReference, defaultKey string, secrets SecretGetter) ([]byte, error) {
+	secret, err := secrets.Get(ref.Namespace, ref.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	key := ref.Key
+	if key == "" {
+		key = defaultKey
+	}
+
+	value, present := secret.Data[key]
+	if !present || len(value) == 0 {
+		return nil, fmt.Errorf("secret %s/%s has no %q key yet", ref.Namespace, ref.Name, key)
+	}
+	return value, nil
+}
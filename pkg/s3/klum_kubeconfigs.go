@@ -0,0 +1,167 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/jadolg/klum/pkg/apiclient"
+	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	"github.com/jadolg/klum/pkg/encrypt"
+	"github.com/jadolg/klum/pkg/usersync"
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultKeyTemplate = "{{.User}}.kubeconfig{{.Extension}}"
+
+// Provider uploads encrypted kubeconfigs to an S3 compatible bucket
+type Provider struct {
+	cfg Config
+}
+
+func NewProvider(cfg Config) *Provider {
+	return &Provider{cfg: cfg}
+}
+
+func (p *Provider) Name() string {
+	return "s3"
+}
+
+func (p *Provider) Enabled() bool {
+	return p.cfg.Enabled()
+}
+
+// RemoveDisabledUsers deletes the object while its user is disabled
+func (p *Provider) RemoveDisabledUsers() bool {
+	return true
+}
+
+func (p *Provider) Upload(ctx context.Context, sync usersync.Object, payload []byte) error {
+	userSync, err := asUserSyncS3(sync)
+	if err != nil {
+		return err
+	}
+	s3Sync := userSync.Spec.S3
+	key, err := objectKey(userSync)
+	if err != nil {
+		return err
+	}
+
+	encrypted, err := encrypt.Encrypt(s3Sync.PublicKey, payload)
+	if err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"user":   userSync.Spec.User,
+		"bucket": s3Sync.Bucket,
+		"key":    key,
+	}).Info("Uploading object")
+
+	client, err := newS3Client(p.cfg)
+	if err != nil {
+		return err
+	}
+	return client.putObject(ctx, s3Sync.Bucket, key, encrypted, map[string]string{
+		"klum-user": userSync.Spec.User,
+		"klum-hash": fmt.Sprintf("%x", sha256.Sum256(payload)),
+	})
+}
+
+func (p *Provider) Delete(ctx context.Context, sync usersync.Object) error {
+	userSync, err := asUserSyncS3(sync)
+	if err != nil {
+		return err
+	}
+	key, err := objectKey(userSync)
+	if err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"user":   userSync.Spec.User,
+		"bucket": userSync.Spec.S3.Bucket,
+		"key":    key,
+	}).Info("Deleting object")
+
+	client, err := newS3Client(p.cfg)
+	if err != nil {
+		return err
+	}
+	err = client.deleteObject(ctx, userSync.Spec.S3.Bucket, key)
+	if apiclient.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func (p *Provider) Verify(ctx context.Context, sync usersync.Object) (bool, error) {
+	userSync, err := asUserSyncS3(sync)
+	if err != nil {
+		return false, err
+	}
+	key, err := objectKey(userSync)
+	if err != nil {
+		return false, err
+	}
+
+	client, err := newS3Client(p.cfg)
+	if err != nil {
+		return false, err
+	}
+	_, err = client.headObject(ctx, userSync.Spec.S3.Bucket, key)
+	if apiclient.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// objectKey validates the spec of userSync and renders its key template
+func objectKey(userSync *klum.UserSyncS3) (string, error) {
+	s3Sync := userSync.Spec.S3
+	if err := s3Sync.Validate(); err != nil {
+		return "", err
+	}
+	if err := encrypt.Validate(s3Sync.PublicKey); err != nil {
+		return "", err
+	}
+
+	keyTemplate := s3Sync.Key
+	if keyTemplate == "" {
+		keyTemplate = defaultKeyTemplate
+	}
+	tmpl, err := template.New("key").Option("missingkey=error").Parse(keyTemplate)
+	if err != nil {
+		return "", fmt.Errorf("invalid s3 key template: %w", err)
+	}
+
+	key := &bytes.Buffer{}
+	err = tmpl.Execute(key, struct {
+		User      string
+		Name      string
+		Extension string
+	}{
+		User:      userSync.Spec.User,
+		Name:      userSync.Name,
+		Extension: encrypt.Extension(s3Sync.PublicKey),
+	})
+	if err != nil {
+		return "", fmt.Errorf("invalid s3 key template: %w", err)
+	}
+
+	if strings.TrimPrefix(key.String(), "/") == "" {
+		return "", fmt.Errorf("s3 key template %q renders an empty key", keyTemplate)
+	}
+	return strings.TrimPrefix(key.String(), "/"), nil
+}
+
+func asUserSyncS3(sync usersync.Object) (*klum.UserSyncS3, error) {
+	userSync, ok := sync.(*klum.UserSyncS3)
+	if !ok {
+		return nil, fmt.Errorf("s3 provider can't synchronize %T", sync)
+	}
+	return userSync, nil
+}
@@ -0,0 +1,137 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"filippo.io/age"
+	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type object struct {
+	body     []byte
+	metadata http.Header
+}
+
+// fakeS3 stores objects by path and checks that requests are signed
+type fakeS3 struct {
+	lock    sync.Mutex
+	objects map[string]object
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	path := r.URL.EscapedPath()
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		metadata := http.Header{}
+		for name := range r.Header {
+			if strings.HasPrefix(name, metadataPrefix) {
+				metadata.Set(name, r.Header.Get(name))
+			}
+		}
+		f.objects[path] = object{body: body, metadata: metadata}
+	case http.MethodHead:
+		o, found := f.objects[path]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		for name := range o.metadata {
+			w.Header().Set(name, o.metadata.Get(name))
+		}
+	case http.MethodDelete:
+		delete(f.objects, path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func newTestProvider(t *testing.T) (*Provider, *fakeS3) {
+	fake := &fakeS3{objects: map[string]object{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return NewProvider(Config{Endpoint: server.URL, AccessKeyID: "access", SecretAccessKey: "secret"}), fake
+}
+
+func newTestSync(t *testing.T, key string) (*klum.UserSyncS3, *age.X25519Identity) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	return &klum.UserSyncS3{
+		ObjectMeta: metav1.ObjectMeta{Name: "darren-export"},
+		Spec: klum.UserSyncS3Spec{
+			User: "darren",
+			S3: klum.S3SyncSpec{
+				Bucket:    "kubeconfigs",
+				Key:       key,
+				PublicKey: identity.Recipient().String(),
+			},
+		},
+	}, identity
+}
+
+func TestUploadVerifyDelete(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	sync, identity := newTestSync(t, "")
+
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	o, found := fake.objects["/kubeconfigs/darren.kubeconfig.age"]
+	require.True(t, found)
+	assert.Equal(t, "darren", o.metadata.Get("X-Amz-Meta-Klum-User"))
+	assert.Len(t, o.metadata.Get("X-Amz-Meta-Klum-Hash"), 64)
+
+	r, err := age.Decrypt(bytes.NewReader(o.body), identity)
+	require.NoError(t, err)
+	plaintext, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "kubeconfig", string(plaintext))
+
+	exists, err := provider.Verify(context.Background(), sync)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	require.NoError(t, provider.Delete(context.Background(), sync))
+	exists, err = provider.Verify(context.Background(), sync)
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestObjectKey(t *testing.T) {
+	sync, _ := newTestSync(t, "/teams/{{.Name}}/{{.User}} config{{.Extension}}")
+	key, err := objectKey(sync)
+	require.NoError(t, err)
+	assert.Equal(t, "teams/darren-export/darren config.age", key)
+
+	provider, fake := newTestProvider(t)
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	assert.Contains(t, fake.objects, "/kubeconfigs/teams/darren-export/darren%20config.age")
+
+	sync.Spec.S3.Key = "{{.Missing}}"
+	_, err = objectKey(sync)
+	assert.Error(t, err)
+
+	sync.Spec.S3.Key = ""
+	sync.Spec.S3.PublicKey = "not a key"
+	_, err = objectKey(sync)
+	assert.Error(t, err)
+}
+
+func TestRemoveDisabledUsers(t *testing.T) {
+	assert.True(t, NewProvider(Config{}).RemoveDisabledUsers())
+}
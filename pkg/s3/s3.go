@@ -0,0 +1,118 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jadolg/klum/pkg/apiclient"
+	"github.com/jadolg/klum/pkg/sigv4"
+)
+
+const (
+	defaultRegion  = "us-east-1"
+	metadataPrefix = "X-Amz-Meta-"
+)
+
+// Config of an S3 compatible object storage. Buckets are always addressed path-style
+// (https://endpoint/bucket/key) which is what MinIO and Ceph RGW expect.
+type Config struct {
+	Endpoint        string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+func (c *Config) Enabled() bool {
+	return c.Endpoint != "" && c.AccessKeyID != "" && c.SecretAccessKey != ""
+}
+
+type client struct {
+	cfg        Config
+	endpoint   *url.URL
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+func newS3Client(cfg Config) (*client, error) {
+	if !cfg.Enabled() {
+		return nil, fmt.Errorf("insufficient information provided. S3 client can't be created")
+	}
+	if cfg.Region == "" {
+		cfg.Region = defaultRegion
+	}
+	endpoint, err := url.Parse(strings.TrimSuffix(cfg.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	return &client{
+		cfg:        cfg,
+		endpoint:   endpoint,
+		httpClient: apiclient.NewHTTPClient(),
+		now:        time.Now,
+	}, nil
+}
+
+func (c *client) putObject(ctx context.Context, bucket, key string, body []byte, metadata map[string]string) error {
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/octet-stream")
+	for name, value := range metadata {
+		headers.Set(metadataPrefix+name, value)
+	}
+	_, err := c.do(ctx, http.MethodPut, bucket, key, headers, body)
+	return err
+}
+
+func (c *client) deleteObject(ctx context.Context, bucket, key string) error {
+	_, err := c.do(ctx, http.MethodDelete, bucket, key, http.Header{}, nil)
+	return err
+}
+
+// headObject returns the user metadata of the object
+func (c *client) headObject(ctx context.Context, bucket, key string) (map[string]string, error) {
+	headers, err := c.do(ctx, http.MethodHead, bucket, key, http.Header{}, nil)
+	if err != nil {
+		return nil, err
+	}
+	metadata := map[string]string{}
+	for name := range headers {
+		if strings.HasPrefix(name, metadataPrefix) {
+			metadata[strings.ToLower(strings.TrimPrefix(name, metadataPrefix))] = headers.Get(name)
+		}
+	}
+	return metadata, nil
+}
+
+func (c *client) do(ctx context.Context, method, bucket, key string, headers http.Header, body []byte) (http.Header, error) {
+	u := *c.endpoint
+	u.Path = c.endpoint.Path + "/" + bucket + "/" + strings.TrimPrefix(key, "/")
+	u.RawPath = sigv4.EncodePath(u.Path)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = headers
+	req.ContentLength = int64(len(body))
+	sigv4.Sign(req, body, sigv4.Credentials{
+		Region:          c.cfg.Region,
+		Service:         "s3",
+		AccessKeyID:     c.cfg.AccessKeyID,
+		SecretAccessKey: c.cfg.SecretAccessKey,
+	}, c.now())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, apiclient.ReadError("s3", resp)
+	}
+	return resp.Header, nil
+}
@@ -0,0 +1,468 @@
+package usersync
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	"github.com/jadolg/klum/pkg/metrics"
+	"github.com/jadolg/klum/pkg/render"
+	"github.com/rancher/wrangler/v3/pkg/generic"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/yaml"
+)
+
+// legacyUploadAnnotationPrefix is the annotation the hash of the last upload was kept in before
+// it was recorded in the status
+const legacyUploadAnnotationPrefix = "klum.cattle.io/lastest.upload."
+
+const (
+	// ReachabilityInterval is how often the targets of providers implementing ReachabilityChecker are checked
+	ReachabilityInterval = 5 * time.Minute
+	// RetryInterval is how long a failed Upload of a provider implementing TargetReporter waits to be retried
+	RetryInterval = time.Minute
+	// CallTimeout bounds every call to a provider, so a target that doesn't answer can't block a worker
+	CallTimeout = 5 * time.Minute
+)
+
+// KubeconfigGetter is the subset of the Kubeconfig controller used by the handler
+type KubeconfigGetter interface {
+	Get(name string, options metav1.GetOptions) (*klum.Kubeconfig, error)
+}
+
+// UserGetter is the subset of the User controller used by the handler
+type UserGetter interface {
+	Get(name string, options metav1.GetOptions) (*klum.User, error)
+}
+
+// Handler drives a Provider from the events of its sync custom resource
+type Handler[T interface {
+	generic.RuntimeMetaObject
+	StatusObject
+}, TList runtime.Object] struct {
+	provider    Provider
+	controller  generic.NonNamespacedControllerInterface[T, TList]
+	kubeconfigs KubeconfigGetter
+	users       UserGetter
+	secrets     render.SecretGetter
+
+	verifyInterval time.Duration
+	recorder       record.EventRecorder
+	// ctx is the context the handler was registered with
+	ctx context.Context
+}
+
+func NewHandler[T interface {
+	generic.RuntimeMetaObject
+	StatusObject
+}, TList runtime.Object](provider Provider, controller generic.NonNamespacedControllerInterface[T, TList], kubeconfigs KubeconfigGetter, users UserGetter, secrets render.SecretGetter) *Handler[T, TList] {
+	return &Handler[T, TList]{
+		provider:    provider,
+		controller:  controller,
+		kubeconfigs: kubeconfigs,
+		users:       users,
+		secrets:     secrets,
+	}
+}
+
+// WithDriftDetection verifies the target of every sync every interval. Targets deleted or changed
+// outside of klum are uploaded again and reported in the Drifted condition and with an event to
+// recorder. A zero interval disables it.
+func (h *Handler[T, TList]) WithDriftDetection(interval time.Duration, recorder record.EventRecorder) *Handler[T, TList] {
+	h.verifyInterval = interval
+	h.recorder = recorder
+	return h
+}
+
+func (h *Handler[T, TList]) Provider() Provider {
+	return h.provider
+}
+
+// Register handles the changes and removals of the sync objects of the controller of h. name
+// identifies the handlers and the finalizer of the sync objects.
+func (h *Handler[T, TList]) Register(ctx context.Context, name string) {
+	h.ctx = ctx
+	h.controller.OnChange(ctx, name, h.sync)
+	h.controller.OnRemove(ctx, name, h.OnRemove)
+}
+
+// callContext returns the context of a call to the provider, canceled after CallTimeout
+func (h *Handler[T, TList]) callContext() (context.Context, context.CancelFunc) {
+	ctx := h.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithTimeout(ctx, CallTimeout)
+}
+
+// sync runs OnChange for obj and stores the status it returns. Like a status handler registered
+// with a condition, the status is stored on error as well, so LastError and the Ready condition
+// report why the sync is retried.
+func (h *Handler[T, TList]) sync(key string, obj T) (T, error) {
+	var zero T
+	if obj == zero || !obj.GetDeletionTimestamp().IsZero() {
+		return obj, nil
+	}
+
+	origStatus := obj.GetSyncStatus()
+	_, newStatus, err := h.OnChange(obj, *origStatus.DeepCopy())
+	if equality.Semantic.DeepEqual(origStatus, newStatus) {
+		return obj, err
+	}
+
+	obj = obj.DeepCopyObject().(T)
+	obj.SetSyncStatus(newStatus)
+	updated, updateErr := h.controller.UpdateStatus(obj)
+	if err == nil {
+		err = updateErr
+	}
+	if updateErr == nil {
+		obj = updated
+	}
+	return obj, err
+}
+
+func (h *Handler[T, TList]) OnChange(sync T, status klum.UserSyncStatus) ([]runtime.Object, klum.UserSyncStatus, error) {
+	var zero T
+	if sync == zero {
+		return nil, setReady(status, false, nil), nil
+	}
+
+	if !h.provider.Enabled() {
+		log.WithFields(log.Fields{
+			"usersync": sync.GetName(),
+			"provider": h.provider.Name(),
+		}).Warning("Synchronization is disabled but sync objects are created")
+		err := fmt.Errorf("%s synchronization is disabled in klum", h.provider.Name())
+		return nil, setReady(status, false, err), nil
+	}
+
+	// Adopts the hash of syncs uploaded before it was kept in the status
+	status.Hash = LastUploadHash(status, sync, h.provider.Name())
+	status = h.checkReachable(sync, status)
+
+	kubeconfig, err := h.kubeconfigs.Get(sync.SyncUser(), metav1.GetOptions{})
+	if errors.IsNotFound(err) && h.userDisabled(sync.SyncUser()) {
+		return h.onUserDisabled(sync, status)
+	}
+	if err != nil {
+		return nil, setReady(status, false, err), err
+	}
+
+	kubeconfig, err = render.Kubeconfig(kubeconfig, h.secrets)
+	if err != nil {
+		return nil, setReady(status, false, err), err
+	}
+
+	payload, err := yaml.Marshal(kubeconfig.Spec)
+	if err != nil {
+		return nil, setReady(status, false, err), err
+	}
+
+	upToDate, hash := isUpToDate(status, payload)
+	// Syncs synchronized before the generation was observed adopt their current generation
+	specChanged := status.ObservedGeneration != 0 && status.ObservedGeneration != sync.GetGeneration()
+	if upToDate && !specChanged {
+		status.ObservedGeneration = sync.GetGeneration()
+		var drifted bool
+		if status, drifted = h.checkDrift(sync, status); !drifted {
+			err := deliveryError(status)
+			return []runtime.Object{}, setReady(status, err == nil, err), nil
+		}
+	}
+
+	ctx, cancel := h.callContext()
+	err = h.provider.Upload(ctx, sync, payload)
+	cancel()
+	status = h.recordDelivery(sync, status)
+	reporter, reportsTargets := h.provider.(TargetReporter)
+	if reportsTargets {
+		status.Targets = reporter.Targets(sync)
+	}
+	retryAfter := asRetryAfter(err)
+	if retryAfter != nil || (err != nil && reportsTargets) {
+		// Returning the error would retry right away and discard the status of the targets
+		after := RetryInterval
+		if retryAfter != nil {
+			after = retryAfter.After
+		}
+		log.WithFields(log.Fields{
+			"usersync": sync.GetName(),
+			"provider": h.provider.Name(),
+			"after":    after,
+		}).WithError(err).Warning("Synchronization failed, retrying later")
+		h.controller.EnqueueAfter(sync.GetName(), after)
+		return nil, setReady(status, false, err), nil
+	}
+	if err != nil {
+		return nil, setReady(status, false, err), err
+	}
+
+	now := metav1.Now()
+	status.Hash = hash
+	status.LastUpload = &now
+	status.KubeconfigRevision = kubeconfig.ResourceVersion
+	status.ObservedGeneration = sync.GetGeneration()
+	err = deliveryError(status)
+	return []runtime.Object{}, setReady(clearDrift(status), err == nil, err), nil
+}
+
+// deliveryError returns the error of the last delivery recorded in status. A provider gives up a
+// delivery by recording it as failed without returning an error, so the same kubeconfig isn't
+// uploaded again and the sync is not ready until the kubeconfig changes.
+func deliveryError(status klum.UserSyncStatus) error {
+	if status.LastDelivery == nil || status.LastDelivery.Error == "" {
+		return nil
+	}
+	return fmt.Errorf("last delivery failed: %s", status.LastDelivery.Error)
+}
+
+// clearDrift resets the Drifted condition once the kubeconfig was uploaded again
+func clearDrift(status klum.UserSyncStatus) klum.UserSyncStatus {
+	userSync := &klum.UserSyncGithub{Status: status}
+	if klum.UserSyncDriftedCondition.IsTrue(userSync) {
+		klum.UserSyncDriftedCondition.False(userSync)
+		klum.UserSyncDriftedCondition.Message(userSync, "")
+	}
+	return userSync.Status
+}
+
+func (h *Handler[T, TList]) OnRemove(key string, sync T) (T, error) {
+	var zero T
+	if sync == zero {
+		return zero, nil
+	}
+
+	if !h.provider.Enabled() {
+		log.WithFields(log.Fields{
+			"usersync": sync.GetName(),
+			"provider": h.provider.Name(),
+		}).Warning("Synchronization is disabled but sync objects are created")
+		return zero, nil
+	}
+
+	ctx, cancel := h.callContext()
+	defer cancel()
+	if err := h.provider.Delete(ctx, sync); err != nil {
+		metrics.ErrorsTotal.Inc()
+		return zero, err
+	}
+	return zero, nil
+}
+
+// onUserDisabled removes the secret of a disabled (or deleted) user from providers implementing
+// DisabledUserRemover. Other providers keep the last kubeconfig, which stops working with the token.
+func (h *Handler[T, TList]) onUserDisabled(sync T, status klum.UserSyncStatus) ([]runtime.Object, klum.UserSyncStatus, error) {
+	message := fmt.Sprintf("user %s is disabled", sync.SyncUser())
+
+	if remover, ok := h.provider.(DisabledUserRemover); !ok || !remover.RemoveDisabledUsers() {
+		return nil, setNotReady(status, message), nil
+	}
+	if status.Hash == "" {
+		return nil, setNotReady(status, message), nil
+	}
+
+	log.WithFields(log.Fields{
+		"usersync": sync.GetName(),
+		"provider": h.provider.Name(),
+	}).Info("Removing credentials of disabled user")
+	ctx, cancel := h.callContext()
+	err := h.provider.Delete(ctx, sync)
+	cancel()
+	status = h.recordDelivery(sync, status)
+	if retryAfter := asRetryAfter(err); retryAfter != nil {
+		h.controller.EnqueueAfter(sync.GetName(), retryAfter.After)
+		return nil, setReady(status, false, err), nil
+	}
+	if err != nil {
+		return nil, setReady(status, false, err), err
+	}
+
+	// Forget the last upload so the kubeconfig is uploaded again once the user is enabled
+	now := metav1.Now()
+	status.Hash = ""
+	status.LastUpload = &now
+	status.KubeconfigRevision = ""
+	return nil, setNotReady(status, message), nil
+}
+
+func (h *Handler[T, TList]) recordDelivery(sync T, status klum.UserSyncStatus) klum.UserSyncStatus {
+	if reporter, ok := h.provider.(DeliveryReporter); ok {
+		if delivery := reporter.LastDelivery(sync); delivery != nil {
+			status.LastDelivery = delivery
+		}
+	}
+	return status
+}
+
+// checkReachable sets the Reachable condition for providers implementing ReachabilityChecker
+// and checks again after ReachabilityInterval
+func (h *Handler[T, TList]) checkReachable(sync T, status klum.UserSyncStatus) klum.UserSyncStatus {
+	checker, ok := h.provider.(ReachabilityChecker)
+	if !ok {
+		return status
+	}
+	defer h.controller.EnqueueAfter(sync.GetName(), ReachabilityInterval)
+
+	userSync := &klum.UserSyncGithub{Status: status}
+	ctx, cancel := h.callContext()
+	defer cancel()
+	if err := checker.Reachable(ctx, sync); err != nil {
+		log.WithFields(log.Fields{
+			"usersync": sync.GetName(),
+			"provider": h.provider.Name(),
+		}).WithError(err).Warning("Target is not reachable")
+		klum.UserSyncReachableCondition.False(userSync)
+		klum.UserSyncReachableCondition.Message(userSync, err.Error())
+	} else {
+		klum.UserSyncReachableCondition.True(userSync)
+		klum.UserSyncReachableCondition.Message(userSync, "")
+	}
+	return userSync.Status
+}
+
+// verifyIntervalFor returns how often the target of sync is verified, the shorter of verifyInterval
+// and the interval of a Resyncer. Zero disables the verification.
+func (h *Handler[T, TList]) verifyIntervalFor(sync T) time.Duration {
+	interval := h.verifyInterval
+	if resyncer, ok := h.provider.(Resyncer); ok {
+		if resync := resyncer.ResyncInterval(sync); resync > 0 && (interval <= 0 || resync < interval) {
+			interval = resync
+		}
+	}
+	return interval
+}
+
+// checkDrift verifies the target of sync once its verify interval passed since the last verification
+// and reports whether it drifted, setting the Drifted condition
+func (h *Handler[T, TList]) checkDrift(sync T, status klum.UserSyncStatus) (klum.UserSyncStatus, bool) {
+	interval := h.verifyIntervalFor(sync)
+	if interval <= 0 {
+		return status, false
+	}
+	if detector, ok := h.provider.(DriftDetector); ok && !detector.DetectsDrift() {
+		return status, false
+	}
+	if status.LastVerified != nil {
+		if next := time.Until(status.LastVerified.Add(interval)); next > 0 {
+			h.controller.EnqueueAfter(sync.GetName(), next)
+			return status, false
+		}
+	}
+	defer h.controller.EnqueueAfter(sync.GetName(), interval)
+
+	fields := log.Fields{
+		"usersync": sync.GetName(),
+		"provider": h.provider.Name(),
+	}
+	ctx, cancel := h.callContext()
+	inSync, err := h.provider.Verify(ctx, sync)
+	cancel()
+	if err != nil {
+		log.WithFields(fields).WithError(err).Warning("Verification failed")
+		return status, false
+	}
+
+	now := metav1.Now()
+	status.LastVerified = &now
+	userSync := &klum.UserSyncGithub{Status: status}
+	if inSync {
+		klum.UserSyncDriftedCondition.False(userSync)
+		klum.UserSyncDriftedCondition.Message(userSync, "")
+		return userSync.Status, false
+	}
+
+	message := fmt.Sprintf("kubeconfig was deleted or changed in %s outside of klum, uploading it again", h.provider.Name())
+	log.WithFields(fields).Warning("Drift detected")
+	metrics.DriftDetectedTotal.WithLabelValues(h.provider.Name()).Inc()
+	klum.UserSyncDriftedCondition.True(userSync)
+	klum.UserSyncDriftedCondition.Message(userSync, message)
+	if h.recorder != nil {
+		h.recorder.Event(sync, v1.EventTypeWarning, "Drifted", message)
+	}
+	return userSync.Status, true
+}
+
+func (h *Handler[T, TList]) userDisabled(name string) bool {
+	user, err := h.users.Get(name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return true
+	}
+	if err != nil {
+		return false
+	}
+	return user.Spec.Enabled != nil && !*user.Spec.Enabled
+}
+
+// EnqueueUser enqueues every sync object delivering the kubeconfig of user
+func (h *Handler[T, TList]) EnqueueUser(user string) error {
+	// ToDo: Check how we can make `spec.user` usable as a field selector
+	list, err := h.controller.List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		sync, ok := item.(Object)
+		if !ok || sync.SyncUser() != user {
+			continue
+		}
+		log.WithFields(log.Fields{
+			"usersync": sync.GetName(),
+			"provider": h.provider.Name(),
+		}).Info("Synchronizing credentials")
+		h.controller.Enqueue(sync.GetName())
+	}
+	return nil
+}
+
+// LastUploadHash returns the hash of the kubeconfig last uploaded by provider for sync with status.
+// It is empty before the first upload. Syncs uploaded before the hash was recorded in the status
+// return the hash of the legacy annotation until the status records an upload.
+func LastUploadHash(status klum.UserSyncStatus, sync Object, provider string) string {
+	if status.Hash != "" || status.LastUpload != nil {
+		return status.Hash
+	}
+	return sync.GetAnnotations()[legacyUploadAnnotationPrefix+provider]
+}
+
+func isUpToDate(status klum.UserSyncStatus, payload []byte) (bool, string) {
+	hash := fmt.Sprintf("%x", sha256.Sum256(payload))
+	return status.Hash != "" && status.Hash == hash, hash
+}
+
+func setNotReady(status klum.UserSyncStatus, message string) klum.UserSyncStatus {
+	userSync := &klum.UserSyncGithub{Status: status}
+	klum.UserSyncReadyCondition.False(userSync)
+	klum.UserSyncReadyCondition.Message(userSync, message)
+	return userSync.Status
+}
+
+func setReady(status klum.UserSyncStatus, ready bool, err error) klum.UserSyncStatus {
+	// dumb hack to set condition, should really make this easier
+	userSync := &klum.UserSyncGithub{Status: status}
+	klum.UserSyncReadyCondition.SetStatusBool(userSync, ready)
+	if ready {
+		userSync.Status.LastError = ""
+	}
+	if err != nil {
+		metrics.ErrorsTotal.Inc()
+		userSync.Status.LastError = err.Error()
+		klum.UserSyncReadyCondition.SetError(userSync, err.Error(), err)
+	}
+	return userSync.Status
+}
@@ -0,0 +1,578 @@
+package usersync
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	"github.com/rancher/wrangler/v3/pkg/generic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+)
+
+type fakeProvider struct {
+	enabled   bool
+	remover   bool
+	uploadErr error
+	uploads   [][]byte
+	deletes   int
+	delivery  *klum.DeliveryStatus
+	drifted   bool
+	verifies  int
+}
+
+func (f *fakeProvider) Name() string  { return "fake" }
+func (f *fakeProvider) Enabled() bool { return f.enabled }
+func (f *fakeProvider) Upload(ctx context.Context, sync Object, payload []byte) error {
+	if f.uploadErr != nil {
+		return f.uploadErr
+	}
+	f.uploads = append(f.uploads, payload)
+	return nil
+}
+func (f *fakeProvider) Delete(ctx context.Context, sync Object) error {
+	f.deletes++
+	return nil
+}
+func (f *fakeProvider) LastDelivery(sync Object) *klum.DeliveryStatus {
+	return f.delivery
+}
+func (f *fakeProvider) RemoveDisabledUsers() bool {
+	return f.remover
+}
+func (f *fakeProvider) Verify(ctx context.Context, sync Object) (bool, error) {
+	f.verifies++
+	return !f.drifted, nil
+}
+
+// fakeReachableProvider is a fakeProvider whose target can be unreachable
+type fakeReachableProvider struct {
+	fakeProvider
+	unreachable error
+}
+
+func (f *fakeReachableProvider) Reachable(ctx context.Context, sync Object) error {
+	return f.unreachable
+}
+
+// fakeTargetProvider is a fakeProvider reporting the status of its targets
+type fakeTargetProvider struct {
+	fakeProvider
+	targets []klum.TargetStatus
+}
+
+func (f *fakeTargetProvider) Targets(sync Object) []klum.TargetStatus {
+	return f.targets
+}
+
+// fakeController implements the calls made by Handler, any other call panics
+type fakeController struct {
+	generic.NonNamespacedControllerInterface[*klum.UserSyncGithub, *klum.UserSyncGithubList]
+	items         []klum.UserSyncGithub
+	enqueued      []string
+	enqueuedAfter map[string]time.Duration
+	statusUpdates []*klum.UserSyncGithub
+}
+
+func (f *fakeController) UpdateStatus(obj *klum.UserSyncGithub) (*klum.UserSyncGithub, error) {
+	f.statusUpdates = append(f.statusUpdates, obj)
+	return obj, nil
+}
+
+func (f *fakeController) List(opts metav1.ListOptions) (*klum.UserSyncGithubList, error) {
+	return &klum.UserSyncGithubList{Items: f.items}, nil
+}
+
+func (f *fakeController) Enqueue(name string) {
+	f.enqueued = append(f.enqueued, name)
+}
+
+func (f *fakeController) EnqueueAfter(name string, duration time.Duration) {
+	if f.enqueuedAfter == nil {
+		f.enqueuedAfter = map[string]time.Duration{}
+	}
+	f.enqueuedAfter[name] = duration
+}
+
+type fakeKubeconfigs map[string]*klum.Kubeconfig
+
+func (f fakeKubeconfigs) Get(name string, options metav1.GetOptions) (*klum.Kubeconfig, error) {
+	if kc, ok := f[name]; ok {
+		return kc, nil
+	}
+	return nil, errors.NewNotFound(schema.GroupResource{Group: "klum.cattle.io", Resource: "kubeconfigs"}, name)
+}
+
+type fakeUsers map[string]*klum.User
+
+func (f fakeUsers) Get(name string, options metav1.GetOptions) (*klum.User, error) {
+	if user, ok := f[name]; ok {
+		return user, nil
+	}
+	return nil, errors.NewNotFound(schema.GroupResource{Group: "klum.cattle.io", Resource: "users"}, name)
+}
+
+type fakeSecrets map[string]*v1.Secret
+
+func (f fakeSecrets) Get(namespace, name string) (*v1.Secret, error) {
+	if secret, ok := f[namespace+"/"+name]; ok {
+		return secret, nil
+	}
+	return nil, errors.NewNotFound(schema.GroupResource{Resource: "secrets"}, name)
+}
+
+func newTestSyncHandler(provider Provider, controller *fakeController) *Handler[*klum.UserSyncGithub, *klum.UserSyncGithubList] {
+	kubeconfigs := fakeKubeconfigs{
+		"darren": {
+			ObjectMeta: metav1.ObjectMeta{Name: "darren", ResourceVersion: "42"},
+			Spec: klum.KubeconfigSpec{
+				AuthInfos: []klum.NamedAuthInfo{
+					{
+						Name: "darren",
+						AuthInfo: klum.AuthInfo{
+							TokenSecretRef: &klum.SecretKeyReference{Namespace: "klum", Name: "darren"},
+						},
+					},
+				},
+			},
+		},
+	}
+	enabled := false
+	users := fakeUsers{
+		"darren":   {ObjectMeta: metav1.ObjectMeta{Name: "darren"}},
+		"disabled": {ObjectMeta: metav1.ObjectMeta{Name: "disabled"}, Spec: klum.UserSpec{Enabled: &enabled}},
+	}
+	secrets := fakeSecrets{
+		"klum/darren": {Data: map[string][]byte{"token": []byte("secret-token")}},
+	}
+	return NewHandler[*klum.UserSyncGithub, *klum.UserSyncGithubList](provider, controller, kubeconfigs, users, secrets)
+}
+
+func newTestSync(user string) *klum.UserSyncGithub {
+	return &klum.UserSyncGithub{
+		ObjectMeta: metav1.ObjectMeta{Name: "sync-" + user},
+		Spec:       klum.UserSyncGithubSpec{User: user},
+	}
+}
+
+func TestOnChange_UploadsOnlyOnChanges(t *testing.T) {
+	provider := &fakeProvider{enabled: true}
+	controller := &fakeController{}
+	h := newTestSyncHandler(provider, controller)
+
+	sync := newTestSync("darren")
+	_, status, err := h.OnChange(sync, klum.UserSyncStatus{})
+	require.NoError(t, err)
+	assert.True(t, klum.UserSyncReadyCondition.IsTrue(&klum.UserSyncGithub{Status: status}))
+
+	require.Len(t, provider.uploads, 1)
+	assert.Contains(t, string(provider.uploads[0]), "token: secret-token")
+	assert.Len(t, status.Hash, 64)
+	assert.NotNil(t, status.LastUpload)
+	assert.Equal(t, "42", status.KubeconfigRevision)
+	assert.Empty(t, sync.Annotations, "the sync is not updated")
+
+	// Same content is not uploaded again
+	_, _, err = h.OnChange(sync, status)
+	require.NoError(t, err)
+	assert.Len(t, provider.uploads, 1)
+}
+
+func TestOnChange_UploadsOnSpecChanges(t *testing.T) {
+	provider := &fakeProvider{enabled: true}
+	controller := &fakeController{}
+	h := newTestSyncHandler(provider, controller)
+
+	sync := newTestSync("darren")
+	sync.Generation = 1
+	_, status, err := h.OnChange(sync, klum.UserSyncStatus{})
+	require.NoError(t, err)
+	require.Len(t, provider.uploads, 1)
+	assert.Equal(t, int64(1), status.ObservedGeneration)
+
+	sync.Generation = 2
+	_, status, err = h.OnChange(sync, status)
+	require.NoError(t, err)
+	assert.Len(t, provider.uploads, 2, "the spec changed")
+	assert.Equal(t, int64(2), status.ObservedGeneration)
+}
+
+func TestOnChange_AdoptsGeneration(t *testing.T) {
+	provider := &fakeProvider{enabled: true}
+	controller := &fakeController{}
+	h := newTestSyncHandler(provider, controller)
+
+	sync := newTestSync("darren")
+	_, status, err := h.OnChange(sync, klum.UserSyncStatus{})
+	require.NoError(t, err)
+
+	// Synchronized by a version of klum that didn't observe generations
+	sync.Generation = 3
+	status.ObservedGeneration = 0
+	_, status, err = h.OnChange(sync, status)
+	require.NoError(t, err)
+	assert.Len(t, provider.uploads, 1)
+	assert.Equal(t, int64(3), status.ObservedGeneration)
+}
+
+func TestOnChange_UploadError(t *testing.T) {
+	provider := &fakeProvider{enabled: true, uploadErr: fmt.Errorf("boom")}
+	controller := &fakeController{}
+	h := newTestSyncHandler(provider, controller)
+
+	_, status, err := h.OnChange(newTestSync("darren"), klum.UserSyncStatus{})
+	require.Error(t, err)
+	assert.True(t, klum.UserSyncReadyCondition.IsFalse(&klum.UserSyncGithub{Status: status}))
+	assert.Empty(t, status.Hash)
+}
+
+func TestOnChange_RecordsTargets(t *testing.T) {
+	provider := &fakeTargetProvider{fakeProvider: fakeProvider{enabled: true, uploadErr: fmt.Errorf("boom")}}
+	provider.targets = []klum.TargetStatus{
+		{Owner: "jadolg", Repository: "klum", Hash: "hash"},
+		{Owner: "jadolg", Repository: "api", Error: "boom"},
+	}
+	controller := &fakeController{}
+	h := newTestSyncHandler(provider, controller)
+
+	_, status, err := h.OnChange(newTestSync("darren"), klum.UserSyncStatus{})
+	require.NoError(t, err, "the status of the targets is kept")
+	assert.Equal(t, provider.targets, status.Targets)
+	assert.True(t, klum.UserSyncReadyCondition.IsFalse(&klum.UserSyncGithub{Status: status}))
+	assert.Equal(t, RetryInterval, controller.enqueuedAfter["sync-darren"])
+	assert.Equal(t, "boom", status.LastError)
+	assert.Empty(t, status.Hash)
+
+	provider.uploadErr = nil
+	_, status, err = h.OnChange(newTestSync("darren"), status)
+	require.NoError(t, err)
+	assert.True(t, klum.UserSyncReadyCondition.IsTrue(&klum.UserSyncGithub{Status: status}))
+	assert.Empty(t, status.LastError)
+	assert.NotEmpty(t, status.Hash)
+}
+
+func TestOnChange_RetryAfter(t *testing.T) {
+	provider := &fakeProvider{enabled: true, uploadErr: &RetryAfterError{Err: fmt.Errorf("rate limited"), After: 10 * time.Minute}}
+	controller := &fakeController{}
+	h := newTestSyncHandler(provider, controller)
+
+	_, status, err := h.OnChange(newTestSync("darren"), klum.UserSyncStatus{})
+	require.NoError(t, err, "the sync is requeued instead")
+	assert.True(t, klum.UserSyncReadyCondition.IsFalse(&klum.UserSyncGithub{Status: status}))
+	assert.Equal(t, 10*time.Minute, controller.enqueuedAfter["sync-darren"])
+}
+
+func TestOnChange_DetectsDrift(t *testing.T) {
+	provider := &fakeProvider{enabled: true}
+	controller := &fakeController{}
+	recorder := record.NewFakeRecorder(10)
+	h := newTestSyncHandler(provider, controller).WithDriftDetection(time.Hour, recorder)
+
+	sync := newTestSync("darren")
+	_, status, err := h.OnChange(sync, klum.UserSyncStatus{})
+	require.NoError(t, err)
+
+	_, status, err = h.OnChange(sync, status)
+	require.NoError(t, err)
+	assert.Equal(t, 1, provider.verifies)
+	assert.NotNil(t, status.LastVerified)
+	assert.True(t, klum.UserSyncDriftedCondition.IsFalse(&klum.UserSyncGithub{Status: status}))
+	assert.Equal(t, time.Hour, controller.enqueuedAfter["sync-darren"])
+
+	// Not verified again until the interval passed
+	provider.drifted = true
+	_, status, err = h.OnChange(sync, status)
+	require.NoError(t, err)
+	assert.Equal(t, 1, provider.verifies)
+	assert.Len(t, provider.uploads, 1)
+
+	lastVerified := metav1.NewTime(time.Now().Add(-2 * time.Hour))
+	status.LastVerified = &lastVerified
+	_, status, err = h.OnChange(sync, status)
+	require.NoError(t, err)
+	assert.Equal(t, 2, provider.verifies)
+	assert.Len(t, provider.uploads, 2, "the drifted kubeconfig is uploaded again")
+	userSync := &klum.UserSyncGithub{Status: status}
+	assert.True(t, klum.UserSyncDriftedCondition.IsFalse(userSync), "the drift is cleared once uploaded again")
+	assert.True(t, klum.UserSyncReadyCondition.IsTrue(userSync))
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "Warning Drifted")
+}
+
+func TestOnChange_DriftDetectionIsOptional(t *testing.T) {
+	provider := &fakeProvider{enabled: true, drifted: true}
+	controller := &fakeController{}
+	h := newTestSyncHandler(provider, controller)
+
+	sync := newTestSync("darren")
+	_, status, err := h.OnChange(sync, klum.UserSyncStatus{})
+	require.NoError(t, err)
+	_, status, err = h.OnChange(sync, status)
+	require.NoError(t, err)
+	assert.Zero(t, provider.verifies)
+	assert.Nil(t, status.LastVerified)
+}
+
+// fakeUndetectableProvider is a fakeProvider whose target can't be read back
+type fakeUndetectableProvider struct {
+	fakeProvider
+}
+
+func (f *fakeUndetectableProvider) DetectsDrift() bool {
+	return false
+}
+
+func TestOnChange_DriftDetectionOptOut(t *testing.T) {
+	provider := &fakeUndetectableProvider{fakeProvider: fakeProvider{enabled: true, drifted: true}}
+	controller := &fakeController{}
+	h := newTestSyncHandler(provider, controller).WithDriftDetection(time.Hour, nil)
+
+	sync := newTestSync("darren")
+	_, status, err := h.OnChange(sync, klum.UserSyncStatus{})
+	require.NoError(t, err)
+	_, status, err = h.OnChange(sync, status)
+	require.NoError(t, err)
+	assert.Zero(t, provider.verifies)
+	assert.Nil(t, status.LastVerified)
+}
+
+// fakeResyncProvider is a fakeProvider whose targets change outside of klum
+type fakeResyncProvider struct {
+	fakeProvider
+}
+
+func (f *fakeResyncProvider) ResyncInterval(sync Object) time.Duration {
+	return 10 * time.Minute
+}
+
+func TestOnChange_Resync(t *testing.T) {
+	provider := &fakeResyncProvider{fakeProvider: fakeProvider{enabled: true}}
+	controller := &fakeController{}
+	h := newTestSyncHandler(provider, controller)
+
+	sync := newTestSync("darren")
+	_, status, err := h.OnChange(sync, klum.UserSyncStatus{})
+	require.NoError(t, err)
+	_, status, err = h.OnChange(sync, status)
+	require.NoError(t, err)
+	assert.Equal(t, 1, provider.verifies, "verified without the drift detection")
+	assert.Equal(t, 10*time.Minute, controller.enqueuedAfter["sync-darren"])
+
+	// The shorter verify interval wins
+	h.WithDriftDetection(time.Hour, nil)
+	lastVerified := metav1.NewTime(time.Now().Add(-10 * time.Minute))
+	status.LastVerified = &lastVerified
+	_, _, err = h.OnChange(sync, status)
+	require.NoError(t, err)
+	assert.Equal(t, 2, provider.verifies)
+	assert.Equal(t, 10*time.Minute, controller.enqueuedAfter["sync-darren"])
+}
+
+func TestSync_StoresStatus(t *testing.T) {
+	provider := &fakeProvider{enabled: true}
+	controller := &fakeController{}
+	h := newTestSyncHandler(provider, controller)
+
+	sync := newTestSync("darren")
+	updated, err := h.sync("sync-darren", sync)
+	require.NoError(t, err)
+	require.Len(t, controller.statusUpdates, 1)
+	assert.True(t, klum.UserSyncReadyCondition.IsTrue(updated))
+	assert.Len(t, updated.Status.Hash, 64)
+	assert.Empty(t, sync.Status.Hash, "the cached object is not modified")
+
+	// Nothing changed, the status is not updated again
+	_, err = h.sync("sync-darren", updated)
+	require.NoError(t, err)
+	assert.Len(t, controller.statusUpdates, 1)
+}
+
+func TestSync_StoresStatusOnError(t *testing.T) {
+	provider := &fakeProvider{enabled: true, uploadErr: fmt.Errorf("boom")}
+	controller := &fakeController{}
+	h := newTestSyncHandler(provider, controller)
+
+	updated, err := h.sync("sync-darren", newTestSync("darren"))
+	require.Error(t, err, "the sync is retried")
+	require.Len(t, controller.statusUpdates, 1, "the failure is stored")
+	assert.True(t, klum.UserSyncReadyCondition.IsFalse(updated))
+	assert.Equal(t, "boom", updated.Status.LastError)
+	assert.Empty(t, updated.Status.Hash)
+
+	// Once it succeeds the error is cleared
+	provider.uploadErr = nil
+	updated, err = h.sync("sync-darren", updated)
+	require.NoError(t, err)
+	require.Len(t, controller.statusUpdates, 2)
+	assert.True(t, klum.UserSyncReadyCondition.IsTrue(updated))
+	assert.Empty(t, updated.Status.LastError)
+}
+
+func TestOnChange_RecordsDelivery(t *testing.T) {
+	provider := &fakeProvider{enabled: true, uploadErr: fmt.Errorf("boom")}
+	provider.delivery = &klum.DeliveryStatus{Event: "created", StatusCode: 500, Attempts: 3, Error: "boom"}
+	h := newTestSyncHandler(provider, &fakeController{})
+
+	_, status, err := h.OnChange(newTestSync("darren"), klum.UserSyncStatus{})
+	require.Error(t, err)
+	assert.Equal(t, provider.delivery, status.LastDelivery)
+}
+
+func TestOnChange_GivenUpDelivery(t *testing.T) {
+	provider := &fakeProvider{enabled: true}
+	provider.delivery = &klum.DeliveryStatus{Event: "created", StatusCode: 500, Attempts: 4, Error: "boom"}
+	h := newTestSyncHandler(provider, &fakeController{})
+
+	sync := newTestSync("darren")
+	_, status, err := h.OnChange(sync, klum.UserSyncStatus{})
+	require.NoError(t, err, "the delivery is not retried")
+	assert.NotEmpty(t, status.Hash)
+	userSync := &klum.UserSyncGithub{Status: status}
+	assert.True(t, klum.UserSyncReadyCondition.IsFalse(userSync))
+	assert.Contains(t, status.LastError, "boom")
+
+	_, status, err = h.OnChange(sync, status)
+	require.NoError(t, err)
+	assert.Len(t, provider.uploads, 1)
+	assert.True(t, klum.UserSyncReadyCondition.IsFalse(&klum.UserSyncGithub{Status: status}), "still not ready until the kubeconfig changes")
+}
+
+func TestOnChange_Reachability(t *testing.T) {
+	provider := &fakeReachableProvider{fakeProvider: fakeProvider{enabled: true}}
+	controller := &fakeController{}
+	h := newTestSyncHandler(provider, controller)
+
+	_, status, err := h.OnChange(newTestSync("darren"), klum.UserSyncStatus{})
+	require.NoError(t, err)
+	assert.True(t, klum.UserSyncReachableCondition.IsTrue(&klum.UserSyncGithub{Status: status}))
+	assert.Equal(t, ReachabilityInterval, controller.enqueuedAfter["sync-darren"])
+
+	// The upload of a new kubeconfig fails while the target is unreachable
+	provider.unreachable = fmt.Errorf("connection refused")
+	provider.uploadErr = provider.unreachable
+	status.Hash = ""
+	_, status, err = h.OnChange(newTestSync("darren"), status)
+	require.Error(t, err)
+	userSync := &klum.UserSyncGithub{Status: status}
+	assert.True(t, klum.UserSyncReachableCondition.IsFalse(userSync))
+	assert.Equal(t, "connection refused", klum.UserSyncReachableCondition.GetMessage(userSync))
+	assert.True(t, klum.UserSyncReadyCondition.IsFalse(userSync))
+}
+
+func TestOnChange_ReachabilityIsOptional(t *testing.T) {
+	controller := &fakeController{}
+	h := newTestSyncHandler(&fakeProvider{enabled: true}, controller)
+
+	_, status, err := h.OnChange(newTestSync("darren"), klum.UserSyncStatus{})
+	require.NoError(t, err)
+	assert.Empty(t, klum.UserSyncReachableCondition.GetStatus(&klum.UserSyncGithub{Status: status}))
+	assert.Empty(t, controller.enqueuedAfter)
+}
+
+func TestOnChange_MissingKubeconfig(t *testing.T) {
+	provider := &fakeProvider{enabled: true}
+	h := newTestSyncHandler(provider, &fakeController{})
+
+	sync := newTestSync("darren")
+	sync.Spec.User = "pending"
+	h.users.(fakeUsers)["pending"] = &klum.User{ObjectMeta: metav1.ObjectMeta{Name: "pending"}}
+	_, _, err := h.OnChange(sync, klum.UserSyncStatus{})
+	require.Error(t, err)
+	assert.Empty(t, provider.uploads)
+}
+
+func TestOnChange_DisabledUser(t *testing.T) {
+	provider := &fakeProvider{enabled: true}
+	controller := &fakeController{}
+	h := newTestSyncHandler(provider, controller)
+
+	sync := newTestSync("disabled")
+	uploaded := klum.UserSyncStatus{Hash: "hash"}
+	_, status, err := h.OnChange(sync, uploaded)
+	require.NoError(t, err)
+	assert.True(t, klum.UserSyncReadyCondition.IsFalse(&klum.UserSyncGithub{Status: status}))
+	assert.Equal(t, "user disabled is disabled", klum.UserSyncReadyCondition.GetMessage(&klum.UserSyncGithub{Status: status}))
+	assert.Equal(t, 0, provider.deletes, "providers keep the secret unless they opt in")
+
+	provider.remover = true
+	_, status, err = h.OnChange(sync, uploaded)
+	require.NoError(t, err)
+	assert.Equal(t, 1, provider.deletes)
+	assert.Empty(t, status.Hash)
+
+	// Nothing to remove once the secret is gone, deleted users are handled like disabled ones
+	_, _, err = h.OnChange(sync, status)
+	require.NoError(t, err)
+	_, _, err = h.OnChange(newTestSync("nobody"), klum.UserSyncStatus{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, provider.deletes)
+}
+
+func TestOnChange_MigratesLegacyAnnotation(t *testing.T) {
+	provider := &fakeProvider{enabled: true}
+	controller := &fakeController{}
+	h := newTestSyncHandler(provider, controller)
+
+	sync := newTestSync("darren")
+	_, status, err := h.OnChange(sync, klum.UserSyncStatus{})
+	require.NoError(t, err)
+
+	// Uploaded by a version of klum that kept the hash in an annotation
+	sync.Annotations = map[string]string{"klum.cattle.io/lastest.upload.fake": status.Hash}
+	_, status, err = h.OnChange(sync, klum.UserSyncStatus{})
+	require.NoError(t, err)
+	assert.Len(t, provider.uploads, 1, "the kubeconfig is not uploaded again")
+	assert.Equal(t, sync.Annotations["klum.cattle.io/lastest.upload.fake"], status.Hash)
+
+	// The annotation is ignored once the status records an upload
+	sync.Annotations["klum.cattle.io/lastest.upload.fake"] = "stale"
+	status.Hash = ""
+	status.LastUpload = &metav1.Time{Time: time.Now()}
+	assert.Empty(t, LastUploadHash(status, sync, "fake"))
+}
+
+func TestOnChange_ProviderDisabled(t *testing.T) {
+	provider := &fakeProvider{}
+	h := newTestSyncHandler(provider, &fakeController{})
+
+	_, status, err := h.OnChange(newTestSync("darren"), klum.UserSyncStatus{})
+	require.NoError(t, err)
+	assert.True(t, klum.UserSyncReadyCondition.IsFalse(&klum.UserSyncGithub{Status: status}))
+	assert.Empty(t, provider.uploads)
+}
+
+func TestOnRemove(t *testing.T) {
+	provider := &fakeProvider{enabled: true}
+	h := newTestSyncHandler(provider, &fakeController{})
+
+	_, err := h.OnRemove("sync-darren", newTestSync("darren"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, provider.deletes)
+
+	_, err = h.OnRemove("sync-darren", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, provider.deletes)
+}
+
+func TestRegistry(t *testing.T) {
+	controller := &fakeController{
+		items: []klum.UserSyncGithub{*newTestSync("darren"), *newTestSync("other")},
+	}
+	registry := NewRegistry()
+	require.NoError(t, registry.Register(newTestSyncHandler(&fakeProvider{}, controller)))
+	assert.Error(t, registry.Register(newTestSyncHandler(&fakeProvider{}, controller)))
+
+	provider, found := registry.Provider("fake")
+	require.True(t, found)
+	assert.Equal(t, "fake", provider.Name())
+
+	require.NoError(t, registry.EnqueueUser("darren"))
+	assert.Equal(t, []string{"sync-darren"}, controller.enqueued)
+}
@@ -0,0 +1,106 @@
+package usersync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Object is a custom resource describing where the kubeconfig of a User is delivered to
+type Object interface {
+	runtime.Object
+	metav1.Object
+	// SyncUser is the name of the User whose kubeconfig is synchronized
+	SyncUser() string
+}
+
+// StatusObject is a sync custom resource whose status is kept by Handler
+type StatusObject interface {
+	Object
+	GetSyncStatus() klum.UserSyncStatus
+	SetSyncStatus(status klum.UserSyncStatus)
+}
+
+// Provider delivers kubeconfigs to a target outside the cluster. Everything that is not specific
+// to the target (change detection, status, retries and reacting to Kubeconfig changes) is handled
+// by Handler, so a new target only needs a Provider and a custom resource implementing Object.
+type Provider interface {
+	// Name identifies the provider in logs and annotations
+	Name() string
+	// Enabled reports whether the controller was configured to use this provider
+	Enabled() bool
+	// Upload creates or updates the secret described by sync with payload
+	Upload(ctx context.Context, sync Object, payload []byte) error
+	// Delete removes the secret described by sync
+	Delete(ctx context.Context, sync Object) error
+	// Verify reports whether the secret described by sync still exists in the target, and is
+	// unchanged if the target can tell. A secret that isn't is uploaded again by the drift detection.
+	Verify(ctx context.Context, sync Object) (bool, error)
+}
+
+// DisabledUserRemover is implemented by providers that delete the secret while its user is disabled
+type DisabledUserRemover interface {
+	RemoveDisabledUsers() bool
+}
+
+// DriftDetector is implemented by providers whose target can't be read back, e.g. an email. Syncs
+// of providers returning false are not verified by the drift detection.
+type DriftDetector interface {
+	DetectsDrift() bool
+}
+
+// DeliveryReporter is implemented by providers that record the outcome of their last request
+// for sync, it is copied to the status of the sync object after every Upload and Delete
+type DeliveryReporter interface {
+	LastDelivery(sync Object) *klum.DeliveryStatus
+}
+
+// ReachabilityChecker is implemented by providers that can check whether their target can be
+// reached. The result is reported in the Reachable condition of the sync object, which is checked
+// again every ReachabilityInterval.
+type ReachabilityChecker interface {
+	Reachable(ctx context.Context, sync Object) error
+}
+
+// TargetReporter is implemented by providers synchronizing a sync to several targets. The status of
+// every target is copied to the status of the sync object after every Upload. A failed Upload is
+// retried after RetryInterval, so the status of the targets that succeeded is kept.
+type TargetReporter interface {
+	Targets(sync Object) []klum.TargetStatus
+}
+
+// Resyncer is implemented by providers whose targets change outside of klum, e.g. repositories
+// matched by a selector. A sync with a positive ResyncInterval is verified at least that often, even
+// when the drift detection is disabled or runs less often, so the changed targets are uploaded.
+type Resyncer interface {
+	ResyncInterval(sync Object) time.Duration
+}
+
+// RetryAfterError is returned by providers whose target asked them to wait, e.g. because of a rate
+// limit. The sync is requeued after After instead of being retried right away.
+type RetryAfterError struct {
+	Err   error
+	After time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("%v, retrying in %s", e.Err, e.After.Round(time.Second))
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
+// asRetryAfter returns the RetryAfterError in the chain of err
+func asRetryAfter(err error) *RetryAfterError {
+	var retryAfter *RetryAfterError
+	if errors.As(err, &retryAfter) {
+		return retryAfter
+	}
+	return nil
+}
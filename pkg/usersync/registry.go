@@ -0,0 +1,64 @@
+package usersync
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Syncer is implemented by Handler. It lets the Registry reach every sync type without knowing its Go type
+type Syncer interface {
+	Provider() Provider
+	EnqueueUser(user string) error
+}
+
+// Registry holds the sync types known to the controller
+type Registry struct {
+	lock    sync.RWMutex
+	syncers map[string]Syncer
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		syncers: map[string]Syncer{},
+	}
+}
+
+// Register adds syncer to the registry. Provider names must be unique.
+func (r *Registry) Register(syncer Syncer) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	name := syncer.Provider().Name()
+	if _, present := r.syncers[name]; present {
+		return fmt.Errorf("sync provider %s is already registered", name)
+	}
+	r.syncers[name] = syncer
+	return nil
+}
+
+// Provider returns the provider registered with name
+func (r *Registry) Provider(name string) (Provider, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	syncer, present := r.syncers[name]
+	if !present {
+		return nil, false
+	}
+	return syncer.Provider(), true
+}
+
+// EnqueueUser enqueues the sync objects of every provider that deliver the kubeconfig of user
+func (r *Registry) EnqueueUser(user string) error {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	var errs []error
+	for _, syncer := range r.syncers {
+		if err := syncer.EnqueueUser(user); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
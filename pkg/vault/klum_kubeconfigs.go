@@ -0,0 +1,238 @@
+package vault
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jadolg/klum/pkg/apiclient"
+	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	"github.com/jadolg/klum/pkg/render"
+	"github.com/jadolg/klum/pkg/usersync"
+	log "github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+)
+
+const defaultMount = "secret"
+
+// Provider synchronizes kubeconfigs to a Vault KV v2 secrets engine
+type Provider struct {
+	cfg       Config
+	namespace string
+	secrets   render.SecretGetter
+	// jwtFile is the service account token used for the Kubernetes auth method
+	jwtFile string
+
+	lock        sync.Mutex
+	loginToken  string
+	loginExpiry time.Time
+}
+
+// NewProvider returns a provider authenticating with the Kubernetes auth method, or with the
+// token stored in cfg.TokenSecret in namespace when no role is configured
+func NewProvider(cfg Config, namespace string, secrets render.SecretGetter) *Provider {
+	if cfg.KubernetesAuthPath == "" {
+		cfg.KubernetesAuthPath = defaultKubernetesAuthPath
+	}
+	return &Provider{
+		cfg:       cfg,
+		namespace: namespace,
+		secrets:   secrets,
+		jwtFile:   serviceAccountTokenFile,
+	}
+}
+
+func (p *Provider) Name() string {
+	return "vault"
+}
+
+func (p *Provider) Enabled() bool {
+	return p.cfg.Enabled()
+}
+
+func (p *Provider) Upload(ctx context.Context, sync usersync.Object, payload []byte) error {
+	userSync, err := asUserSyncVault(sync)
+	if err != nil {
+		return err
+	}
+	vaultSync := userSync.Spec.Vault
+	if err := vaultSync.Validate(); err != nil {
+		return err
+	}
+
+	data, err := secretData(&vaultSync, payload)
+	if err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"user":   userSync.Spec.User,
+		"mount":  mount(&vaultSync),
+		"path":   vaultSync.Path,
+		"format": vaultSync.Format,
+	}).Info("Adding secret")
+
+	return p.withClient(ctx, func(client *client) error {
+		if err := client.writeSecret(ctx, mount(&vaultSync), vaultSync.Path, data); err != nil {
+			return err
+		}
+		return client.writeMetadata(ctx, mount(&vaultSync), vaultSync.Path, map[string]string{
+			"klum-user": userSync.Spec.User,
+			"klum-hash": fmt.Sprintf("%x", sha256.Sum256(payload)),
+		})
+	})
+}
+
+func (p *Provider) Delete(ctx context.Context, sync usersync.Object) error {
+	userSync, err := asUserSyncVault(sync)
+	if err != nil {
+		return err
+	}
+	vaultSync := userSync.Spec.Vault
+	if err := vaultSync.Validate(); err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"user":         userSync.Spec.User,
+		"mount":        mount(&vaultSync),
+		"path":         vaultSync.Path,
+		"deletePolicy": vaultSync.DeletePolicy,
+	}).Info("Deleting secret")
+
+	return p.withClient(ctx, func(client *client) error {
+		var err error
+		if vaultSync.DeletePolicy == klum.VaultDeletePolicySoftDelete {
+			err = client.softDeleteSecret(ctx, mount(&vaultSync), vaultSync.Path)
+		} else {
+			err = client.deleteSecret(ctx, mount(&vaultSync), vaultSync.Path)
+		}
+		if apiclient.IsNotFound(err) {
+			return nil
+		}
+		return err
+	})
+}
+
+func (p *Provider) Verify(ctx context.Context, sync usersync.Object) (bool, error) {
+	userSync, err := asUserSyncVault(sync)
+	if err != nil {
+		return false, err
+	}
+	vaultSync := userSync.Spec.Vault
+	if err := vaultSync.Validate(); err != nil {
+		return false, err
+	}
+
+	var exists bool
+	err = p.withClient(ctx, func(client *client) error {
+		var err error
+		exists, err = client.secretExists(ctx, mount(&vaultSync), vaultSync.Path)
+		return err
+	})
+	return exists, err
+}
+
+// withClient calls f with an authenticated client. A login token rejected by Vault is dropped
+// so the next call logs in again.
+func (p *Provider) withClient(ctx context.Context, f func(client *client) error) error {
+	token, err := p.token(ctx)
+	if err != nil {
+		return err
+	}
+	client, err := newClient(p.cfg.Address, token)
+	if err != nil {
+		return err
+	}
+	err = f(client)
+	if apiclient.HasStatus(err, http.StatusForbidden) {
+		p.lock.Lock()
+		p.loginToken = ""
+		p.lock.Unlock()
+	}
+	return err
+}
+
+func (p *Provider) token(ctx context.Context) (string, error) {
+	if p.cfg.KubernetesRole == "" {
+		secret, err := p.secrets.Get(p.namespace, p.cfg.TokenSecret)
+		if err != nil {
+			return "", err
+		}
+		token := strings.TrimSpace(string(secret.Data[tokenSecretKey]))
+		if token == "" {
+			return "", fmt.Errorf("secret %s/%s has no %s key", p.namespace, p.cfg.TokenSecret, tokenSecretKey)
+		}
+		return token, nil
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.loginToken != "" && time.Now().Before(p.loginExpiry) {
+		return p.loginToken, nil
+	}
+
+	jwt, err := os.ReadFile(p.jwtFile)
+	if err != nil {
+		return "", err
+	}
+	client, err := newClient(p.cfg.Address, "")
+	if err != nil {
+		return "", err
+	}
+	token, ttl, err := client.kubernetesLogin(ctx, p.cfg.KubernetesAuthPath, p.cfg.KubernetesRole, strings.TrimSpace(string(jwt)))
+	if err != nil {
+		return "", err
+	}
+	p.loginToken = token
+	p.loginExpiry = time.Now().Add(ttl - tokenRenewMargin)
+	return token, nil
+}
+
+// secretData returns the key/values stored in Vault for the kubeconfig payload
+func secretData(spec *klum.VaultSyncSpec, payload []byte) (map[string]string, error) {
+	if spec.Format != klum.VaultFormatCredentials {
+		return map[string]string{"kubeconfig": string(payload)}, nil
+	}
+
+	kubeconfig := klum.KubeconfigSpec{}
+	if err := yaml.Unmarshal(payload, &kubeconfig); err != nil {
+		return nil, err
+	}
+
+	cluster, authInfo, err := render.CurrentCredentials(&kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	ca, err := base64.StdEncoding.DecodeString(cluster.CertificateAuthorityData)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		"token":  authInfo.Token,
+		"server": cluster.Server,
+		"ca":     string(ca),
+	}, nil
+}
+
+func mount(spec *klum.VaultSyncSpec) string {
+	if spec.Mount == "" {
+		return defaultMount
+	}
+	return spec.Mount
+}
+
+func asUserSyncVault(sync usersync.Object) (*klum.UserSyncVault, error) {
+	userSync, ok := sync.(*klum.UserSyncVault)
+	if !ok {
+		return nil, fmt.Errorf("vault provider can't synchronize %T", sync)
+	}
+	return userSync, nil
+}
@@ -0,0 +1,205 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// fakeVault implements the subset of the KV v2 and Kubernetes auth APIs used by the provider
+type fakeVault struct {
+	lock     sync.Mutex
+	token    string
+	logins   int
+	data     map[string]map[string]string
+	metadata map[string]map[string]string
+	deleted  map[string]bool
+}
+
+func newFakeVault(token string) *fakeVault {
+	return &fakeVault{
+		token:    token,
+		data:     map[string]map[string]string{},
+		metadata: map[string]map[string]string{},
+		deleted:  map[string]bool{},
+	}
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if r.URL.Path == "/v1/auth/kubernetes/login" {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["role"] != "klum" || body["jwt"] != "service-account-jwt" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		f.logins++
+		_, _ = w.Write([]byte(`{"auth":{"client_token":"` + f.token + `","lease_duration":3600}}`))
+		return
+	}
+
+	if r.Header.Get("X-Vault-Token") != f.token {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/secret/data/kubeconfigs/darren":
+		var body struct {
+			Data map[string]string `json:"data"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		f.data["darren"] = body.Data
+		delete(f.deleted, "darren")
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/secret/metadata/kubeconfigs/darren":
+		var body struct {
+			CustomMetadata map[string]string `json:"custom_metadata"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		f.metadata["darren"] = body.CustomMetadata
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/secret/data/kubeconfigs/darren":
+		if f.data["darren"] == nil || f.deleted["darren"] {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"data": f.data["darren"]}})
+	case r.Method == http.MethodDelete && r.URL.Path == "/v1/secret/data/kubeconfigs/darren":
+		f.deleted["darren"] = true
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodDelete && r.URL.Path == "/v1/secret/metadata/kubeconfigs/darren":
+		delete(f.data, "darren")
+		delete(f.metadata, "darren")
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+type fakeSecrets map[string]*v1.Secret
+
+func (f fakeSecrets) Get(namespace, name string) (*v1.Secret, error) {
+	if secret, ok := f[namespace+"/"+name]; ok {
+		return secret, nil
+	}
+	return nil, errors.NewNotFound(schema.GroupResource{Resource: "secrets"}, name)
+}
+
+func newTestSync(spec klum.VaultSyncSpec) *klum.UserSyncVault {
+	spec.Path = "kubeconfigs/darren"
+	return &klum.UserSyncVault{
+		ObjectMeta: metav1.ObjectMeta{Name: "darren"},
+		Spec:       klum.UserSyncVaultSpec{User: "darren", Vault: spec},
+	}
+}
+
+func newTestPayload(t *testing.T) []byte {
+	payload, err := yaml.Marshal(klum.KubeconfigSpec{
+		Clusters:       []klum.NamedCluster{{Name: "default", Cluster: klum.Cluster{Server: "https://k8s:6443", CertificateAuthorityData: "dGVzdC1jYS1kYXRh"}}},
+		AuthInfos:      []klum.NamedAuthInfo{{Name: "darren", AuthInfo: klum.AuthInfo{Token: "secret-token"}}},
+		Contexts:       []klum.NamedContext{{Name: "default", Context: klum.Context{Cluster: "default", AuthInfo: "darren"}}},
+		CurrentContext: "default",
+	})
+	require.NoError(t, err)
+	return payload
+}
+
+func newTokenSecretProvider(t *testing.T, fake *fakeVault) *Provider {
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	secrets := fakeSecrets{"klum/vault-token": {Data: map[string][]byte{"token": []byte("root\n")}}}
+	return NewProvider(Config{Address: server.URL, TokenSecret: "vault-token"}, "klum", secrets)
+}
+
+func TestUpload_Kubeconfig(t *testing.T) {
+	fake := newFakeVault("root")
+	provider := newTokenSecretProvider(t, fake)
+	payload := newTestPayload(t)
+
+	require.NoError(t, provider.Upload(context.Background(), newTestSync(klum.VaultSyncSpec{}), payload))
+	assert.Equal(t, map[string]string{"kubeconfig": string(payload)}, fake.data["darren"])
+	assert.Equal(t, "darren", fake.metadata["darren"]["klum-user"])
+	assert.Len(t, fake.metadata["darren"]["klum-hash"], 64)
+}
+
+func TestUpload_Credentials(t *testing.T) {
+	fake := newFakeVault("root")
+	provider := newTokenSecretProvider(t, fake)
+
+	require.NoError(t, provider.Upload(context.Background(), newTestSync(klum.VaultSyncSpec{Format: klum.VaultFormatCredentials}), newTestPayload(t)))
+	assert.Equal(t, map[string]string{
+		"token":  "secret-token",
+		"server": "https://k8s:6443",
+		"ca":     "test-ca-data",
+	}, fake.data["darren"])
+}
+
+func TestDelete(t *testing.T) {
+	fake := newFakeVault("root")
+	provider := newTokenSecretProvider(t, fake)
+	payload := newTestPayload(t)
+
+	softDelete := newTestSync(klum.VaultSyncSpec{DeletePolicy: klum.VaultDeletePolicySoftDelete})
+	require.NoError(t, provider.Upload(context.Background(), softDelete, payload))
+	require.NoError(t, provider.Delete(context.Background(), softDelete))
+	exists, err := provider.Verify(context.Background(), softDelete)
+	require.NoError(t, err)
+	assert.False(t, exists)
+	assert.NotNil(t, fake.data["darren"], "soft deleted secrets can be undeleted")
+
+	hardDelete := newTestSync(klum.VaultSyncSpec{})
+	require.NoError(t, provider.Upload(context.Background(), hardDelete, payload))
+	exists, err = provider.Verify(context.Background(), hardDelete)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	require.NoError(t, provider.Delete(context.Background(), hardDelete))
+	assert.Nil(t, fake.data["darren"])
+	assert.Nil(t, fake.metadata["darren"])
+}
+
+func TestKubernetesAuth(t *testing.T) {
+	fake := newFakeVault("login-token")
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	jwtFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(jwtFile, []byte("service-account-jwt"), 0600))
+
+	provider := NewProvider(Config{Address: server.URL, KubernetesRole: "klum"}, "klum", fakeSecrets{})
+	provider.jwtFile = jwtFile
+
+	sync := newTestSync(klum.VaultSyncSpec{})
+	require.NoError(t, provider.Upload(context.Background(), sync, newTestPayload(t)))
+	require.NoError(t, provider.Upload(context.Background(), sync, newTestPayload(t)))
+	assert.Equal(t, 1, fake.logins, "the login token is reused until it expires")
+
+	// Tokens rejected by Vault are replaced on the next call
+	fake.token = "new-login-token"
+	assert.Error(t, provider.Upload(context.Background(), sync, newTestPayload(t)))
+	require.NoError(t, provider.Upload(context.Background(), sync, newTestPayload(t)))
+	assert.Equal(t, 2, fake.logins)
+}
+
+func TestUpload_MissingTokenSecret(t *testing.T) {
+	provider := NewProvider(Config{Address: "http://127.0.0.1:8200", TokenSecret: "missing"}, "klum", fakeSecrets{})
+	assert.Error(t, provider.Upload(context.Background(), newTestSync(klum.VaultSyncSpec{}), newTestPayload(t)))
+}
@@ -0,0 +1,116 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jadolg/klum/pkg/apiclient"
+)
+
+const (
+	defaultKubernetesAuthPath = "kubernetes"
+	serviceAccountTokenFile   = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	tokenSecretKey            = "token"
+	// tokenRenewMargin makes sure a login token is replaced before it expires
+	tokenRenewMargin = 30 * time.Second
+)
+
+type Config struct {
+	Address string
+	// KubernetesRole is the role used to log in with the Kubernetes auth method
+	KubernetesRole string
+	// KubernetesAuthPath is the mount path of the Kubernetes auth method
+	KubernetesAuthPath string
+	// TokenSecret is the name of a Secret in the klum namespace holding a Vault token under the token key
+	TokenSecret string
+}
+
+func (c *Config) Enabled() bool {
+	return c.Address != "" && (c.KubernetesRole != "" || c.TokenSecret != "")
+}
+
+type client struct {
+	*apiclient.Client
+}
+
+func newClient(address, token string) (*client, error) {
+	if _, err := url.Parse(address); err != nil {
+		return nil, err
+	}
+	c := apiclient.NewClient("vault", strings.TrimSuffix(address, "/")+"/v1", func(req *http.Request) {
+		if token != "" {
+			req.Header.Set("X-Vault-Token", token)
+		}
+	})
+	c.ErrorMessage = errorMessage
+	return &client{c}, nil
+}
+
+// errorMessage joins the errors of a failed response of the Vault API
+func errorMessage(body []byte) string {
+	response := struct {
+		Errors []string `json:"errors"`
+	}{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return string(body)
+	}
+	return strings.Join(response.Errors, ", ")
+}
+
+type loginResponse struct {
+	Auth struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int    `json:"lease_duration"`
+	} `json:"auth"`
+}
+
+// kubernetesLogin exchanges the service account token jwt for a Vault token
+func (c *client) kubernetesLogin(ctx context.Context, authPath, role, jwt string) (string, time.Duration, error) {
+	resp := &loginResponse{}
+	body := map[string]string{"role": role, "jwt": jwt}
+	if err := c.Do(ctx, http.MethodPost, fmt.Sprintf("/auth/%s/login", strings.Trim(authPath, "/")), body, resp); err != nil {
+		return "", 0, err
+	}
+	if resp.Auth.ClientToken == "" {
+		return "", 0, fmt.Errorf("vault kubernetes login returned no token")
+	}
+	return resp.Auth.ClientToken, time.Duration(resp.Auth.LeaseDuration) * time.Second, nil
+}
+
+func kvPath(mount, kind, path string) string {
+	return fmt.Sprintf("/%s/%s/%s", strings.Trim(mount, "/"), kind, strings.Trim(path, "/"))
+}
+
+// writeSecret creates a new version of the KV v2 secret at path
+func (c *client) writeSecret(ctx context.Context, mount, path string, data map[string]string) error {
+	return c.Do(ctx, http.MethodPost, kvPath(mount, "data", path), map[string]interface{}{"data": data}, nil)
+}
+
+// writeMetadata sets the custom metadata of the KV v2 secret at path
+func (c *client) writeMetadata(ctx context.Context, mount, path string, metadata map[string]string) error {
+	return c.Do(ctx, http.MethodPost, kvPath(mount, "metadata", path), map[string]interface{}{"custom_metadata": metadata}, nil)
+}
+
+// softDeleteSecret deletes the latest version of the secret, it can still be undeleted
+func (c *client) softDeleteSecret(ctx context.Context, mount, path string) error {
+	return c.Do(ctx, http.MethodDelete, kvPath(mount, "data", path), nil, nil)
+}
+
+// deleteSecret permanently removes every version and the metadata of the secret
+func (c *client) deleteSecret(ctx context.Context, mount, path string) error {
+	return c.Do(ctx, http.MethodDelete, kvPath(mount, "metadata", path), nil, nil)
+}
+
+// secretExists reports whether the latest version of the secret exists and is not deleted
+func (c *client) secretExists(ctx context.Context, mount, path string) (bool, error) {
+	err := c.Do(ctx, http.MethodGet, kvPath(mount, "data", path), nil, nil)
+	if apiclient.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,374 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	"github.com/jadolg/klum/pkg/encrypt"
+	"github.com/jadolg/klum/pkg/render"
+	"github.com/jadolg/klum/pkg/usersync"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	EventCreated = "created"
+	EventRotated = "rotated"
+	EventRemoved = "removed"
+
+	// SignatureHeader carries the hex encoded HMAC-SHA256 of the request body, prefixed by sha256=
+	SignatureHeader = "X-Klum-Signature"
+	// EventHeader carries the event type of the request
+	EventHeader = "X-Klum-Event"
+
+	defaultSecretKey = "secret"
+	defaultRetries   = 3
+	requestTimeout   = 10 * time.Second
+	maxBackoff       = 5 * time.Minute
+)
+
+// Payload is the JSON body POSTed to the webhooks
+type Payload struct {
+	Event     string    `json:"event"`
+	User      string    `json:"user"`
+	Sync      string    `json:"sync"`
+	Timestamp time.Time `json:"timestamp"`
+	// Hash identifies the kubeconfig version, it is empty for removed events
+	Hash string `json:"hash,omitempty"`
+	// Kubeconfig is the kubeconfig encrypted for the public key of the sync, base64 encoded
+	Kubeconfig string `json:"kubeconfig,omitempty"`
+	// Encryption is age or pgp when Kubeconfig is set
+	Encryption string `json:"encryption,omitempty"`
+}
+
+// Provider POSTs kubeconfig lifecycle events to webhooks
+type Provider struct {
+	namespace  string
+	secrets    render.SecretGetter
+	httpClient *http.Client
+	// backoff is the wait before the first retry, it doubles on every retry up to maxBackoff
+	backoff time.Duration
+
+	lock       sync.Mutex
+	deliveries map[string]*klum.DeliveryStatus
+	// attempts counts the failed attempts to deliver the current event of a sync
+	attempts map[string]attempt
+}
+
+// attempt identifies the event being delivered, a new event or kubeconfig starts counting again
+type attempt struct {
+	event string
+	hash  string
+	count int
+	// failed is set once the delivery is given up, the event isn't sent again
+	failed bool
+}
+
+// NewProvider returns a provider reading the signing secrets with secrets. Secret references
+// without a namespace point to namespace.
+func NewProvider(namespace string, secrets render.SecretGetter) *Provider {
+	return &Provider{
+		namespace:  namespace,
+		secrets:    secrets,
+		httpClient: &http.Client{Timeout: requestTimeout},
+		backoff:    time.Second,
+		deliveries: map[string]*klum.DeliveryStatus{},
+		attempts:   map[string]attempt{},
+	}
+}
+
+func (p *Provider) Name() string {
+	return "webhook"
+}
+
+// Enabled is always true, webhooks don't need any configuration in klum
+func (p *Provider) Enabled() bool {
+	return true
+}
+
+// RemoveDisabledUsers sends a removed event when the user is disabled
+func (p *Provider) RemoveDisabledUsers() bool {
+	return true
+}
+
+func (p *Provider) LastDelivery(sync usersync.Object) *klum.DeliveryStatus {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.deliveries[sync.GetName()].DeepCopy()
+}
+
+func (p *Provider) Upload(ctx context.Context, sync usersync.Object, payload []byte) error {
+	userSync, err := asUserSyncWebhook(sync)
+	if err != nil {
+		return err
+	}
+	webhookSync := userSync.Spec.Webhook
+	if err := webhookSync.Validate(); err != nil {
+		return err
+	}
+
+	event := EventCreated
+	if usersync.LastUploadHash(userSync.Status, sync, p.Name()) != "" {
+		event = EventRotated
+	}
+
+	body := &Payload{
+		Event:     event,
+		User:      userSync.Spec.User,
+		Sync:      userSync.Name,
+		Timestamp: time.Now().UTC(),
+		Hash:      fmt.Sprintf("%x", sha256.Sum256(payload)),
+	}
+	if webhookSync.PublicKey != "" {
+		encrypted, err := encrypt.Encrypt(webhookSync.PublicKey, payload)
+		if err != nil {
+			return err
+		}
+		body.Kubeconfig = base64.StdEncoding.EncodeToString(encrypted)
+		body.Encryption = "age"
+		if encrypt.Extension(webhookSync.PublicKey) == ".asc" {
+			body.Encryption = "pgp"
+		}
+	}
+
+	return p.deliver(ctx, userSync, body)
+}
+
+func (p *Provider) Delete(ctx context.Context, sync usersync.Object) error {
+	userSync, err := asUserSyncWebhook(sync)
+	if err != nil {
+		return err
+	}
+	if err := userSync.Spec.Webhook.Validate(); err != nil {
+		return err
+	}
+
+	err = p.deliver(ctx, userSync, &Payload{
+		Event:     EventRemoved,
+		User:      userSync.Spec.User,
+		Sync:      userSync.Name,
+		Timestamp: time.Now().UTC(),
+	})
+	if userSync.DeletionTimestamp != nil {
+		// Don't block the removal of the sync on a webhook that is gone, the event is only attempted once
+		p.forget(userSync)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"user": userSync.Spec.User,
+				"url":  userSync.Spec.Webhook.URL,
+			}).WithError(err).Error("Failed to deliver removed event")
+		}
+		return nil
+	}
+	return err
+}
+
+// Verify always reports true, webhooks can't be asked what they received
+func (p *Provider) Verify(ctx context.Context, sync usersync.Object) (bool, error) {
+	return true, nil
+}
+
+// DetectsDrift is false, delivered webhooks can't be read back
+func (p *Provider) DetectsDrift() bool {
+	return false
+}
+
+// deliver POSTs body to the webhook of userSync. Server errors are retried with an exponential backoff
+// by returning a usersync.RetryAfterError, so the worker isn't blocked while waiting. Once the retries
+// are used up, or the webhook rejects the request, the failed delivery is recorded and nil is returned,
+// the event is only sent again once the event or the kubeconfig changes.
+func (p *Provider) deliver(ctx context.Context, userSync *klum.UserSyncWebhook, body *Payload) error {
+	webhookSync := userSync.Spec.Webhook
+
+	secret, err := p.signingSecret(&webhookSync.SecretRef)
+	if err != nil {
+		p.record(userSync, body.Event, 0, 0, err)
+		return err
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	signature := sign(secret, data)
+
+	retries := defaultRetries
+	if webhookSync.Retries != nil {
+		retries = *webhookSync.Retries
+	}
+
+	log.WithFields(log.Fields{
+		"user":  userSync.Spec.User,
+		"url":   webhookSync.URL,
+		"event": body.Event,
+	}).Info("Delivering webhook")
+
+	count, failed := p.nextAttempt(userSync.Name, body)
+	if failed {
+		return nil
+	}
+	statusCode, retry, err := p.post(ctx, webhookSync.URL, body.Event, signature, data)
+	p.record(userSync, body.Event, statusCode, count, err)
+	if err == nil {
+		p.resetAttempts(userSync.Name)
+		return nil
+	}
+	if !retry || count > retries {
+		log.WithFields(log.Fields{
+			"user":     userSync.Spec.User,
+			"url":      webhookSync.URL,
+			"attempts": count,
+		}).WithError(err).Error("Webhook delivery failed, giving up")
+		p.giveUp(userSync.Name)
+		return nil
+	}
+
+	log.WithFields(log.Fields{
+		"user":    userSync.Spec.User,
+		"url":     webhookSync.URL,
+		"attempt": count,
+	}).WithError(err).Warn("Webhook delivery failed, retrying")
+	return &usersync.RetryAfterError{Err: err, After: p.backoffAfter(count)}
+}
+
+// nextAttempt returns the number of the attempt to deliver body for the sync name, and whether its
+// delivery was given up
+func (p *Provider) nextAttempt(name string, body *Payload) (int, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	a := p.attempts[name]
+	if a.event != body.Event || a.hash != body.Hash {
+		a = attempt{event: body.Event, hash: body.Hash}
+	}
+	if a.failed {
+		return a.count, true
+	}
+	a.count++
+	p.attempts[name] = a
+	return a.count, false
+}
+
+func (p *Provider) giveUp(name string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	a := p.attempts[name]
+	a.failed = true
+	p.attempts[name] = a
+}
+
+func (p *Provider) resetAttempts(name string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	delete(p.attempts, name)
+}
+
+// backoffAfter returns the wait before retrying the failed attempt count
+func (p *Provider) backoffAfter(count int) time.Duration {
+	backoff := p.backoff
+	for i := 1; i < count && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxBackoff)
+}
+
+// post sends a single request and reports whether a failure is worth retrying
+func (p *Provider) post(ctx context.Context, url, event, signature string, data []byte) (int, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return 0, false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event)
+	req.Header.Set(SignatureHeader, signature)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return 0, true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		err := fmt.Errorf("webhook returned %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return resp.StatusCode, retry, err
+	}
+	return resp.StatusCode, false, nil
+}
+
+// signingSecret returns the shared secret of ref. The Secret must be in the klum namespace, so a sync
+// can't read the Secrets of another namespace.
+func (p *Provider) signingSecret(ref *klum.SecretKeyReference) ([]byte, error) {
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = p.namespace
+	}
+	if namespace != p.namespace {
+		return nil, fmt.Errorf("secretRef must be in the %s namespace, not %s", p.namespace, namespace)
+	}
+	key := ref.Key
+	if key == "" {
+		key = defaultSecretKey
+	}
+
+	secret, err := p.secrets.Get(namespace, ref.Name)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := secret.Data[key]
+	if !ok || len(value) == 0 {
+		return nil, fmt.Errorf("secret %s/%s has no %s key", namespace, ref.Name, key)
+	}
+	return value, nil
+}
+
+func (p *Provider) record(userSync *klum.UserSyncWebhook, event string, statusCode, attempts int, err error) {
+	delivery := &klum.DeliveryStatus{
+		Time:       metav1.Now(),
+		Event:      event,
+		StatusCode: statusCode,
+		Attempts:   attempts,
+	}
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.deliveries[userSync.Name] = delivery
+}
+
+func (p *Provider) forget(userSync *klum.UserSyncWebhook) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	delete(p.deliveries, userSync.Name)
+	delete(p.attempts, userSync.Name)
+}
+
+// sign returns the value of SignatureHeader for data
+func sign(secret, data []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(data)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func asUserSyncWebhook(sync usersync.Object) (*klum.UserSyncWebhook, error) {
+	userSync, ok := sync.(*klum.UserSyncWebhook)
+	if !ok {
+		return nil, fmt.Errorf("webhook provider can't synchronize %T", sync)
+	}
+	return userSync, nil
+}
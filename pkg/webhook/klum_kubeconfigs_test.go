@@ -0,0 +1,230 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+	klum "github.com/jadolg/klum/pkg/apis/klum.cattle.io/v1alpha1"
+	"github.com/jadolg/klum/pkg/usersync"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type fakeSecrets map[string]*v1.Secret
+
+func (f fakeSecrets) Get(namespace, name string) (*v1.Secret, error) {
+	if secret, ok := f[namespace+"/"+name]; ok {
+		return secret, nil
+	}
+	return nil, errors.NewNotFound(schema.GroupResource{Resource: "secrets"}, name)
+}
+
+// receiver is a webhook that fails the first failures requests with status
+type receiver struct {
+	lock     sync.Mutex
+	failures int
+	status   int
+	payloads []Payload
+	valid    []bool
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.failures > 0 {
+		r.failures--
+		w.WriteHeader(r.status)
+		return
+	}
+
+	data, _ := io.ReadAll(req.Body)
+	payload := Payload{}
+	_ = json.Unmarshal(data, &payload)
+	r.payloads = append(r.payloads, payload)
+	r.valid = append(r.valid, req.Header.Get(SignatureHeader) == sign([]byte("shared-secret"), data) &&
+		req.Header.Get(EventHeader) == payload.Event)
+}
+
+func newTestProvider(t *testing.T, r *receiver) (*Provider, *klum.UserSyncWebhook) {
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+
+	provider := NewProvider("klum", fakeSecrets{
+		"klum/webhook": {Data: map[string][]byte{"secret": []byte("shared-secret")}},
+	})
+	provider.backoff = time.Millisecond
+
+	return provider, &klum.UserSyncWebhook{
+		ObjectMeta: metav1.ObjectMeta{Name: "darren-hook"},
+		Spec: klum.UserSyncWebhookSpec{
+			User: "darren",
+			Webhook: klum.WebhookSyncSpec{
+				URL:       server.URL,
+				SecretRef: klum.SecretKeyReference{Name: "webhook"},
+			},
+		},
+	}
+}
+
+func TestLifecycleEvents(t *testing.T) {
+	r := &receiver{}
+	provider, sync := newTestProvider(t, r)
+
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	sync.Status.Hash = "hash"
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("rotated")))
+	require.NoError(t, provider.Delete(context.Background(), sync))
+
+	require.Len(t, r.payloads, 3)
+	assert.Equal(t, []bool{true, true, true}, r.valid)
+	assert.Equal(t, EventCreated, r.payloads[0].Event)
+	assert.Equal(t, EventRotated, r.payloads[1].Event)
+	assert.Equal(t, EventRemoved, r.payloads[2].Event)
+	assert.Equal(t, "darren", r.payloads[0].User)
+	assert.Equal(t, "darren-hook", r.payloads[0].Sync)
+	assert.NotEqual(t, r.payloads[0].Hash, r.payloads[1].Hash)
+	assert.Empty(t, r.payloads[2].Hash)
+	assert.Empty(t, r.payloads[0].Kubeconfig, "the kubeconfig is only sent encrypted")
+
+	delivery := provider.LastDelivery(sync)
+	require.NotNil(t, delivery)
+	assert.Equal(t, EventRemoved, delivery.Event)
+	assert.Equal(t, http.StatusOK, delivery.StatusCode)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Empty(t, delivery.Error)
+}
+
+func TestEncryptedKubeconfig(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	r := &receiver{}
+	provider, sync := newTestProvider(t, r)
+	sync.Spec.Webhook.PublicKey = identity.Recipient().String()
+
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	require.Len(t, r.payloads, 1)
+	assert.Equal(t, "age", r.payloads[0].Encryption)
+
+	encrypted, err := base64.StdEncoding.DecodeString(r.payloads[0].Kubeconfig)
+	require.NoError(t, err)
+	plaintext, err := age.Decrypt(bytes.NewReader(encrypted), identity)
+	require.NoError(t, err)
+	data, err := io.ReadAll(plaintext)
+	require.NoError(t, err)
+	assert.Equal(t, "kubeconfig", string(data))
+}
+
+func TestRetries(t *testing.T) {
+	r := &receiver{failures: 2, status: http.StatusServiceUnavailable}
+	provider, sync := newTestProvider(t, r)
+	provider.backoff = time.Second
+
+	// Failed attempts are retried later instead of blocking the worker
+	err := provider.Upload(context.Background(), sync, []byte("kubeconfig"))
+	var retryAfter *usersync.RetryAfterError
+	require.ErrorAs(t, err, &retryAfter)
+	assert.Equal(t, time.Second, retryAfter.After)
+	require.ErrorAs(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")), &retryAfter)
+	assert.Equal(t, 2*time.Second, retryAfter.After, "the backoff doubles")
+
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	assert.Len(t, r.payloads, 1)
+	assert.Equal(t, 3, provider.LastDelivery(sync).Attempts)
+
+	r.failures = 10
+	retries := 1
+	sync.Spec.Webhook.Retries = &retries
+	require.ErrorAs(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")), &retryAfter)
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")), "the delivery is given up after the retries")
+	delivery := provider.LastDelivery(sync)
+	assert.Equal(t, 2, delivery.Attempts)
+	assert.Equal(t, http.StatusServiceUnavailable, delivery.StatusCode)
+	assert.NotEmpty(t, delivery.Error)
+
+	// The next event starts counting again
+	require.ErrorAs(t, provider.Upload(context.Background(), sync, []byte("rotated")), &retryAfter)
+	assert.Equal(t, 1, provider.LastDelivery(sync).Attempts)
+}
+
+func TestRetriesStop(t *testing.T) {
+	r := &receiver{failures: 100, status: http.StatusServiceUnavailable}
+	provider, sync := newTestProvider(t, r)
+	retries := 2
+	sync.Spec.Webhook.Retries = &retries
+
+	for i := 0; i < retries+5; i++ {
+		_ = provider.Upload(context.Background(), sync, []byte("kubeconfig"))
+	}
+	assert.Equal(t, retries+1, 100-r.failures, "no request is sent once the retries are used up")
+	delivery := provider.LastDelivery(sync)
+	assert.Equal(t, retries+1, delivery.Attempts)
+	assert.NotEmpty(t, delivery.Error)
+}
+
+func TestBackoffAfter(t *testing.T) {
+	provider := NewProvider("klum", fakeSecrets{})
+	assert.Equal(t, time.Second, provider.backoffAfter(1))
+	assert.Equal(t, 8*time.Second, provider.backoffAfter(4))
+	assert.Equal(t, maxBackoff, provider.backoffAfter(klum.MaxWebhookRetries+1))
+}
+
+func TestClientErrorsAreNotRetried(t *testing.T) {
+	r := &receiver{failures: 1, status: http.StatusBadRequest}
+	provider, sync := newTestProvider(t, r)
+
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	assert.Equal(t, 1, provider.LastDelivery(sync).Attempts)
+	assert.NotEmpty(t, provider.LastDelivery(sync).Error)
+
+	require.NoError(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	assert.Empty(t, r.payloads, "the rejected event is not sent again")
+}
+
+func TestMissingSecret(t *testing.T) {
+	provider, sync := newTestProvider(t, &receiver{})
+	sync.Spec.Webhook.SecretRef = klum.SecretKeyReference{Name: "missing"}
+
+	require.Error(t, provider.Upload(context.Background(), sync, []byte("kubeconfig")))
+	assert.NotEmpty(t, provider.LastDelivery(sync).Error)
+}
+
+func TestSecretOutsideNamespace(t *testing.T) {
+	r := &receiver{}
+	provider, sync := newTestProvider(t, r)
+	provider.secrets = fakeSecrets{"other/webhook": {Data: map[string][]byte{"secret": []byte("shared")}}}
+	sync.Spec.Webhook.SecretRef = klum.SecretKeyReference{Namespace: "other", Name: "webhook"}
+
+	err := provider.Upload(context.Background(), sync, []byte("kubeconfig"))
+	assert.ErrorContains(t, err, "must be in the klum namespace")
+	assert.Empty(t, r.payloads)
+}
+
+func TestDeleteDoesNotBlockRemoval(t *testing.T) {
+	r := &receiver{failures: 10, status: http.StatusInternalServerError}
+	provider, sync := newTestProvider(t, r)
+	retries := 0
+	sync.Spec.Webhook.Retries = &retries
+
+	require.NoError(t, provider.Delete(context.Background(), sync), "disabled users give up after the retries")
+	assert.NotEmpty(t, provider.LastDelivery(sync).Error)
+
+	now := metav1.Now()
+	sync.DeletionTimestamp = &now
+	require.NoError(t, provider.Delete(context.Background(), sync))
+	assert.Nil(t, provider.LastDelivery(sync))
+}